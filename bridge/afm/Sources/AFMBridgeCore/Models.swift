@@ -6,11 +6,13 @@ public struct BridgeRequest: Decodable, Sendable {
     public let model: String
     public let messages: [BridgeMessage]
     public let expectJSON: Bool
+    public let stream: Bool
 
     enum CodingKeys: String, CodingKey {
         case model
         case messages
         case expectJSON = "expect_json"
+        case stream
     }
 
     public init(from decoder: Decoder) throws {
@@ -19,6 +21,8 @@ public struct BridgeRequest: Decodable, Sendable {
         messages = try c.decode([BridgeMessage].self, forKey: .messages)
         // expect_json is optional in the Go struct (omitempty), default false.
         expectJSON = try c.decodeIfPresent(Bool.self, forKey: .expectJSON) ?? false
+        // stream is also omitempty; when true the bridge writes NDJSON.
+        stream = try c.decodeIfPresent(Bool.self, forKey: .stream) ?? false
     }
 }
 
@@ -33,19 +37,33 @@ public struct BridgeResponse: Encodable, Sendable {
     public let finishReason: String?
     public let usage: BridgeUsage?
     public var contextTrimmed: Bool?
+    // done marks the final line of an NDJSON stream; nil in normal mode.
+    public var done: Bool?
 
     enum CodingKeys: String, CodingKey {
         case content
         case finishReason = "finish_reason"
         case usage
         case contextTrimmed = "context_trimmed"
+        case done
     }
 
-    public init(content: String, finishReason: String? = nil, usage: BridgeUsage? = nil, contextTrimmed: Bool? = nil) {
+    public init(content: String, finishReason: String? = nil, usage: BridgeUsage? = nil, contextTrimmed: Bool? = nil, done: Bool? = nil) {
         self.content = content
         self.finishReason = finishReason
         self.usage = usage
         self.contextTrimmed = contextTrimmed
+        self.done = done
+    }
+}
+
+// BridgeStreamDelta is one incremental NDJSON line in stream mode. Deltas are
+// display-only on the Go side; the final BridgeResponse is authoritative.
+public struct BridgeStreamDelta: Encodable, Sendable {
+    public let delta: String
+
+    public init(delta: String) {
+        self.delta = delta
     }
 }
 
@@ -92,25 +92,88 @@ struct Handler {
         session.prewarm()
 
         do {
-            if request.expectJSON {
-                return try await inferStructured(session: session, prompt: prompt)
-            } else {
-                return try await inferPlain(session: session, prompt: prompt)
-            }
+            return try await respond(session: session, prompt: prompt, request: request)
         } catch LanguageModelSession.GenerationError.exceededContextWindowSize {
             // The 4096 token limit was exceeded. Retry with just instructions + last message.
             let freshSession = LanguageModelSession(instructions: systemPrompt)
-            var response: BridgeResponse
-            if request.expectJSON {
-                response = try await inferStructured(session: freshSession, prompt: prompt)
-            } else {
-                response = try await inferPlain(session: freshSession, prompt: prompt)
-            }
+            var response = try await respond(session: freshSession, prompt: prompt, request: request)
             response.contextTrimmed = true
             return response
         }
     }
 
+    private func respond(session: LanguageModelSession, prompt: String, request: BridgeRequest) async throws -> BridgeResponse {
+        switch (request.stream, request.expectJSON) {
+        case (true, true):
+            return try await streamStructured(session: session, prompt: prompt)
+        case (true, false):
+            return try await streamPlain(session: session, prompt: prompt)
+        case (false, true):
+            return try await inferStructured(session: session, prompt: prompt)
+        case (false, false):
+            return try await inferPlain(session: session, prompt: prompt)
+        }
+    }
+
+    // MARK: - Streaming generation (stream=true, NDJSON on stdout)
+
+    /// Streams the text field of a structured response. Each delta line carries a
+    /// raw JSON fragment so that concatenated deltas form a prefix of the final
+    /// content, which is what the Go side's incremental text extractor expects.
+    private func streamStructured(session: LanguageModelSession, prompt: String) async throws -> BridgeResponse {
+        do {
+            let stream = session.streamResponse(to: prompt, generating: ShellBudResponse.self)
+            var emitted = ""
+            var openedText = false
+            for try await snapshot in stream {
+                guard let text = snapshot.content.text, text.count > emitted.count, text.hasPrefix(emitted) else {
+                    continue
+                }
+                if !openedText {
+                    try IO.writeJSON(BridgeStreamDelta(delta: #"{"text":""#))
+                    openedText = true
+                }
+                let delta = String(text.dropFirst(emitted.count))
+                try IO.writeJSON(BridgeStreamDelta(delta: try encodeJSONFragment(delta)))
+                emitted = text
+            }
+            let result = try await stream.collect()
+            let jsonContent = try encodeAsJSONString(result.content)
+            return BridgeResponse(content: jsonContent, finishReason: "stop", done: true)
+        } catch {
+            // Let infer() retry with a trimmed session on context overflow.
+            if let genError = error as? LanguageModelSession.GenerationError,
+               case .exceededContextWindowSize = genError {
+                throw genError
+            }
+            // Same fail-closed fallback as inferStructured: text only, no commands.
+            var response = try await inferStructured(session: session, prompt: prompt)
+            response.done = true
+            return response
+        }
+    }
+
+    private func streamPlain(session: LanguageModelSession, prompt: String) async throws -> BridgeResponse {
+        do {
+            let stream = session.streamResponse(to: prompt)
+            var emitted = ""
+            for try await snapshot in stream {
+                let text = snapshot.content
+                guard text.count > emitted.count, text.hasPrefix(emitted) else {
+                    continue
+                }
+                try IO.writeJSON(BridgeStreamDelta(delta: String(text.dropFirst(emitted.count))))
+                emitted = text
+            }
+            let result = try await stream.collect()
+            return BridgeResponse(content: result.content, finishReason: "stop", done: true)
+        } catch let error as LanguageModelSession.GenerationError {
+            throw error
+        } catch {
+            throw BridgeError.inferenceError(error.localizedDescription)
+        }
+    }
+
     // MARK: - Structured generation (expect_json=true)
 
     private func inferStructured(session: LanguageModelSession, prompt: String) async throws -> BridgeResponse {
@@ -194,6 +257,16 @@ struct Handler {
         }
     }
 
+    /// Encodes a string as JSON and strips the surrounding quotes, yielding an
+    /// escaped fragment that can be spliced into a JSON string literal.
+    private func encodeJSONFragment(_ text: String) throws -> String {
+        let data = try JSONEncoder().encode(text)
+        guard let quoted = String(data: data, encoding: .utf8), quoted.count >= 2 else {
+            throw BridgeError.encodingFailed("UTF-8 encoding failed")
+        }
+        return String(quoted.dropFirst().dropLast())
+    }
+
     /// Encodes a ShellBudResponse to the compact JSON string the Go side parses.
     private func encodeAsJSONString(_ response: ShellBudResponse) throws -> String {
         struct Wire: Encodable {
@@ -26,6 +26,20 @@ struct BridgeRequestTests {
         #expect(req.expectJSON == false)
     }
 
+    @Test("stream defaults to false when omitted")
+    func streamDefaultsFalse() throws {
+        let json = #"{"model":"default","messages":[]}"#
+        let req = try JSONDecoder().decode(BridgeRequest.self, from: Data(json.utf8))
+        #expect(req.stream == false)
+    }
+
+    @Test("decodes stream flag")
+    func decodesStream() throws {
+        let json = #"{"model":"default","messages":[],"stream":true}"#
+        let req = try JSONDecoder().decode(BridgeRequest.self, from: Data(json.utf8))
+        #expect(req.stream == true)
+    }
+
     @Test("decodes multiple messages")
     func decodesMultipleMessages() throws {
         let json = """
@@ -92,6 +106,35 @@ struct BridgeResponseTests {
     }
 }
 
+// MARK: - Stream encoding
+
+@Suite("Stream encoding")
+struct StreamEncodingTests {
+    @Test("delta line has only the delta key")
+    func encodesDelta() throws {
+        let data = try JSONEncoder().encode(BridgeStreamDelta(delta: "hel"))
+        let dict = try JSONSerialization.jsonObject(with: data) as! [String: Any]
+        #expect(dict["delta"] as? String == "hel")
+        #expect(dict.count == 1)
+    }
+
+    @Test("final line carries done flag")
+    func encodesDone() throws {
+        let response = BridgeResponse(content: "hello", finishReason: "stop", done: true)
+        let data = try JSONEncoder().encode(response)
+        let dict = try JSONSerialization.jsonObject(with: data) as! [String: Any]
+        #expect(dict["content"] as? String == "hello")
+        #expect(dict["done"] as? Bool == true)
+    }
+
+    @Test("done omitted in normal mode")
+    func omitsDone() throws {
+        let data = try JSONEncoder().encode(BridgeResponse(content: "hello"))
+        let dict = try JSONSerialization.jsonObject(with: data) as! [String: Any]
+        #expect(dict["done"] == nil)
+    }
+}
+
 // MARK: - AvailabilityResponse encoding
 
 @Suite("AvailabilityResponse encoding")
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/hpkotak/shellbud/internal/approval"
+)
+
+// Approval flags. They are persistent so the same policy applies to one-shot
+// queries, sb chat and sb fix.
+var (
+	dryRunFlag  bool
+	yesSafeFlag bool
+	yesFlag     bool
+	// allowForbiddenFlag lets forbidden commands be offered for
+	// confirmation. No policy runs them without asking.
+	allowForbiddenFlag bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&dryRunFlag, "dry-run", false, "show suggested commands but never run them")
+	rootCmd.PersistentFlags().BoolVar(&yesSafeFlag, "yes-safe", false, "run safe commands without asking and refuse all others")
+	rootCmd.PersistentFlags().BoolVar(&yesFlag, "yes", false, "run commands without asking, except network and forbidden ones such as curl | sh and rm -rf /")
+	rootCmd.PersistentFlags().BoolVar(&allowForbiddenFlag, "allow-forbidden", false, "offer forbidden commands such as rm -rf / for confirmation instead of refusing them")
+}
+
+// approvalPolicy returns the policy chosen with the approval flags.
+func approvalPolicy() (approval.Policy, error) {
+	return approval.FromFlags(dryRunFlag, yesSafeFlag, yesFlag)
+}
+
+// echoPolicy tells the user which policy is in force, unless it is the
+// default of asking.
+func echoPolicy(policy approval.Policy, out io.Writer) {
+	if policy != approval.Ask {
+		_, _ = fmt.Fprintf(out, "Approval policy: %s\n", policy.Describe())
+	}
+}
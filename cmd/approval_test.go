@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+func TestRunTranslatePolicy(t *testing.T) {
+	const response = `{"text":"Clean up.","commands":["ls -la","rm -rf build","rm -rf /"]}`
+	tests := []struct {
+		name    string
+		set     func()
+		wantRan []string
+		wantOut []string
+		wantErr string
+	}{
+		{
+			name:    "dry-run runs nothing",
+			set:     func() { dryRunFlag = true },
+			wantOut: []string{"Approval policy: dry-run", "Not run (dry run)."},
+		},
+		{
+			name:    "yes-safe runs only safe commands",
+			set:     func() { yesSafeFlag = true },
+			wantRan: []string{"ls -la"},
+			wantOut: []string{"Approval policy: yes-safe", "Running (safe command, --yes-safe).", "Refused: destructive commands are not run with --yes-safe."},
+			wantErr: "2 command(s) refused by the yes-safe policy",
+		},
+		{
+			name:    "yes runs all but forbidden commands",
+			set:     func() { yesFlag = true },
+			wantRan: []string{"ls -la", "rm -rf build"},
+			wantOut: []string{"Approval policy: yes", "Refused: forbidden commands are never run automatically."},
+			wantErr: "1 command(s) refused by the yes policy",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveCmdVars(t)
+			defer restore()
+			setupTestConfig(t, config.Default())
+
+			newProvider = func(*config.Config, string) (provider.Provider, error) {
+				return &mockProvider{chatResult: response}, nil
+			}
+			var ran []string
+			runCommand = func(command string) error {
+				ran = append(ran, command)
+				return nil
+			}
+			// Nothing is read: a policy never prompts.
+			ioIn = strings.NewReader("")
+			out := &bytes.Buffer{}
+			ioOut = out
+			tt.set()
+
+			err := runTranslate(rootCmd, []string{"clean", "up"})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("runTranslate() error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("runTranslate() error = %v, want %q", err, tt.wantErr)
+			}
+			if strings.Join(ran, "|") != strings.Join(tt.wantRan, "|") {
+				t.Errorf("ran %q, want %q", ran, tt.wantRan)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPolicyFlagConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func()
+		wantErr string
+	}{
+		{"two policies", func() { dryRunFlag, yesFlag = true, true }, "cannot be combined"},
+		{"print-command", func() { printCommandFlag, yesSafeFlag = true, true }, "--print-command cannot be combined with --yes-safe"},
+		{"json output", func() { outputFlag, yesFlag = "json", true }, "--yes cannot be combined with --output json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveCmdVars(t)
+			defer restore()
+			tt.set()
+			if err := runTranslate(rootCmd, []string{"x"}); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunChatPolicyConflicts(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	outputFlag = "ndjson"
+	yesSafeFlag = true
+	if err := runChat(chatCmd, nil); err == nil || !strings.Contains(err.Error(), "--yes-safe cannot be combined with --output ndjson") {
+		t.Errorf("error = %v, want conflict", err)
+	}
+
+	yesSafeFlag, yesFlag = true, true
+	if err := runChat(chatCmd, nil); err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("error = %v, want conflict", err)
+	}
+}
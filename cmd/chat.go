@@ -4,28 +4,74 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"time"
 
+	"github.com/hpkotak/shellbud/internal/approval"
 	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/output"
 	"github.com/hpkotak/shellbud/internal/repl"
+	"github.com/hpkotak/shellbud/internal/sessions"
 	"github.com/spf13/cobra"
 )
 
 var chatCmd = &cobra.Command{
-	Use:   "chat",
+	Use:   "chat [--resume [id]]",
 	Short: "Start an interactive shell assistant session",
 	Long: `Start an interactive chat session with ShellBud.
 Ask questions, get commands, run them, and continue the conversation.
 
+Sessions are saved under ~/.shellbud/sessions after every turn. Resume the
+most recent one with 'sb chat --resume', or a specific one with
+'sb chat --resume <id>' (see 'sb sessions list').
+
 Type 'exit' or 'quit' to end the session. Ctrl+D also works.`,
+	Args: cobra.MaximumNArgs(1),
 	RunE: runChat,
 }
 
+var resumeFlag bool
+
 func init() {
+	chatCmd.Flags().StringVarP(&outputFlag, "output", "o", string(output.Text), "output format: text or ndjson (one JSON document per response; never runs commands)")
+	chatCmd.Flags().BoolVar(&resumeFlag, "resume", false, "resume the most recent session, or the session given as an argument")
 	rootCmd.AddCommand(chatCmd)
 }
 
 func runChat(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 && !resumeFlag {
+		return fmt.Errorf("unexpected argument %q (use --resume %s to resume a session)", args[0], args[0])
+	}
+
+	format, err := output.ParseFormat(outputFlag)
+	if err != nil {
+		return err
+	}
+	policy, err := approvalPolicy()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case output.Text:
+		return chat(args, false, policy)
+	case output.JSON:
+		return fmt.Errorf("sb chat writes one document per response; use --output ndjson")
+	}
+	if policy == approval.YesSafe || policy == approval.Yes {
+		return fmt.Errorf("--%s cannot be combined with --output ndjson, which never runs commands", policy)
+	}
+	if err := chat(args, true, policy); err != nil {
+		_ = output.NewEncoder(ioOut, output.NDJSON).Error(err)
+		return err
+	}
+	return nil
+}
+
+// chat runs the chat session under policy, writing result lines instead of
+// human text when ndjson is set.
+func chat(args []string, ndjson bool, policy approval.Policy) error {
 	cfg, err := config.Load()
 	if err != nil {
 		if errors.Is(err, config.ErrNotFound) {
@@ -33,6 +79,9 @@ func runChat(cmd *cobra.Command, args []string) error {
 		}
 		return fmt.Errorf("loading config: %w", err)
 	}
+	if err := loadSafetyPolicy(cfg); err != nil {
+		return err
+	}
 
 	model := cfg.Model
 	if modelFlag != "" {
@@ -50,5 +99,67 @@ func runChat(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("provider not ready: %w\n\nRun 'sb setup' to reconfigure", err)
 	}
 
-	return repl.Run(p, ioIn, ioOut)
+	handle, err := openSession(args, p.Name(), model)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = handle.Close() }()
+
+	runner := chatRunner(cfg)
+	defer func() { _ = runner.Close() }()
+
+	return repl.Run(p, ioIn, ioOut, repl.Options{
+		ContextWindow:    cfg.ContextWindow(model),
+		Summarize:        cfg.Summarize,
+		Session:          handle,
+		Model:            model,
+		ContextWindowFor: cfg.ContextWindow,
+		HistoryPath:      filepath.Join(config.Dir(), "chat_history"),
+		NDJSON:           ndjson,
+		Policy:           policy,
+		Runner:           runner,
+		AllowForbidden:   allowForbiddenFlag,
+	})
+}
+
+// chatRunner returns what runs chat's commands: one long-lived shell when
+// shell_session is set, otherwise a fresh shell per command.
+func chatRunner(cfg *config.Config) executor.Runner {
+	if cfg.ShellSession {
+		return executor.NewShellSession(cfg.CommandTimeout)
+	}
+	return &executor.Shell{}
+}
+
+// openSession resumes the requested session (the most recent one when no ID
+// is given) or starts a new one. A resumed session records the provider and
+// model now in use.
+func openSession(args []string, providerName, model string) (*sessions.Handle, error) {
+	store := sessions.NewStore(sessions.Dir())
+	if !resumeFlag {
+		cwd, _ := os.Getwd()
+		return store.Create(providerName, model, cwd)
+	}
+
+	var id string
+	if len(args) > 0 {
+		id = args[0]
+	} else {
+		latest, err := store.Latest()
+		if err != nil {
+			if errors.Is(err, sessions.ErrNotFound) {
+				return nil, fmt.Errorf("no saved sessions to resume")
+			}
+			return nil, err
+		}
+		id = latest
+	}
+
+	handle, err := store.Open(id)
+	if err != nil {
+		return nil, fmt.Errorf("resuming session: %w", err)
+	}
+	handle.Session.Provider = providerName
+	handle.Session.Model = model
+	return handle, nil
 }
@@ -2,13 +2,20 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/output"
 	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/sessions"
+	"github.com/hpkotak/shellbud/internal/shellhook"
 )
 
 func TestRunChat(t *testing.T) {
@@ -137,6 +144,42 @@ func TestRunChat(t *testing.T) {
 	}
 }
 
+func TestRunChatShellSession(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+
+	cfg := config.Default()
+	cfg.ShellSession = true
+	setupTestConfig(t, cfg)
+	newProvider = func(cfg *config.Config, model string) (provider.Provider, error) {
+		return &mockProvider{}, nil
+	}
+	ioIn = strings.NewReader("exit\n")
+	out := &bytes.Buffer{}
+	ioOut = out
+
+	if err := runChat(rootCmd, nil); err != nil {
+		t.Fatalf("runChat() error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Commands run in one shell session") {
+		t.Errorf("banner should mention the shell session:\n%s", out.String())
+	}
+}
+
+func TestChatRunner(t *testing.T) {
+	cfg := config.Default()
+	if _, ok := chatRunner(cfg).(*executor.Shell); !ok {
+		t.Errorf("chatRunner() = %T by default, want *executor.Shell", chatRunner(cfg))
+	}
+
+	cfg.ShellSession = true
+	cfg.CommandTimeout = time.Minute
+	ss, ok := chatRunner(cfg).(*executor.ShellSession)
+	if !ok || ss.Timeout != time.Minute {
+		t.Errorf("chatRunner() = %#v with shell_session, want a ShellSession with the configured timeout", chatRunner(cfg))
+	}
+}
+
 func TestExecute(t *testing.T) {
 	restore := saveCmdVars(t)
 	defer restore()
@@ -160,3 +203,184 @@ func TestExecute(t *testing.T) {
 		t.Errorf("help output missing expected content, got:\n%s", buf.String())
 	}
 }
+
+func TestExecuteForgetsHookOutsideFix(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	defer rootCmd.SetArgs(nil)
+	origOut := rootCmd.OutOrStdout()
+	defer rootCmd.SetOut(origOut)
+	rootCmd.SetOut(io.Discard)
+
+	for _, tt := range []struct {
+		args     []string
+		wantKept bool
+	}{
+		{[]string{"init", "bash"}, false},
+		{[]string{"fix", "--hook", "bash"}, true},
+	} {
+		t.Setenv(shellhook.EnvLastCommand, "make")
+		rootCmd.SetArgs(tt.args)
+		if err := Execute(); err != nil {
+			t.Fatalf("Execute(%q) error: %v", tt.args, err)
+		}
+		if _, kept := os.LookupEnv(shellhook.EnvLastCommand); kept != tt.wantKept {
+			t.Errorf("after %q, %s set = %v, want %v", tt.args, shellhook.EnvLastCommand, kept, tt.wantKept)
+		}
+	}
+}
+
+// chatOnce runs a one-question chat and returns its output.
+func chatOnce(t *testing.T, args []string, input string) (string, error) {
+	t.Helper()
+	newProvider = func(cfg *config.Config, model string) (provider.Provider, error) {
+		return &mockProvider{chatResult: `{"text":"Noted.","commands":[]}`}, nil
+	}
+	out := &bytes.Buffer{}
+	ioIn = strings.NewReader(input)
+	ioOut = out
+	err := runChat(rootCmd, args)
+	return out.String(), err
+}
+
+func TestRunChatSavesAndResumesSessions(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	setupTestConfig(t, config.Default())
+	store := sessions.NewStore(sessions.Dir())
+
+	if _, err := chatOnce(t, nil, "exit\n"); err != nil {
+		t.Fatalf("runChat(): %v", err)
+	}
+	if list, _ := store.List(); len(list) != 0 {
+		t.Fatalf("empty chat saved %d sessions, want 0", len(list))
+	}
+
+	if _, err := chatOnce(t, nil, "first question\nexit\n"); err != nil {
+		t.Fatalf("runChat(): %v", err)
+	}
+	list, err := store.List()
+	if err != nil || len(list) != 1 {
+		t.Fatalf("List() = %d sessions, %v; want 1", len(list), err)
+	}
+	id := list[0].ID
+	if list[0].Provider != "mock" || list[0].Model != config.DefaultModel {
+		t.Errorf("saved provider/model = %s/%s, want mock/%s", list[0].Provider, list[0].Model, config.DefaultModel)
+	}
+
+	resumeFlag = true
+	out, err := chatOnce(t, nil, "second question\nexit\n")
+	if err != nil {
+		t.Fatalf("runChat(--resume): %v", err)
+	}
+	if !strings.Contains(out, "Resumed session "+id) {
+		t.Errorf("--resume should resume the latest session, got:\n%s", out)
+	}
+
+	modelFlag = "codellama:7b"
+	if _, err := chatOnce(t, []string{id[:10]}, "third question\nexit\n"); err != nil {
+		t.Fatalf("runChat(--resume id): %v", err)
+	}
+	s, err := store.Load(id)
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if len(s.Messages) != 6 {
+		t.Errorf("resumed session has %d messages, want 6", len(s.Messages))
+	}
+	if s.Model != "codellama:7b" {
+		t.Errorf("resumed session model = %q, want the model now in use", s.Model)
+	}
+}
+
+func TestRunChatResumeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		resume  bool
+		args    []string
+		locked  bool
+		wantErr string
+	}{
+		{name: "argument without resume", args: []string{"abc"}, wantErr: "use --resume abc"},
+		{name: "nothing to resume", resume: true, wantErr: "no saved sessions to resume"},
+		{name: "unknown session", resume: true, args: []string{"missing"}, wantErr: "session not found"},
+		{name: "session open elsewhere", resume: true, locked: true, wantErr: "in use by another sb process"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveCmdVars(t)
+			defer restore()
+			setupTestConfig(t, config.Default())
+
+			args := tt.args
+			if tt.locked {
+				h, err := sessions.NewStore(sessions.Dir()).Create("mock", "m", "/tmp")
+				if err != nil {
+					t.Fatalf("Create(): %v", err)
+				}
+				defer func() { _ = h.Close() }()
+				if err := h.Save(); err != nil {
+					t.Fatalf("Save(): %v", err)
+				}
+				args = []string{h.Session.ID}
+			}
+
+			resumeFlag = tt.resume
+			_, err := chatOnce(t, args, "exit\n")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runChat() error = %v, want substring %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunChatNDJSON(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	setupTestConfig(t, config.Default())
+	outputFlag = "ndjson"
+
+	out, err := chatOnce(t, nil, "first\nsecond\n")
+	if err != nil {
+		t.Fatalf("runChat(): %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want one per response:\n%s", len(lines), out)
+	}
+	for _, line := range lines {
+		var doc output.Result
+		if err := json.Unmarshal([]byte(line), &doc); err != nil {
+			t.Fatalf("line is not JSON: %v\n%s", err, line)
+		}
+		if doc.Version != output.Version || doc.Text != "Noted." {
+			t.Errorf("document = %+v", doc)
+		}
+	}
+}
+
+func TestRunChatOutputErrors(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+
+	outputFlag = "json"
+	if err := runChat(rootCmd, nil); err == nil || !strings.Contains(err.Error(), "use --output ndjson") {
+		t.Errorf("--output json error = %v", err)
+	}
+	outputFlag = "xml"
+	if err := runChat(rootCmd, nil); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("--output xml error = %v", err)
+	}
+
+	// Without a config, the failure is reported as a document.
+	t.Setenv("HOME", t.TempDir())
+	outputFlag = "ndjson"
+	out := &bytes.Buffer{}
+	ioOut = out
+	if err := runChat(rootCmd, nil); err == nil {
+		t.Fatal("expected error without config")
+	}
+	if !strings.HasPrefix(out.String(), `{"version":1,"error":"no config found`) {
+		t.Errorf("output = %q, want failure document", out.String())
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/safety"
 	"github.com/spf13/cobra"
 )
 
@@ -14,11 +18,22 @@ var configSetCmd = &cobra.Command{
 	Use:   "set <key> <value>",
 	Short: "Update a configuration value",
 	Long: `Update a configuration value. Supported keys:
-  provider    LLM provider (ollama/openai/afm)
-  model       Model name (e.g., llama3.2:latest)
-  ollama.host Ollama server URL
-  openai.host OpenAI-compatible API base URL
-  afm.command AFM bridge executable path`,
+  provider       LLM provider (ollama/openai/afm/anthropic/gemini)
+  model          Model name (e.g., llama3.2:latest)
+  ollama.host    Ollama server URL
+  openai.host    OpenAI-compatible API base URL
+  afm.command    AFM bridge executable path
+  anthropic.host Anthropic Messages API base URL
+  gemini.host    Gemini API base URL
+  summarize      Summarize chat turns that no longer fit the context window (true/false)
+  shell_session  Run chat commands in one long-lived shell (true/false)
+  command_timeout
+                 Time limit for each command in the shell session (e.g., 30m)
+  context_windows.<model>
+                 Context window in tokens for <model> (e.g., context_windows.llama3.2:latest 8192)
+  safety_families.<family>
+                 Turn a family of built-in safety rules on or off
+                 (git/container/kubernetes/cloud/database; e.g., safety_families.git false)`,
 	Args: cobra.ExactArgs(2),
 	RunE: runConfigSet,
 }
@@ -63,8 +78,61 @@ func runConfigSet(cmd *cobra.Command, args []string) error {
 			return fmt.Errorf("afm command cannot be empty")
 		}
 		cfg.AFM.Command = value
+	case "anthropic.host":
+		if _, err := url.ParseRequestURI(value); err != nil {
+			return fmt.Errorf("invalid URL %q: %w", value, err)
+		}
+		cfg.Anthropic.Host = value
+	case "gemini.host":
+		if _, err := url.ParseRequestURI(value); err != nil {
+			return fmt.Errorf("invalid URL %q: %w", value, err)
+		}
+		cfg.Gemini.Host = value
+	case "summarize":
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("summarize must be true or false, got %q", value)
+		}
+		cfg.Summarize = enabled
+	case "shell_session":
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("shell_session must be true or false, got %q", value)
+		}
+		cfg.ShellSession = enabled
+	case "command_timeout":
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("command_timeout must be a positive duration such as 30m, got %q", value)
+		}
+		cfg.CommandTimeout = timeout
 	default:
-		return fmt.Errorf("unknown config key: %s", key)
+		if family, ok := strings.CutPrefix(key, "safety_families."); ok {
+			if !slices.Contains(safety.Families(), family) {
+				return fmt.Errorf("unknown safety rule family %q (families: %s)", family, strings.Join(safety.Families(), ", "))
+			}
+			enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+			if err != nil {
+				return fmt.Errorf("%s must be true or false, got %q", key, value)
+			}
+			if cfg.SafetyFamilies == nil {
+				cfg.SafetyFamilies = make(map[string]bool)
+			}
+			cfg.SafetyFamilies[family] = enabled
+			break
+		}
+		model, ok := strings.CutPrefix(key, "context_windows.")
+		if !ok || model == "" {
+			return fmt.Errorf("unknown config key: %s", key)
+		}
+		tokens, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || tokens <= 0 {
+			return fmt.Errorf("context window must be a positive number of tokens, got %q", value)
+		}
+		if cfg.ContextWindows == nil {
+			cfg.ContextWindows = make(map[string]int)
+		}
+		cfg.ContextWindows[model] = tokens
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -94,5 +162,13 @@ func applyProviderDefaults(cfg *config.Config) {
 		if strings.TrimSpace(cfg.AFM.Command) == "" {
 			cfg.AFM.Command = defaults.AFM.Command
 		}
+	case "anthropic":
+		if strings.TrimSpace(cfg.Anthropic.Host) == "" {
+			cfg.Anthropic.Host = defaults.Anthropic.Host
+		}
+	case "gemini":
+		if strings.TrimSpace(cfg.Gemini.Host) == "" {
+			cfg.Gemini.Host = defaults.Gemini.Host
+		}
 	}
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -19,7 +20,9 @@ func TestRunConfigSet(t *testing.T) {
 		{"set valid provider", "provider", "ollama", ""},
 		{"set second valid provider", "provider", "openai", ""},
 		{"set third valid provider", "provider", "afm", ""},
-		{"set invalid provider", "provider", "anthropic", "invalid provider"},
+		{"set fourth valid provider", "provider", "anthropic", ""},
+		{"set fifth valid provider", "provider", "gemini", ""},
+		{"set invalid provider", "provider", "unknown", "invalid provider"},
 		{"set valid model", "model", "codellama:7b", ""},
 		{"set empty model after trim", "model", "   ", "model cannot be empty"},
 		{"set valid host", "ollama.host", "http://192.168.1.100:11434", ""},
@@ -28,6 +31,25 @@ func TestRunConfigSet(t *testing.T) {
 		{"set invalid openai host", "openai.host", "://broken", "invalid URL"},
 		{"set valid afm command", "afm.command", "/usr/local/bin/afm-bridge", ""},
 		{"set invalid afm command", "afm.command", "", "afm command cannot be empty"},
+		{"set valid anthropic host", "anthropic.host", "https://api.anthropic.com/v1", ""},
+		{"set invalid anthropic host", "anthropic.host", "://broken", "invalid URL"},
+		{"set valid gemini host", "gemini.host", "https://generativelanguage.googleapis.com/v1beta", ""},
+		{"set invalid gemini host", "gemini.host", "://broken", "invalid URL"},
+		{"set context window", "context_windows.llama3.2:latest", "4096", ""},
+		{"set non-numeric context window", "context_windows.llama3.2:latest", "big", "positive number of tokens"},
+		{"set zero context window", "context_windows.llama3.2:latest", "0", "positive number of tokens"},
+		{"context window without model", "context_windows.", "4096", "unknown config key"},
+		{"enable summarize", "summarize", "true", ""},
+		{"invalid summarize", "summarize", "maybe", "true or false"},
+		{"enable shell session", "shell_session", "true", ""},
+		{"invalid shell session", "shell_session", "sometimes", "true or false"},
+		{"set command timeout", "command_timeout", "30m0s", ""},
+		{"invalid command timeout", "command_timeout", "soon", "positive duration"},
+		{"zero command timeout", "command_timeout", "0s", "positive duration"},
+		{"turn off a safety family", "safety_families.git", "false", ""},
+		{"turn on a safety family", "safety_families.git", "true", ""},
+		{"unknown safety family", "safety_families.gti", "false", `unknown safety rule family "gti"`},
+		{"invalid safety family value", "safety_families.git", "off", "true or false"},
 		{"unknown key", "unknown.key", "value", "unknown config key"},
 	}
 
@@ -79,6 +101,20 @@ func TestRunConfigSet(t *testing.T) {
 				got = loaded.OpenAI.Host
 			case "afm.command":
 				got = loaded.AFM.Command
+			case "anthropic.host":
+				got = loaded.Anthropic.Host
+			case "gemini.host":
+				got = loaded.Gemini.Host
+			case "context_windows.llama3.2:latest":
+				got = strconv.Itoa(loaded.ContextWindow("llama3.2:latest"))
+			case "summarize":
+				got = strconv.FormatBool(loaded.Summarize)
+			case "shell_session":
+				got = strconv.FormatBool(loaded.ShellSession)
+			case "command_timeout":
+				got = loaded.CommandTimeout.String()
+			case "safety_families.git":
+				got = strconv.FormatBool(loaded.SafetyFamilies["git"])
 			}
 			if got != tt.value {
 				t.Errorf("config[%s] = %q after set, want %q", tt.key, got, tt.value)
@@ -187,4 +223,26 @@ ollama:
 	if loaded.AFM.Command != config.DefaultAFMCommand {
 		t.Errorf("AFM.Command = %q, want %q", loaded.AFM.Command, config.DefaultAFMCommand)
 	}
+
+	if err := runConfigSet(nil, []string{"provider", "anthropic"}); err != nil {
+		t.Fatalf("set provider anthropic: %v", err)
+	}
+	loaded, err = config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if loaded.Anthropic.Host != config.DefaultAnthropicHost {
+		t.Errorf("Anthropic.Host = %q, want %q", loaded.Anthropic.Host, config.DefaultAnthropicHost)
+	}
+
+	if err := runConfigSet(nil, []string{"provider", "gemini"}); err != nil {
+		t.Fatalf("set provider gemini: %v", err)
+	}
+	loaded, err = config.Load()
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if loaded.Gemini.Host != config.DefaultGeminiHost {
+		t.Errorf("Gemini.Host = %q, want %q", loaded.Gemini.Host, config.DefaultGeminiHost)
+	}
 }
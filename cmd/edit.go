@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/lineedit"
+)
+
+// editCommand lets the user change command before running it with
+// executor.EditCommand, editing in place when in is a terminal.
+func editCommand(command string, in io.Reader, out io.Writer) string {
+	var r executor.LineReader = plainReader{in: in, out: out}
+	if f, ok := in.(*os.File); ok && stdinIsTerminal(f) {
+		r = lineedit.NewTerminal(f, out)
+	}
+	edited, err := executor.EditCommand(command, r, out)
+	if err != nil && !errors.Is(err, io.EOF) {
+		_, _ = fmt.Fprintf(out, "  Input error: %v\n", err)
+	}
+	return edited
+}
+
+// plainReader reads a line from input that is not a terminal. It scans in
+// afresh for each line, as the confirmation prompts that share in do.
+type plainReader struct {
+	in  io.Reader
+	out io.Writer
+}
+
+func (r plainReader) ReadLine(prompt string) (string, error) {
+	_, _ = fmt.Fprint(r.out, prompt)
+	scanner := bufio.NewScanner(r.in)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return scanner.Text(), nil
+}
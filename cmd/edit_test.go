@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+func TestRunTranslateModify(t *testing.T) {
+	tests := []struct {
+		name    string
+		visual  string
+		input   string
+		wantRan string
+		wantOut []string
+	}{
+		{
+			name:    "inline edit",
+			input:   "m\nls -la /tmp\n\n",
+			wantRan: "ls -la /tmp",
+			wantOut: []string{"Run this? [Y/n/m]", "New command (Enter keeps it):", "> ls -la /tmp"},
+		},
+		{
+			name:    "edit to a destructive command defaults to no",
+			input:   "m\nrm -rf /tmp/cache\n\n",
+			wantOut: []string{"> rm -rf /tmp/cache", "Warning: this is a destructive command (deletes files; recursive delete).", "Are you sure? [y/N/m]", "Skipped."},
+		},
+		{
+			name:    "empty edit keeps the suggestion",
+			input:   "m\n\ny\n",
+			wantRan: "ls -la",
+		},
+		{
+			name:    "editor from VISUAL",
+			visual:  `printf 'ls -la ~\n' >`,
+			input:   "m\ny\n",
+			wantRan: "ls -la ~",
+		},
+		{
+			name:    "editor failure keeps the suggestion",
+			visual:  "false",
+			input:   "m\ny\n",
+			wantRan: "ls -la",
+			wantOut: []string{"Edit error:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveCmdVars(t)
+			defer restore()
+			setupTestConfig(t, config.Default())
+			t.Setenv("VISUAL", tt.visual)
+			t.Setenv("EDITOR", "")
+
+			newProvider = func(*config.Config, string) (provider.Provider, error) {
+				return &mockProvider{chatResult: `{"text":"List them.","commands":["ls -la"]}`}, nil
+			}
+			ran := ""
+			runCommand = func(command string) error {
+				ran = command
+				return nil
+			}
+			// One byte at a time, so each prompt reads only its line.
+			ioIn = iotest.OneByteReader(strings.NewReader(tt.input))
+			out := &bytes.Buffer{}
+			ioOut = out
+
+			if err := runTranslate(rootCmd, []string{"list", "files"}); err != nil {
+				t.Fatalf("runTranslate() error: %v", err)
+			}
+			if ran != tt.wantRan {
+				t.Errorf("ran %q, want %q", ran, tt.wantRan)
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpkotak/shellbud/internal/approval"
+	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/prompt"
+	"github.com/hpkotak/shellbud/internal/safety"
+	"github.com/hpkotak/shellbud/internal/shellhook"
+	"github.com/spf13/cobra"
+)
+
+var fixCmd = &cobra.Command{
+	Use:   "fix [command]",
+	Short: "Diagnose the last failed command and suggest a fix",
+	Long: `Diagnose a failed command and suggest a corrected one.
+
+With no arguments, sb fix uses the last command recorded by the shell hook.
+The hook is part of 'sb init' (see 'sb init --help'); to install only the
+hook, add one line to your shell's rc file:
+
+  eval "$(sb fix --hook bash)"        # ~/.bashrc
+  eval "$(sb fix --hook zsh)"         # ~/.zshrc
+  sb fix --hook fish | source         # ~/.config/fish/config.fish
+
+Without the hook, pass the command: sb fix git pus origin main
+
+The hook records the command and its exit status, not its output. It keeps
+them in shell variables that only sb fix receives. sb fix offers to re-run
+the command to capture the output (never for destructive commands).
+Suggested fixes go through the usual confirmation flow.`,
+	Args: cobra.ArbitraryArgs,
+	RunE: runFix,
+}
+
+var hookFlag string
+
+// runCapture re-runs a command to capture its output. Tests override it.
+var runCapture = executor.RunCapture
+
+func init() {
+	fixCmd.Flags().StringVar(&hookFlag, "hook", "", "print the shell hook for bash, zsh or fish")
+	rootCmd.AddCommand(fixCmd)
+}
+
+func runFix(_ *cobra.Command, args []string) error {
+	if hookFlag != "" {
+		script, err := shellhook.Script(hookFlag)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprint(ioOut, script)
+		return nil
+	}
+
+	policy, err := approvalPolicy()
+	if err != nil {
+		return err
+	}
+	last, err := lastCommand(args)
+	if err != nil {
+		return err
+	}
+	if last.ExitCode == 0 {
+		_, _ = fmt.Fprintf(ioOut, "The last command (%s) succeeded; nothing to fix.\n", last.Command)
+		return nil
+	}
+
+	p, model, err := readyProvider()
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(ioOut, "\n  $ %s", last.Command)
+	if last.ExitCode > 0 {
+		_, _ = fmt.Fprintf(ioOut, "  (exit status %d)", last.ExitCode)
+	}
+	_, _ = fmt.Fprintln(ioOut)
+
+	output := ""
+	if level := safety.Classify(last.Command); level >= safety.Destructive {
+		_, _ = fmt.Fprintf(ioOut, "  Not offering to re-run: this is a %s command.\n", level)
+	} else if rerun(last.Command, policy) {
+		_, _ = fmt.Fprintln(ioOut)
+		out, code, err := runCapture(last.Command)
+		if err != nil {
+			return fmt.Errorf("re-running command: %w", err)
+		}
+		output, last.ExitCode = out, code
+	}
+
+	return ask(p, model, prompt.FixRequest(last.Command, last.ExitCode, output), false, policy)
+}
+
+// rerun reports whether to re-run the failed command to capture its output:
+// the user is asked unless policy decides without asking.
+func rerun(command string, policy approval.Policy) bool {
+	switch action, _ := policy.Decide(command); action {
+	case approval.Prompt:
+		return executor.Confirm("  Re-run it to capture its output?", false, ioIn, ioOut)
+	case approval.Run:
+		return true
+	}
+	return false
+}
+
+// lastCommand returns the command to fix: the arguments when given, else
+// the one recorded by the shell hook.
+func lastCommand(args []string) (shellhook.LastCommand, error) {
+	if len(args) > 0 {
+		return shellhook.LastCommand{Command: strings.Join(args, " "), ExitCode: -1}, nil
+	}
+	last, ok := shellhook.Last()
+	if !ok {
+		return shellhook.LastCommand{}, fmt.Errorf("no previous command recorded. Install the shell hook (see 'sb fix --help') or pass the command: sb fix <command>")
+	}
+	if fields := strings.Fields(last.Command); len(fields) >= 2 && fields[0] == "sb" && fields[1] == "fix" {
+		return shellhook.LastCommand{}, fmt.Errorf("the last command was 'sb fix' itself; run the failing command again first")
+	}
+	return last, nil
+}
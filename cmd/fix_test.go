@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/shellhook"
+)
+
+func TestRunFix(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		hookCommand string
+		hookStatus  string
+		input       string // re-run answer, then fix confirmation
+		captureErr  error
+		setPolicy   func()
+		wantErr     string
+		wantOut     []string
+		wantInMsg   []string
+		notInMsg    []string
+		wantRerun   bool
+		wantRun     string
+	}{
+		{
+			name:        "hook command, re-run and apply fix",
+			hookCommand: "git pus origin main",
+			hookStatus:  "1",
+			input:       "y\ny\n",
+			wantOut:     []string{"$ git pus origin main  (exit status 1)", "> git push origin main"},
+			wantInMsg:   []string{"Command: `git pus origin main`", "Exit status: 1", "<command_output>\ngit: 'pus' is not a git command.\n</command_output>"},
+			wantRerun:   true,
+			wantRun:     "git push origin main",
+		},
+		{
+			name:        "hook command without re-run",
+			hookCommand: "git pus origin main",
+			hookStatus:  "1",
+			input:       "n\nn\n",
+			wantInMsg:   []string{"Exit status: 1"},
+			notInMsg:    []string{"<command_output>"},
+			wantOut:     []string{"Skipped"},
+		},
+		{
+			name:        "yes re-runs and applies the fix without asking",
+			hookCommand: "git pus origin main",
+			hookStatus:  "1",
+			setPolicy:   func() { yesFlag = true },
+			wantOut:     []string{"Running (--yes)."},
+			wantInMsg:   []string{"<command_output>"},
+			wantRerun:   true,
+			wantRun:     "git push origin main",
+		},
+		{
+			name:        "dry-run neither re-runs nor applies",
+			hookCommand: "git pus origin main",
+			hookStatus:  "1",
+			setPolicy:   func() { dryRunFlag = true },
+			wantOut:     []string{"Not run (dry run)."},
+			notInMsg:    []string{"<command_output>"},
+		},
+		{
+			name:       "command from args has unknown status",
+			args:       []string{"git", "pus"},
+			input:      "\nn\n",
+			wantInMsg:  []string{"Command: `git pus`"},
+			notInMsg:   []string{"Exit status"},
+			hookStatus: "",
+		},
+		{
+			name:        "destructive command is never re-run",
+			hookCommand: "rm -rf build/",
+			hookStatus:  "1",
+			input:       "n\n",
+			wantOut:     []string{"Not offering to re-run"},
+			wantInMsg:   []string{"Command: `rm -rf build/`"},
+		},
+		{
+			name:        "successful last command",
+			hookCommand: "ls",
+			hookStatus:  "0",
+			wantOut:     []string{"succeeded; nothing to fix"},
+		},
+		{
+			name:    "nothing recorded",
+			wantErr: "no previous command recorded",
+		},
+		{
+			name:        "last command was sb fix",
+			hookCommand: "sb fix",
+			hookStatus:  "1",
+			wantErr:     "'sb fix' itself",
+		},
+		{
+			name:        "re-run fails",
+			hookCommand: "make",
+			hookStatus:  "2",
+			input:       "y\n",
+			captureErr:  errors.New("no shell"),
+			wantErr:     "re-running command",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveCmdVars(t)
+			defer restore()
+			setupTestConfig(t, config.Default())
+			t.Setenv(shellhook.EnvLastCommand, tt.hookCommand)
+			t.Setenv(shellhook.EnvLastStatus, tt.hookStatus)
+
+			p := &capturingProvider{mockProvider: mockProvider{chatResult: `{"text":"Typo in push.","commands":["git push origin main"]}`}}
+			newProvider = func(*config.Config, string) (provider.Provider, error) { return p, nil }
+			reran := false
+			runCapture = func(command string) (string, int, error) {
+				reran = true
+				return "git: 'pus' is not a git command.\n", 1, tt.captureErr
+			}
+			var ran string
+			runCommand = func(command string) error {
+				ran = command
+				return nil
+			}
+			// One byte at a time, so each confirmation reads only its line.
+			ioIn = iotest.OneByteReader(strings.NewReader(tt.input))
+			out := &bytes.Buffer{}
+			ioOut = out
+			if tt.setPolicy != nil {
+				tt.setPolicy()
+			}
+
+			err := runFix(fixCmd, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runFix() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runFix() error: %v", err)
+			}
+
+			for _, want := range tt.wantOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			var user string
+			if len(p.messages) == 2 {
+				user = p.messages[1].Content
+			}
+			for _, want := range tt.wantInMsg {
+				if !strings.Contains(user, want) {
+					t.Errorf("request missing %q:\n%s", want, user)
+				}
+			}
+			for _, unwanted := range tt.notInMsg {
+				if strings.Contains(user, unwanted) {
+					t.Errorf("request should not contain %q:\n%s", unwanted, user)
+				}
+			}
+			if reran != tt.wantRerun {
+				t.Errorf("re-ran = %v, want %v", reran, tt.wantRerun)
+			}
+			if ran != tt.wantRun {
+				t.Errorf("ran = %q, want %q", ran, tt.wantRun)
+			}
+		})
+	}
+}
+
+func TestRunFixHook(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+
+	out := &bytes.Buffer{}
+	ioOut = out
+	hookFlag = "zsh"
+	if err := runFix(fixCmd, nil); err != nil {
+		t.Fatalf("runFix(--hook zsh) error: %v", err)
+	}
+	if !strings.Contains(out.String(), "precmd_functions") {
+		t.Errorf("zsh hook output = %q", out.String())
+	}
+
+	hookFlag = "tcsh"
+	if err := runFix(fixCmd, nil); err == nil || !strings.Contains(err.Error(), "unsupported shell") {
+		t.Errorf("runFix(--hook tcsh) error = %v, want unsupported shell", err)
+	}
+}
+
+func TestRunFixNoConfig(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	t.Setenv("HOME", t.TempDir())
+	ioOut = &bytes.Buffer{}
+
+	if err := runFix(fixCmd, []string{"make"}); err == nil || !strings.Contains(err.Error(), "sb setup") {
+		t.Errorf("runFix() error = %v, want setup hint", err)
+	}
+}
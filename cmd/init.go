@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hpkotak/shellbud/internal/shellhook"
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init bash|zsh|fish",
+	Short: "Print shell integration (Ctrl-G widget and sb fix hook)",
+	Long: `Print shell integration code. Add one line to your shell's rc file:
+
+  eval "$(sb init bash)"        # ~/.bashrc
+  eval "$(sb init zsh)"         # ~/.zshrc
+  sb init fish | source         # ~/.config/fish/config.fish
+
+Then type a request on the command line and press Ctrl-G. sb suggests
+commands and the one you pick replaces the command line, so you can edit it
+and run it yourself; it lands in your shell history like any other command.
+The integration also records the last command for 'sb fix'.
+
+The widget runs 'sb --print-command', which writes only the chosen command
+to stdout and everything else to stderr.`,
+	ValidArgs: shellhook.Shells,
+	Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	RunE:      runInit,
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+}
+
+func runInit(_ *cobra.Command, args []string) error {
+	script, err := shellhook.Init(args[0])
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprint(ioOut, script)
+	return nil
+}
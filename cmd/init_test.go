@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunInit(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+
+	tests := []struct {
+		shell string
+		want  string
+	}{
+		{"bash", `bind -x '"\C-g": __sb_widget'`},
+		{"zsh", "bindkey '^G' __sb_widget"},
+		{"fish", `bind \cg __sb_widget`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.shell, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			ioOut = out
+			if err := runInit(initCmd, []string{tt.shell}); err != nil {
+				t.Fatalf("runInit(%s): %v", tt.shell, err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("runInit(%s) output missing %q:\n%s", tt.shell, tt.want, out.String())
+			}
+		})
+	}
+
+	if err := runInit(initCmd, []string{"tcsh"}); err == nil {
+		t.Error("runInit(tcsh) should fail")
+	}
+}
+
+func TestInitArgs(t *testing.T) {
+	for _, args := range [][]string{nil, {"tcsh"}, {"bash", "zsh"}} {
+		if err := initCmd.Args(initCmd, args); err == nil {
+			t.Errorf("initCmd.Args(%q) should fail", args)
+		}
+	}
+	if err := initCmd.Args(initCmd, []string{"zsh"}); err != nil {
+		t.Errorf("initCmd.Args(zsh): %v", err)
+	}
+}
@@ -1,24 +1,33 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/hpkotak/shellbud/internal/approval"
 	"github.com/hpkotak/shellbud/internal/config"
 	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/output"
 	"github.com/hpkotak/shellbud/internal/prompt"
 	"github.com/hpkotak/shellbud/internal/provider"
 	"github.com/hpkotak/shellbud/internal/safety"
 	"github.com/hpkotak/shellbud/internal/shellenv"
+	"github.com/hpkotak/shellbud/internal/shellhook"
 	"github.com/spf13/cobra"
 )
 
-var modelFlag string
+var (
+	modelFlag        string
+	printCommandFlag bool
+	outputFlag       string
+)
 
 // Package-level function variables for testability.
 // Tests override these to avoid real provider/executor calls.
@@ -27,6 +36,7 @@ var (
 	runCommand            = executor.Run
 	ioIn        io.Reader = os.Stdin
 	ioOut       io.Writer = os.Stdout
+	ioErr       io.Writer = os.Stderr
 )
 
 var rootCmd = &cobra.Command{
@@ -40,14 +50,24 @@ Examples:
   sb find all files larger than 100MB
   sb compress this folder as tar.gz
 
-For interactive sessions: sb chat`,
+For interactive sessions: sb chat
+For a Ctrl-G shell widget: sb init bash|zsh|fish`,
 	Args:              cobra.ArbitraryArgs,
 	RunE:              runTranslate,
 	DisableAutoGenTag: true,
 }
 
 func init() {
+	// The shell hook passes the last command line whenever an argument is
+	// "fix"; only sb fix may keep it.
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, _ []string) {
+		if cmd != fixCmd {
+			shellhook.Forget()
+		}
+	}
 	rootCmd.PersistentFlags().StringVar(&modelFlag, "model", "", "override model for this query")
+	rootCmd.Flags().StringVarP(&outputFlag, "output", "o", string(output.Text), "output format: text, json or ndjson (machine-readable; never runs commands)")
+	rootCmd.Flags().BoolVar(&printCommandFlag, "print-command", false, "print only the chosen command to stdout instead of running it (for shell integration)")
 }
 
 func Execute() error {
@@ -55,13 +75,42 @@ func Execute() error {
 }
 
 func createProvider(cfg *config.Config, model string) (provider.Provider, error) {
+	chain := cfg.Chain()
+	if len(chain) == 1 && chain[0].Model == "" {
+		return buildProvider(cfg, chain[0].Name, model)
+	}
+
+	members := make([]provider.FallbackMember, 0, len(chain))
+	for _, ref := range chain {
+		p, err := buildProvider(cfg, ref.Name, chainModel(ref, model))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref.Name, err)
+		}
+		members = append(members, provider.FallbackMember{Provider: p, Model: ref.Model})
+	}
+	return provider.NewFallback(members...)
+}
+
+func chainModel(ref config.ProviderRef, model string) string {
+	if ref.Model != "" {
+		return ref.Model
+	}
+	return model
+}
+
+func buildProvider(cfg *config.Config, name, model string) (provider.Provider, error) {
 	return provider.NewFromConfig(provider.BuildConfig{
-		Name:         cfg.Provider,
+		Name:         name,
 		Model:        model,
 		OllamaHost:   cfg.Ollama.Host,
 		OpenAIHost:   cfg.OpenAI.Host,
 		OpenAIAPIKey: os.Getenv("OPENAI_API_KEY"),
 		AFMCommand:   cfg.AFM.Command,
+
+		AnthropicHost:   cfg.Anthropic.Host,
+		AnthropicAPIKey: os.Getenv("ANTHROPIC_API_KEY"),
+		GeminiHost:      cfg.Gemini.Host,
+		GeminiAPIKey:    os.Getenv("GEMINI_API_KEY"),
 	})
 }
 
@@ -70,12 +119,71 @@ func runTranslate(cmd *cobra.Command, args []string) error {
 		return cmd.Help()
 	}
 
+	format, err := output.ParseFormat(outputFlag)
+	if err != nil {
+		return err
+	}
+	policy, err := approvalPolicy()
+	if err != nil {
+		return err
+	}
+	if printCommandFlag && policy != approval.Ask {
+		return fmt.Errorf("--print-command cannot be combined with --%s", policy)
+	}
+	if format == output.Text {
+		return translate(args, nil, policy)
+	}
+	if printCommandFlag {
+		return fmt.Errorf("--print-command cannot be combined with --output %s", format)
+	}
+	if policy == approval.YesSafe || policy == approval.Yes {
+		return fmt.Errorf("--%s cannot be combined with --output %s, which never runs commands", policy, format)
+	}
+	// Failures are reported as a document too, so scripts need only parse
+	// stdout.
+	enc := output.NewEncoder(ioOut, format)
+	if err := translate(args, enc, policy); err != nil {
+		_ = enc.Error(err)
+		return err
+	}
+	return nil
+}
+
+// translate answers the query in args and offers its commands under policy.
+// With enc set, the answer is written as a machine-readable document and
+// nothing is run.
+func translate(args []string, enc *output.Encoder, policy approval.Policy) error {
+	p, model, err := readyProvider()
+	if err != nil {
+		return err
+	}
+
+	query := strings.Join(args, " ")
+	input, piped, err := readPipedInput(ioIn)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(input.text) != "" {
+		query = prompt.WithPipedInput(query, input.text, input.truncated)
+	}
+	if enc != nil {
+		return askDocument(p, model, query, enc)
+	}
+	return ask(p, model, query, piped, policy)
+}
+
+// readyProvider loads the config and the safety rules and returns the
+// provider, checked to be available, and the model to use.
+func readyProvider() (provider.Provider, string, error) {
 	cfg, err := config.Load()
 	if err != nil {
 		if errors.Is(err, config.ErrNotFound) {
-			return fmt.Errorf("no config found. Run 'sb setup' to get started")
+			return nil, "", fmt.Errorf("no config found. Run 'sb setup' to get started")
 		}
-		return fmt.Errorf("loading config: %w", err)
+		return nil, "", fmt.Errorf("loading config: %w", err)
+	}
+	if err := loadSafetyPolicy(cfg); err != nil {
+		return nil, "", err
 	}
 
 	model := cfg.Model
@@ -85,67 +193,124 @@ func runTranslate(cmd *cobra.Command, args []string) error {
 
 	p, err := newProvider(cfg, model)
 	if err != nil {
-		return fmt.Errorf("creating provider: %w", err)
+		return nil, "", fmt.Errorf("creating provider: %w", err)
 	}
 
 	checkCtx, checkCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer checkCancel()
 	if err := p.Available(checkCtx); err != nil {
-		return fmt.Errorf("provider not ready: %w\n\nRun 'sb setup' to reconfigure", err)
+		return nil, "", fmt.Errorf("provider not ready: %w\n\nRun 'sb setup' to reconfigure", err)
 	}
+	return p, model, nil
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
-	query := strings.Join(args, " ")
+// oneShotMessages builds the request for a one-shot query, with the current
+// environment in the system prompt.
+func oneShotMessages(query string) []provider.Message {
 	envSnap := shellenv.Gather()
-
-	messages := []provider.Message{
+	return []provider.Message{
 		{Role: "system", Content: prompt.ChatSystemPrompt(envSnap.Format())},
 		{Role: "user", Content: query},
 	}
+}
+
+// askDocument sends query and writes the response with enc. Commands are
+// classified but never run, and nothing is read from the terminal.
+func askDocument(p provider.Provider, model, query string, enc *output.Encoder) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
 
 	resp, err := p.Chat(ctx, provider.ChatRequest{
+		Messages:   oneShotMessages(query),
+		Model:      model,
+		ExpectJSON: true,
+	})
+	if err != nil {
+		return fmt.Errorf("query failed: %w", err)
+	}
+	return enc.Result(output.NewResult(p.Name(), model, resp, prompt.ParseChatResponse(resp.Text)))
+}
+
+// ask sends query with the environment context, shows the answer, and offers
+// to run the commands in it as policy allows. piped means stdin is taken, so
+// confirmations are read from the terminal.
+func ask(p provider.Provider, model, query string, piped bool, policy approval.Policy) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	// With --print-command, stdout carries only the chosen command.
+	out := ioOut
+	if printCommandFlag {
+		out = ioErr
+	}
+
+	echoPolicy(policy, out)
+	messages := oneShotMessages(query)
+
+	// Render the text field as it streams in; commands are only extracted
+	// below, once the complete response has been parsed and validated.
+	var textStream prompt.TextStream
+	streamed := false
+	resp, err := provider.StreamChat(ctx, p, provider.ChatRequest{
 		Messages:   messages,
 		Model:      model,
 		ExpectJSON: true,
+	}, func(delta string) {
+		text := textStream.Feed(delta)
+		if text == "" {
+			return
+		}
+		if !streamed {
+			_, _ = fmt.Fprintln(out)
+			streamed = true
+		}
+		_, _ = fmt.Fprint(out, text)
 	})
+	if streamed {
+		_, _ = fmt.Fprintln(out)
+	}
 	if err != nil {
 		return fmt.Errorf("query failed: %w", err)
 	}
 
 	if resp.Warning != "" {
-		_, _ = fmt.Fprintf(ioOut, "\n  Note: %s\n", resp.Warning)
+		_, _ = fmt.Fprintf(out, "\n  Note: %s\n", resp.Warning)
 	}
 
 	parsed := prompt.ParseChatResponse(resp.Text)
 
-	// Display the full response text.
-	_, _ = fmt.Fprintf(ioOut, "\n%s\n", parsed.Text)
+	// Display the full response text unless it was already streamed.
+	if !streamed {
+		_, _ = fmt.Fprintf(out, "\n%s\n", parsed.Text)
+	}
 	if !parsed.Structured {
-		_, _ = fmt.Fprintln(ioOut, "  Note: model response was not valid structured output; no commands were run.")
+		_, _ = fmt.Fprintln(out, "  Note: model response was not valid structured output; no commands were run.")
+	}
+
+	if len(parsed.Commands) == 0 {
+		return nil
+	}
+	if policy != approval.Ask {
+		return runWithPolicy(parsed.Commands, policy, out)
+	}
+	confirmIn, closeConfirm, ok := confirmInput(piped)
+	defer closeConfirm()
+	if !ok {
+		_, _ = fmt.Fprintln(out, "  Note: stdin is piped and no terminal is available to confirm commands; they will be skipped.")
+	}
+	if printCommandFlag {
+		return printCommand(parsed.Commands, confirmIn, out)
 	}
 
 	// If commands were extracted, offer to run each one.
 	for _, command := range parsed.Commands {
-		_, _ = fmt.Fprintf(ioOut, "\n  > %s\n\n", command)
-
-		level := safety.Classify(command)
-
-		var confirmed bool
-		if level == safety.Destructive {
-			_, _ = fmt.Fprintln(ioOut, "  Warning: this is a destructive command.")
-			confirmed = executor.Confirm("  Are you sure?", false, ioIn, ioOut)
-		} else {
-			confirmed = executor.Confirm("  Run this?", true, ioIn, ioOut)
-		}
-
+		command, confirmed := confirmCommand(command, confirmIn, out)
 		if !confirmed {
-			_, _ = fmt.Fprintln(ioOut, "  Skipped.")
+			_, _ = fmt.Fprintln(out, "  Skipped.")
 			continue
 		}
 
-		_, _ = fmt.Fprintln(ioOut)
+		_, _ = fmt.Fprintln(out)
 		if err := runCommand(command); err != nil {
 			return err
 		}
@@ -153,3 +318,109 @@ func runTranslate(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// confirmCommand asks whether to run command, offering to modify it first.
+// An edited command is classified afresh, so an edit that makes it
+// destructive needs the destructive confirmation. A forbidden command is
+// refused unless --allow-forbidden is set. It returns the command to
+// run and whether the user confirmed it.
+func confirmCommand(command string, in io.Reader, out io.Writer) (string, bool) {
+	for {
+		_, _ = fmt.Fprintf(out, "\n  > %s\n\n", command)
+
+		v := safety.Evaluate(command)
+		if note := v.Warning(); note != "" {
+			_, _ = fmt.Fprintf(out, "  %s\n", note)
+		}
+		for _, seg := range v.Flagged() {
+			_, _ = fmt.Fprintf(out, "    %s\n", seg)
+		}
+
+		var answer executor.Answer
+		switch {
+		case v.Level == safety.Forbidden && !allowForbiddenFlag:
+			_, _ = fmt.Fprintln(out, "  Forbidden commands are only offered with --allow-forbidden.")
+			return command, false
+		case v.Level >= safety.Destructive:
+			answer = executor.ConfirmEdit("  Are you sure?", false, in, out)
+		default:
+			answer = executor.ConfirmEdit("  Run this?", true, in, out)
+		}
+
+		if answer != executor.Modify {
+			return command, answer == executor.Yes
+		}
+		command = editCommand(command, in, out)
+	}
+}
+
+// runWithPolicy applies a non-interactive policy to commands: each is run,
+// shown, or refused without asking. Refusals are reported as an error so
+// scripts using --yes or --yes-safe can tell that something was not run.
+func runWithPolicy(commands []string, policy approval.Policy, out io.Writer) error {
+	refused := 0
+	for _, command := range commands {
+		_, _ = fmt.Fprintf(out, "\n  > %s\n\n", command)
+
+		action, reason := policy.Decide(command)
+		switch action {
+		case approval.Show:
+			_, _ = fmt.Fprintf(out, "  Not run (%s).\n", reason)
+		case approval.Refuse:
+			_, _ = fmt.Fprintf(out, "  Refused: %s.\n", reason)
+			refused++
+		default:
+			_, _ = fmt.Fprintf(out, "  Running (%s).\n\n", reason)
+			if err := runCommand(command); err != nil {
+				return err
+			}
+		}
+	}
+	if refused > 0 {
+		return fmt.Errorf("%d command(s) refused by the %s policy", refused, policy)
+	}
+	return nil
+}
+
+// printCommand lets the user choose one of commands and writes only that
+// command to stdout, for shell integrations that put it on the command line
+// instead of running it. Prompts go to out (stderr).
+func printCommand(commands []string, in io.Reader, out io.Writer) error {
+	for i, command := range commands {
+		_, _ = fmt.Fprintf(out, "\n  %d) %s\n", i+1, command)
+		v := safety.Evaluate(command)
+		if note := v.Warning(); note != "" {
+			_, _ = fmt.Fprintf(out, "     %s\n", note)
+		}
+		for _, seg := range v.Flagged() {
+			_, _ = fmt.Fprintf(out, "       %s\n", seg)
+		}
+	}
+	_, _ = fmt.Fprintln(out)
+
+	choice := 0
+	if len(commands) == 1 {
+		if !executor.Confirm("  Insert this command?", true, in, out) {
+			_, _ = fmt.Fprintln(out, "  Nothing inserted.")
+			return nil
+		}
+	} else {
+		_, _ = fmt.Fprintf(out, "  Insert which command? [1-%d, Enter for 1]: ", len(commands))
+		scanner := bufio.NewScanner(in)
+		answer := ""
+		if scanner.Scan() {
+			answer = strings.TrimSpace(scanner.Text())
+		}
+		n, err := strconv.Atoi(answer)
+		switch {
+		case answer == "":
+		case err == nil && n >= 1 && n <= len(commands):
+			choice = n - 1
+		default:
+			_, _ = fmt.Fprintln(out, "  Nothing inserted.")
+			return nil
+		}
+	}
+	_, _ = fmt.Fprintln(ioOut, commands[choice])
+	return nil
+}
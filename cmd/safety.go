@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/safety"
+	"github.com/spf13/cobra"
+)
+
+var safetyCmd = &cobra.Command{
+	Use:   "safety",
+	Short: "Inspect the rules that classify commands",
+	Long: `ShellBud flags destructive commands with built-in rules, extended by
+~/.shellbud/safety.yaml and by .shellbud/safety.yaml in the current
+repository (or a parent directory):
+
+  rules:
+    - name: pulumi-destroy
+      pattern: '\bpulumi\s+destroy\b'
+      exclude: '--preview-only'    # optional
+      level: destructive           # safe, caution, destructive (default), network or forbidden
+      reason: destroys managed infrastructure
+
+A rule in ~/.shellbud/safety.yaml named after a built-in rule overrides the
+fields it sets; 'level: safe' un-flags it. A repository's file can only add
+rules.
+
+The built-in rules for git, container, kubernetes, cloud and database tools
+come in families that can be turned off one at a time:
+
+  sb config set safety_families.git false`,
+}
+
+var safetyTestCmd = &cobra.Command{
+	Use:   "test <command>",
+	Short: "Show how a command is classified and which rules match",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSafetyTest,
+}
+
+func init() {
+	safetyCmd.AddCommand(safetyTestCmd)
+	rootCmd.AddCommand(safetyCmd)
+}
+
+func runSafetyTest(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		if !errors.Is(err, config.ErrNotFound) {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		cfg = config.Default()
+	}
+	if err := loadSafetyPolicy(cfg); err != nil {
+		return err
+	}
+	command := args[0]
+
+	v := safety.Evaluate(command)
+	_, _ = fmt.Fprintf(ioOut, "%s: %s\n", command, v.Level)
+	if v.Level == safety.Forbidden {
+		_, _ = fmt.Fprintln(ioOut, "Only offered with --allow-forbidden, and never run automatically.")
+	}
+	matched := v.Matched
+	if len(matched) == 0 {
+		_, _ = fmt.Fprintln(ioOut, "No rules matched.")
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(ioOut, "\nMatched rules:")
+	w := tabwriter.NewWriter(ioOut, 0, 0, 2, ' ', 0)
+	for _, r := range matched {
+		source := displayPath(r.Source)
+		if r.Family != "" {
+			source += ", " + r.Family + " family"
+		}
+		_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t(%s)\n", r.Name, r.Level, r.Reason, source)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if v.Segments == nil {
+		return nil
+	}
+
+	_, _ = fmt.Fprintln(ioOut, "\nSegments:")
+	w = tabwriter.NewWriter(ioOut, 0, 0, 2, ' ', 0)
+	for _, seg := range v.Segments {
+		if reasons := seg.Reasons(); len(reasons) > 0 {
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\n", seg.Text, seg.Level, strings.Join(reasons, "; "))
+		} else {
+			_, _ = fmt.Fprintf(w, "  %s\t%s\n", seg.Text, seg.Level)
+		}
+	}
+	return w.Flush()
+}
+
+// loadSafetyPolicy puts the user's safety file, and the one of the
+// repository sb runs in, into effect for this process, without the rule
+// families turned off in cfg.
+func loadSafetyPolicy(cfg *config.Config) error {
+	userPath := filepath.Join(config.Dir(), safety.FileName)
+	var repoPath string
+	if cwd, err := os.Getwd(); err == nil {
+		repoPath = safety.RepoFile(cwd, userPath)
+	}
+	p, err := safety.LoadPolicy(userPath, repoPath, cfg.DisabledSafetyFamilies()...)
+	if err != nil {
+		return fmt.Errorf("loading safety rules: %w", err)
+	}
+	safety.Use(p)
+	return nil
+}
+
+// displayPath shortens paths under the home directory to ~/...
+func displayPath(path string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return path
+	}
+	if rest, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return filepath.Join("~", rest)
+	}
+	return path
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/approval"
+	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/safety"
+)
+
+// writeSafetyFile writes a safety file at dir/.shellbud/safety.yaml.
+func writeSafetyFile(t *testing.T, dir, content string) {
+	t.Helper()
+	path := filepath.Join(dir, ".shellbud", safety.FileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write safety file: %v", err)
+	}
+}
+
+func TestRunSafetyTest(t *testing.T) {
+	const userRules = `rules:
+  - name: pulumi-destroy
+    pattern: '\bpulumi\s+destroy\b'
+    reason: destroys managed infrastructure
+  - name: killall
+    level: safe
+`
+	const repoRules = `rules:
+  - name: deploy-prod
+    pattern: '\bmake\s+deploy-prod\b'
+    reason: deploys to production
+`
+	tests := []struct {
+		name    string
+		config  string
+		user    string
+		repo    string
+		command string
+		want    []string
+		notWant []string
+		wantErr string
+	}{
+		{
+			name:    "built-in rule",
+			command: "sudo rm -rf build",
+			want:    []string{"sudo rm -rf build: destructive", "sudo          destructive  runs with root privileges  (built-in)", "rm-recursive  destructive  recursive delete"},
+			notWant: []string{"--allow-forbidden", "Segments:"},
+		},
+		{
+			name:    "segments",
+			command: "cd build && rm -rf out | tee log",
+			want:    []string{": destructive", "Segments:\n  cd build    safe\n  rm -rf out  destructive  deletes files; recursive delete\n  tee log     safe\n"},
+		},
+		{
+			name:    "rule family",
+			command: "git reset --hard HEAD~1",
+			want:    []string{"git-reset-hard  destructive  discards uncommitted changes  (built-in, git family)"},
+		},
+		{
+			name:    "rule family turned off",
+			config:  "safety_families:\n  git: false\n",
+			command: "git reset --hard HEAD~1",
+			want:    []string{"git reset --hard HEAD~1: safe", "No rules matched."},
+		},
+		{
+			name:    "unknown rule family",
+			config:  "safety_families:\n  gti: false\n",
+			command: "ls",
+			wantErr: `unknown rule family "gti"`,
+		},
+		{
+			name:    "broken config",
+			config:  "safety_families: [",
+			command: "ls",
+			wantErr: "loading config",
+		},
+		{
+			name:    "no rules",
+			command: "ls -la",
+			want:    []string{"ls -la: safe", "No rules matched."},
+		},
+		{
+			name:    "user rule",
+			user:    userRules,
+			command: "pulumi destroy",
+			want:    []string{"pulumi destroy: destructive", "pulumi-destroy  destructive  destroys managed infrastructure  (~/.shellbud/safety.yaml)"},
+		},
+		{
+			name:    "un-flagged built-in",
+			user:    userRules,
+			command: "killall node",
+			want:    []string{"killall node: safe", "killall  safe  kills processes by name"},
+		},
+		{
+			name:    "repo rule",
+			user:    userRules,
+			repo:    repoRules,
+			command: "make deploy-prod",
+			want:    []string{"make deploy-prod: destructive", "deploy-prod  destructive  deploys to production  (/", filepath.Join("repo", ".shellbud", safety.FileName) + ")"},
+		},
+		{
+			name:    "forbidden",
+			command: "rm -rf /",
+			want:    []string{"rm -rf /: forbidden", "Only offered with --allow-forbidden", "rm-root       forbidden    deletes the root or home directory"},
+		},
+		{
+			name:    "invalid user file",
+			user:    "rules:\n  - name: broken\n    pattern: '('\n    reason: x\n",
+			command: "ls",
+			wantErr: `loading safety rules: `,
+		},
+		{
+			name:    "repo file cannot un-flag",
+			repo:    "rules:\n  - name: rm\n    level: safe\n",
+			command: "rm x",
+			wantErr: "can only add rules",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveCmdVars(t)
+			defer restore()
+
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if tt.config != "" {
+				if err := os.MkdirAll(config.Dir(), 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(config.Path(), []byte(tt.config), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.user != "" {
+				writeSafetyFile(t, home, tt.user)
+			}
+			repo := filepath.Join(t.TempDir(), "repo")
+			if err := os.MkdirAll(repo, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.repo != "" {
+				writeSafetyFile(t, repo, tt.repo)
+			}
+			t.Chdir(repo)
+			out := &bytes.Buffer{}
+			ioOut = out
+
+			err := runSafetyTest(safetyTestCmd, []string{tt.command})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("runSafetyTest() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runSafetyTest() error: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("output should not contain %q:\n%s", notWant, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestReadyProviderLoadsSafetyRules(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+
+	setupTestConfig(t, config.Default())
+	home, _ := os.UserHomeDir()
+	t.Chdir(t.TempDir())
+	newProvider = func(cfg *config.Config, model string) (provider.Provider, error) {
+		return &mockProvider{}, nil
+	}
+
+	writeSafetyFile(t, home, "rules:\n  - name: make-deploy\n    pattern: '^make deploy$'\n    reason: ships to production\n")
+	if _, _, err := readyProvider(); err != nil {
+		t.Fatalf("readyProvider() error: %v", err)
+	}
+	if safety.Classify("make deploy") != safety.Destructive {
+		t.Error("a rule from ~/.shellbud/safety.yaml should be in effect")
+	}
+
+	writeSafetyFile(t, home, "rules: [")
+	if _, _, err := readyProvider(); err == nil || !strings.Contains(err.Error(), "loading safety rules") {
+		t.Errorf("readyProvider() error = %v, want safety file error", err)
+	}
+	if err := chat(nil, false, approval.Ask); err == nil || !strings.Contains(err.Error(), "loading safety rules") {
+		t.Errorf("chat() error = %v, want safety file error", err)
+	}
+}
+
+func TestDisplayPath(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/home/test/.shellbud/safety.yaml", filepath.Join("~", ".shellbud", "safety.yaml")},
+		{"/srv/repo/.shellbud/safety.yaml", "/srv/repo/.shellbud/safety.yaml"},
+		{"/home/tester/x", "/home/tester/x"},
+		{"built-in", "built-in"},
+	}
+	for _, tt := range tests {
+		if got := displayPath(tt.path); got != tt.want {
+			t.Errorf("displayPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hpkotak/shellbud/internal/sessions"
+	"github.com/spf13/cobra"
+)
+
+// sessionTitleLen bounds the first-question preview in the session list.
+const sessionTitleLen = 48
+
+var sessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved chat sessions, most recent first",
+	Args:  cobra.NoArgs,
+	RunE:  runSessionsList,
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsListCmd)
+}
+
+func runSessionsList(cmd *cobra.Command, args []string) error {
+	list, err := sessions.NewStore(sessions.Dir()).List()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		_, _ = fmt.Fprintln(ioOut, "No saved sessions. Start one with 'sb chat'.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(ioOut, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tUPDATED\tMODEL\tMESSAGES\tFIRST QUESTION")
+	for _, s := range list {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n",
+			s.ID, s.UpdatedAt.Local().Format("2006-01-02 15:04"), s.Model, len(s.Messages), sessionTitle(s))
+	}
+	return w.Flush()
+}
+
+// sessionTitle previews the session's first question on one line.
+func sessionTitle(s *sessions.Session) string {
+	for _, m := range s.Messages {
+		if m.Role != "user" {
+			continue
+		}
+		title := strings.Join(strings.Fields(m.Content), " ")
+		if len([]rune(title)) > sessionTitleLen {
+			title = string([]rune(title)[:sessionTitleLen-3]) + "..."
+		}
+		return title
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/sessions"
+)
+
+// seedSession saves a session with the given messages under the test HOME.
+func seedSession(t *testing.T, msgs ...provider.Message) *sessions.Session {
+	t.Helper()
+	h, err := sessions.NewStore(sessions.Dir()).Create("ollama", "llama3.2:latest", "/tmp/project")
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	h.Session.SetHistory(msgs)
+	if err := h.Save(); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	return h.Session
+}
+
+func TestRunSessionsList(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	t.Setenv("HOME", t.TempDir())
+
+	out := &bytes.Buffer{}
+	ioOut = out
+	if err := runSessionsList(nil, nil); err != nil {
+		t.Fatalf("runSessionsList(): %v", err)
+	}
+	if !strings.Contains(out.String(), "No saved sessions") {
+		t.Errorf("empty list output = %q", out.String())
+	}
+
+	long := "how do I find every file larger than one hundred megabytes under my home directory"
+	s := seedSession(t,
+		provider.Message{Role: "user", Content: long},
+		provider.Message{Role: "assistant", Content: `{"text":"Use find.","commands":[]}`},
+	)
+	seedSession(t) // no messages, no title
+
+	out.Reset()
+	if err := runSessionsList(nil, nil); err != nil {
+		t.Fatalf("runSessionsList(): %v", err)
+	}
+	for _, want := range []string{"ID", "FIRST QUESTION", s.ID, "llama3.2:latest", "how do I find every file larger than one hund..."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("list output missing %q:\n%s", want, out.String())
+		}
+	}
+}
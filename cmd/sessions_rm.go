@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hpkotak/shellbud/internal/sessions"
+	"github.com/spf13/cobra"
+)
+
+var sessionsRmCmd = &cobra.Command{
+	Use:   "rm <id>...",
+	Short: "Delete saved chat sessions",
+	Long: `Delete saved chat sessions. Each <id> may be a unique prefix. A session
+that is open in another terminal is not deleted.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runSessionsRm,
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsRmCmd)
+}
+
+func runSessionsRm(cmd *cobra.Command, args []string) error {
+	store := sessions.NewStore(sessions.Dir())
+	for _, id := range args {
+		removed, err := store.Remove(id)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(ioOut, "Removed session %s\n", removed)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/sessions"
+)
+
+func TestRunSessionsRm(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	t.Setenv("HOME", t.TempDir())
+
+	a := seedSession(t, provider.Message{Role: "user", Content: "a"})
+	b := seedSession(t, provider.Message{Role: "user", Content: "b"})
+
+	out := &bytes.Buffer{}
+	ioOut = out
+	if err := runSessionsRm(nil, []string{a.ID, b.ID}); err != nil {
+		t.Fatalf("runSessionsRm(): %v", err)
+	}
+	for _, id := range []string{a.ID, b.ID} {
+		if !strings.Contains(out.String(), "Removed session "+id) {
+			t.Errorf("output missing removal of %s:\n%s", id, out.String())
+		}
+		if _, err := sessions.NewStore(sessions.Dir()).Load(id); !errors.Is(err, sessions.ErrNotFound) {
+			t.Errorf("Load(%s) after rm error = %v, want ErrNotFound", id, err)
+		}
+	}
+
+	if err := runSessionsRm(nil, []string{a.ID}); !errors.Is(err, sessions.ErrNotFound) {
+		t.Errorf("runSessionsRm(removed) error = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hpkotak/shellbud/internal/prompt"
+	"github.com/hpkotak/shellbud/internal/sessions"
+	"github.com/spf13/cobra"
+)
+
+var sessionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show a saved chat session",
+	Long: `Show a saved chat session: its metadata, the conversation, and the
+commands that were run with their exit codes. <id> may be a unique prefix.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSessionsShow,
+}
+
+func init() {
+	sessionsCmd.AddCommand(sessionsShowCmd)
+}
+
+func runSessionsShow(cmd *cobra.Command, args []string) error {
+	s, err := sessions.NewStore(sessions.Dir()).Load(args[0])
+	if err != nil {
+		return err
+	}
+
+	const timeFormat = "2006-01-02 15:04:05"
+	_, _ = fmt.Fprintf(ioOut, "Session:  %s\n", s.ID)
+	_, _ = fmt.Fprintf(ioOut, "Provider: %s (%s)\n", s.Provider, s.Model)
+	_, _ = fmt.Fprintf(ioOut, "Started:  %s in %s\n", s.CreatedAt.Local().Format(timeFormat), s.CWD)
+	_, _ = fmt.Fprintf(ioOut, "Updated:  %s\n", s.UpdatedAt.Local().Format(timeFormat))
+	if len(s.Summaries) > 0 {
+		_, _ = fmt.Fprintf(ioOut, "Summaries: %d (latest covers %d messages)\n", len(s.Summaries), s.Summaries[len(s.Summaries)-1].Covers)
+	}
+
+	for _, m := range s.Messages {
+		switch m.Role {
+		case "assistant":
+			// Display only; nothing from a saved session is ever run.
+			parsed := prompt.ParseChatResponse(m.Content)
+			_, _ = fmt.Fprintf(ioOut, "\nsb> %s\n", parsed.Text)
+			for _, command := range parsed.Commands {
+				_, _ = fmt.Fprintf(ioOut, "  > %s\n", command)
+			}
+		default:
+			_, _ = fmt.Fprintf(ioOut, "\n%s> %s\n", m.Role, m.Content)
+		}
+	}
+
+	if len(s.Commands) > 0 {
+		_, _ = fmt.Fprintln(ioOut, "\nCommands run:")
+		for _, c := range s.Commands {
+			_, _ = fmt.Fprintf(ioOut, "  %s  [exit %d]  %s  (in %s)\n", c.RanAt.Local().Format(timeFormat), c.ExitCode, c.Command, c.CWD)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/sessions"
+)
+
+func TestRunSessionsShow(t *testing.T) {
+	restore := saveCmdVars(t)
+	defer restore()
+	t.Setenv("HOME", t.TempDir())
+
+	h, err := sessions.NewStore(sessions.Dir()).Create("ollama", "llama3.2:latest", "/tmp/project")
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	h.Session.SetHistory([]provider.Message{
+		{Role: "user", Content: "list files"},
+		{Role: "assistant", Content: `{"text":"Here you go.","commands":["ls -la"]}`},
+	})
+	h.Session.Commands = []sessions.Command{{Command: "ls -la", ExitCode: 1, CWD: "/tmp/project"}}
+	h.Session.Summaries = []sessions.Summary{{Text: "earlier", Covers: 4}}
+	if err := h.Save(); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	_ = h.Close()
+
+	out := &bytes.Buffer{}
+	ioOut = out
+	if err := runSessionsShow(nil, []string{h.Session.ID}); err != nil {
+		t.Fatalf("runSessionsShow(): %v", err)
+	}
+	for _, want := range []string{
+		"Session:  " + h.Session.ID,
+		"Provider: ollama (llama3.2:latest)",
+		"in /tmp/project",
+		"Summaries: 1 (latest covers 4 messages)",
+		"user> list files",
+		"sb> Here you go.",
+		"  > ls -la",
+		"[exit 1]  ls -la  (in /tmp/project)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("show output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	if err := runSessionsShow(nil, []string{"missing"}); err == nil || !strings.Contains(err.Error(), "session not found") {
+		t.Errorf("runSessionsShow(missing) error = %v, want not found", err)
+	}
+}
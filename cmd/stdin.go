@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// maxStdinBytes bounds how much piped input is sent to the model.
+const maxStdinBytes = 16 * 1024
+
+// Terminal hooks for piped input. Tests override these.
+var (
+	stdinIsTerminal = func(f *os.File) bool { return term.IsTerminal(int(f.Fd())) }
+	openTTY         = func() (io.ReadCloser, error) { return os.Open("/dev/tty") }
+)
+
+// pipedInput is text piped into a one-shot query, as in
+// `journalctl -u nginx | sb why is this failing`.
+type pipedInput struct {
+	text      string
+	truncated bool
+}
+
+// readPipedInput reads stdin when it is a pipe or file rather than a
+// terminal, keeping at most maxStdinBytes. piped is false for a terminal.
+func readPipedInput(in io.Reader) (input pipedInput, piped bool, err error) {
+	f, ok := in.(*os.File)
+	if !ok || stdinIsTerminal(f) {
+		return pipedInput{}, false, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxStdinBytes+1))
+	if err != nil {
+		return pipedInput{}, true, fmt.Errorf("reading stdin: %w", err)
+	}
+	if len(data) > maxStdinBytes {
+		data = data[:maxStdinBytes]
+		input.truncated = true
+	}
+	input.text = string(data)
+	return input, true, nil
+}
+
+// confirmInput returns where to read command confirmations from. Piped stdin
+// has already been consumed, so confirmations come from the controlling
+// terminal instead. Without one, every command is declined.
+func confirmInput(piped bool) (in io.Reader, closeFn func(), ok bool) {
+	if !piped {
+		return ioIn, func() {}, true
+	}
+	tty, err := openTTY()
+	if err != nil {
+		return strings.NewReader(""), func() {}, false
+	}
+	return tty, func() { _ = tty.Close() }, true
+}
@@ -0,0 +1,106 @@
+// Package approval decides whether a suggested command may run without
+// asking, under the policy chosen with --dry-run, --yes-safe or --yes.
+//
+// The decision is deterministic and depends only on the safety package, never
+// on the model, like the classification itself.
+package approval
+
+import (
+	"fmt"
+
+	"github.com/hpkotak/shellbud/internal/safety"
+)
+
+// Policy is how suggested commands are approved.
+type Policy int
+
+const (
+	// Ask prompts for every command (the default).
+	Ask Policy = iota
+	// DryRun shows commands and never runs them.
+	DryRun
+	// YesSafe runs safe commands without asking and refuses the rest.
+	YesSafe
+	// Yes runs every command without asking, except network and forbidden
+	// ones.
+	Yes
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DryRun:
+		return "dry-run"
+	case YesSafe:
+		return "yes-safe"
+	case Yes:
+		return "yes"
+	}
+	return "ask"
+}
+
+// Describe explains the policy in one line, for echoing when it is in force.
+func (p Policy) Describe() string {
+	switch p {
+	case DryRun:
+		return "dry-run (commands are shown, never run)"
+	case YesSafe:
+		return "yes-safe (safe commands run without asking; others are refused)"
+	case Yes:
+		return "yes (commands run without asking, except network and forbidden ones)"
+	}
+	return "ask (every command needs confirmation)"
+}
+
+// FromFlags returns the policy for the --dry-run, --yes-safe and --yes flags,
+// at most one of which may be set.
+func FromFlags(dryRun, yesSafe, yes bool) (Policy, error) {
+	set := 0
+	policy := Ask
+	for _, f := range []struct {
+		on     bool
+		policy Policy
+	}{{dryRun, DryRun}, {yesSafe, YesSafe}, {yes, Yes}} {
+		if f.on {
+			set++
+			policy = f.policy
+		}
+	}
+	if set > 1 {
+		return Ask, fmt.Errorf("--dry-run, --yes-safe and --yes cannot be combined")
+	}
+	return policy, nil
+}
+
+// Action is what to do with one command.
+type Action int
+
+const (
+	// Prompt asks the user, as without a policy.
+	Prompt Action = iota
+	// Run runs the command without asking.
+	Run
+	// Show displays the command without running it.
+	Show
+	// Refuse does not run the command and counts it as refused.
+	Refuse
+)
+
+// Decide returns the action for command under p, with a reason for the user
+// when the command is not prompted for.
+func (p Policy) Decide(command string) (Action, string) {
+	switch p {
+	case DryRun:
+		return Show, "dry run"
+	case YesSafe:
+		if level := safety.Classify(command); level != safety.Safe {
+			return Refuse, fmt.Sprintf("%s commands are not run with --yes-safe", level)
+		}
+		return Run, "safe command, --yes-safe"
+	case Yes:
+		if level := safety.Classify(command); level >= safety.Network {
+			return Refuse, fmt.Sprintf("%s commands are never run automatically", level)
+		}
+		return Run, "--yes"
+	}
+	return Prompt, ""
+}
@@ -11,7 +11,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -21,24 +23,62 @@ const (
 	DefaultOllamaHost = "http://localhost:11434"
 	DefaultOpenAIHost = "https://api.openai.com/v1"
 	DefaultAFMCommand = "afm-bridge"
+	// DefaultAnthropicHost is the Messages API base URL (requests go to /messages).
+	DefaultAnthropicHost = "https://api.anthropic.com/v1"
+	// DefaultGeminiHost is the Gemini API base URL (requests go to /models/{model}:generateContent).
+	DefaultGeminiHost = "https://generativelanguage.googleapis.com/v1beta"
 	// DefaultAFMModel is the model name used for AFM. AFM has exactly one
 	// on-device model (SystemLanguageModel.default), so "default" is the
 	// canonical identifier the bridge accepts.
 	DefaultAFMModel = "default"
 	DefaultProvider = "ollama"
 	DefaultModel    = "llama3.2:latest"
+	// DefaultContextWindow is the context window, in tokens, assumed for
+	// models without an entry in context_windows.
+	DefaultContextWindow = 8192
 )
 
-var ValidProviders = []string{"ollama", "openai", "afm"}
+var ValidProviders = []string{"ollama", "openai", "afm", "anthropic", "gemini"}
 
 var ErrNotFound = errors.New("config file not found")
 
 type Config struct {
-	Provider string `yaml:"provider"`
-	Model    string `yaml:"model"`
-	Ollama   Ollama `yaml:"ollama"`
-	OpenAI   OpenAI `yaml:"openai"`
-	AFM      AFM    `yaml:"afm"`
+	Provider  string    `yaml:"provider"`
+	Model     string    `yaml:"model"`
+	Ollama    Ollama    `yaml:"ollama"`
+	OpenAI    OpenAI    `yaml:"openai"`
+	AFM       AFM       `yaml:"afm"`
+	Anthropic Anthropic `yaml:"anthropic"`
+	Gemini    Gemini    `yaml:"gemini"`
+
+	// Providers is an optional ordered fallback chain. When set it replaces
+	// Provider: each backend is tried in turn until one is reachable.
+	Providers []ProviderRef `yaml:"providers,omitempty"`
+
+	// ContextWindows maps model names to their context window in tokens.
+	ContextWindows map[string]int `yaml:"context_windows,omitempty"`
+
+	// Summarize makes chat fold turns that no longer fit the context window
+	// into a rolling summary instead of dropping them.
+	Summarize bool `yaml:"summarize,omitempty"`
+
+	// ShellSession makes chat run commands in one long-lived shell, so
+	// aliases, functions and sourced files carry over between them.
+	ShellSession bool `yaml:"shell_session,omitempty"`
+	// CommandTimeout bounds each command run in the shell session, written
+	// as a duration such as 30m. Zero means the default.
+	CommandTimeout time.Duration `yaml:"command_timeout,omitempty"`
+
+	// SafetyFamilies turns families of built-in safety rules, such as git or
+	// cloud, on or off. A family not listed is on.
+	SafetyFamilies map[string]bool `yaml:"safety_families,omitempty"`
+}
+
+// ProviderRef names one backend in the fallback chain. Model overrides the
+// top-level model for that backend, since model names rarely carry across.
+type ProviderRef struct {
+	Name  string `yaml:"name"`
+	Model string `yaml:"model,omitempty"`
 }
 
 type Ollama struct {
@@ -53,6 +93,14 @@ type AFM struct {
 	Command string `yaml:"command"`
 }
 
+type Anthropic struct {
+	Host string `yaml:"host"`
+}
+
+type Gemini struct {
+	Host string `yaml:"host"`
+}
+
 // Validate checks that config values are valid.
 func (c *Config) Validate() error {
 	if !isValidProvider(c.Provider) {
@@ -61,7 +109,60 @@ func (c *Config) Validate() error {
 	if c.Model == "" {
 		return fmt.Errorf("model cannot be empty")
 	}
-	switch c.Provider {
+	if err := c.validateProviderSettings(c.Provider); err != nil {
+		return err
+	}
+	for _, ref := range c.Providers {
+		if !isValidProvider(ref.Name) {
+			return fmt.Errorf("invalid provider %q in providers (valid: %v)", ref.Name, ValidProviders)
+		}
+		if err := c.validateProviderSettings(ref.Name); err != nil {
+			return err
+		}
+	}
+	for model, tokens := range c.ContextWindows {
+		if tokens <= 0 {
+			return fmt.Errorf("context window for %q must be positive, got %d", model, tokens)
+		}
+	}
+	if c.CommandTimeout < 0 {
+		return fmt.Errorf("command timeout must be positive, got %s", c.CommandTimeout)
+	}
+	return nil
+}
+
+// ContextWindow returns the configured context window for model, falling
+// back to DefaultContextWindow.
+func (c *Config) ContextWindow(model string) int {
+	if tokens, ok := c.ContextWindows[model]; ok && tokens > 0 {
+		return tokens
+	}
+	return DefaultContextWindow
+}
+
+// DisabledSafetyFamilies returns the safety rule families turned off, sorted.
+func (c *Config) DisabledSafetyFamilies() []string {
+	var disabled []string
+	for family, on := range c.SafetyFamilies {
+		if !on {
+			disabled = append(disabled, family)
+		}
+	}
+	sort.Strings(disabled)
+	return disabled
+}
+
+// Chain returns the ordered providers to use: the fallback list when set,
+// otherwise the single configured provider.
+func (c *Config) Chain() []ProviderRef {
+	if len(c.Providers) > 0 {
+		return c.Providers
+	}
+	return []ProviderRef{{Name: c.Provider}}
+}
+
+func (c *Config) validateProviderSettings(name string) error {
+	switch name {
 	case "ollama":
 		if err := validateURL("ollama host", c.Ollama.Host); err != nil {
 			return err
@@ -74,6 +175,14 @@ func (c *Config) Validate() error {
 		if strings.TrimSpace(c.AFM.Command) == "" {
 			return fmt.Errorf("afm command cannot be empty")
 		}
+	case "anthropic":
+		if err := validateURL("anthropic host", c.Anthropic.Host); err != nil {
+			return err
+		}
+	case "gemini":
+		if err := validateURL("gemini host", c.Gemini.Host); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -132,9 +241,31 @@ func loadFrom(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("parsing config: %w", err)
 	}
+	cfg.fillDefaults()
 	return &cfg, nil
 }
 
+// fillDefaults sets the host or command of every backend the file leaves
+// out, so members of a fallback chain work without settings of their own.
+func (c *Config) fillDefaults() {
+	d := Default()
+	if c.Ollama.Host == "" {
+		c.Ollama.Host = d.Ollama.Host
+	}
+	if c.OpenAI.Host == "" {
+		c.OpenAI.Host = d.OpenAI.Host
+	}
+	if c.AFM.Command == "" {
+		c.AFM.Command = d.AFM.Command
+	}
+	if c.Anthropic.Host == "" {
+		c.Anthropic.Host = d.Anthropic.Host
+	}
+	if c.Gemini.Host == "" {
+		c.Gemini.Host = d.Gemini.Host
+	}
+}
+
 // Save writes the config to disk, creating the directory if needed.
 func Save(cfg *Config) error {
 	if err := os.MkdirAll(Dir(), 0o755); err != nil {
@@ -174,5 +305,11 @@ func Default() *Config {
 		AFM: AFM{
 			Command: DefaultAFMCommand,
 		},
+		Anthropic: Anthropic{
+			Host: DefaultAnthropicHost,
+		},
+		Gemini: Gemini{
+			Host: DefaultGeminiHost,
+		},
 	}
 }
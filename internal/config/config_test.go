@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadSaveRoundTrip(t *testing.T) {
@@ -18,6 +19,10 @@ func TestLoadSaveRoundTrip(t *testing.T) {
 		Ollama:   Ollama{Host: "http://localhost:11434"},
 		OpenAI:   OpenAI{Host: "https://api.openai.com/v1"},
 		AFM:      AFM{Command: "/usr/local/bin/afm-bridge"},
+
+		Anthropic: Anthropic{Host: "https://api.anthropic.com/v1"},
+		Gemini:    Gemini{Host: "https://generativelanguage.googleapis.com/v1beta"},
+		Summarize: true,
 	}
 
 	// Save to temp path
@@ -50,6 +55,15 @@ func TestLoadSaveRoundTrip(t *testing.T) {
 	if loaded.AFM.Command != cfg.AFM.Command {
 		t.Errorf("AFM.Command = %q, want %q", loaded.AFM.Command, cfg.AFM.Command)
 	}
+	if loaded.Anthropic.Host != cfg.Anthropic.Host {
+		t.Errorf("Anthropic.Host = %q, want %q", loaded.Anthropic.Host, cfg.Anthropic.Host)
+	}
+	if loaded.Gemini.Host != cfg.Gemini.Host {
+		t.Errorf("Gemini.Host = %q, want %q", loaded.Gemini.Host, cfg.Gemini.Host)
+	}
+	if loaded.Summarize != cfg.Summarize {
+		t.Errorf("Summarize = %v, want %v", loaded.Summarize, cfg.Summarize)
+	}
 }
 
 func TestLoadMissingFile(t *testing.T) {
@@ -77,9 +91,76 @@ func TestValidate(t *testing.T) {
 			name: "valid afm config",
 			cfg:  Config{Provider: "afm", Model: "afm-latest", AFM: AFM{Command: "/usr/local/bin/afm-bridge"}},
 		},
+		{
+			name: "valid anthropic config",
+			cfg:  Config{Provider: "anthropic", Model: "claude-sonnet-4-5", Anthropic: Anthropic{Host: "https://api.anthropic.com/v1"}},
+		},
+		{
+			name: "valid gemini config",
+			cfg:  Config{Provider: "gemini", Model: "gemini-2.5-flash", Gemini: Gemini{Host: "https://generativelanguage.googleapis.com/v1beta"}},
+		},
+		{
+			name: "valid fallback chain",
+			cfg: Config{
+				Provider:  "ollama",
+				Model:     "llama3.2:latest",
+				Providers: []ProviderRef{{Name: "ollama"}, {Name: "openai", Model: "gpt-4o-mini"}},
+				Ollama:    Ollama{Host: "http://localhost:11434"},
+				OpenAI:    OpenAI{Host: "https://api.openai.com/v1"},
+			},
+		},
+		{
+			name: "invalid provider in chain",
+			cfg: Config{
+				Provider:  "ollama",
+				Model:     "llama3.2:latest",
+				Providers: []ProviderRef{{Name: "ollama"}, {Name: "unknown"}},
+				Ollama:    Ollama{Host: "http://localhost:11434"},
+			},
+			wantErr: "invalid provider \"unknown\" in providers",
+		},
+		{
+			name: "chain member settings validated",
+			cfg: Config{
+				Provider:  "ollama",
+				Model:     "llama3.2:latest",
+				Providers: []ProviderRef{{Name: "ollama"}, {Name: "gemini"}},
+				Ollama:    Ollama{Host: "http://localhost:11434"},
+			},
+			wantErr: "gemini host URL cannot be empty",
+		},
+		{
+			name: "valid context windows",
+			cfg: Config{
+				Provider:       "ollama",
+				Model:          "llama3.2:latest",
+				Ollama:         Ollama{Host: "http://localhost:11434"},
+				ContextWindows: map[string]int{"llama3.2:latest": 131072},
+			},
+		},
+		{
+			name: "non-positive context window rejected",
+			cfg: Config{
+				Provider:       "ollama",
+				Model:          "llama3.2:latest",
+				Ollama:         Ollama{Host: "http://localhost:11434"},
+				ContextWindows: map[string]int{"llama3.2:latest": 0},
+			},
+			wantErr: "must be positive",
+		},
+		{
+			name: "negative command timeout rejected",
+			cfg: Config{
+				Provider:       "ollama",
+				Model:          "llama3.2:latest",
+				Ollama:         Ollama{Host: "http://localhost:11434"},
+				CommandTimeout: -time.Second,
+			},
+			wantErr: "command timeout must be positive",
+		},
 		{
 			name:    "invalid provider",
-			cfg:     Config{Provider: "anthropic", Model: "claude-sonnet-4", Ollama: Ollama{Host: "http://localhost:11434"}},
+			cfg:     Config{Provider: "unknown", Model: "some-model", Ollama: Ollama{Host: "http://localhost:11434"}},
 			wantErr: "invalid provider",
 		},
 		{
@@ -115,6 +196,16 @@ func TestValidate(t *testing.T) {
 			cfg:     Config{Provider: "ollama", Model: "llama3.2:latest", Ollama: Ollama{Host: ""}},
 			wantErr: "ollama host URL cannot be empty",
 		},
+		{
+			name:    "empty anthropic host rejected",
+			cfg:     Config{Provider: "anthropic", Model: "claude-sonnet-4-5", Anthropic: Anthropic{Host: ""}},
+			wantErr: "anthropic host URL cannot be empty",
+		},
+		{
+			name:    "empty gemini host rejected",
+			cfg:     Config{Provider: "gemini", Model: "gemini-2.5-flash", Gemini: Gemini{Host: ""}},
+			wantErr: "gemini host URL cannot be empty",
+		},
 		{
 			name:    "empty openai host rejected",
 			cfg:     Config{Provider: "openai", Model: "gpt-4o-mini", OpenAI: OpenAI{Host: ""}},
@@ -160,6 +251,12 @@ func TestDefault(t *testing.T) {
 	if cfg.AFM.Command != DefaultAFMCommand {
 		t.Errorf("AFM.Command = %q, want %q", cfg.AFM.Command, DefaultAFMCommand)
 	}
+	if cfg.Anthropic.Host != DefaultAnthropicHost {
+		t.Errorf("Anthropic.Host = %q, want %q", cfg.Anthropic.Host, DefaultAnthropicHost)
+	}
+	if cfg.Gemini.Host != DefaultGeminiHost {
+		t.Errorf("Gemini.Host = %q, want %q", cfg.Gemini.Host, DefaultGeminiHost)
+	}
 
 	if err := cfg.Validate(); err != nil {
 		t.Errorf("Default() config fails Validate(): %v", err)
@@ -257,3 +354,120 @@ future_field: some_value
 		t.Errorf("Provider = %q, want %q", cfg.Provider, "ollama")
 	}
 }
+
+func TestLoadProvidersChain(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.yaml")
+
+	yamlData := `provider: ollama
+model: llama3.2:latest
+providers:
+  - name: ollama
+  - name: openai
+    model: gpt-4o-mini
+`
+	if err := os.WriteFile(path, []byte(yamlData), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cfg, err := loadFrom(path)
+	if err != nil {
+		t.Fatalf("loadFrom() error: %v", err)
+	}
+	want := []ProviderRef{{Name: "ollama"}, {Name: "openai", Model: "gpt-4o-mini"}}
+	got := cfg.Chain()
+	if len(got) != len(want) {
+		t.Fatalf("Chain() len = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Chain()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestLoadFillsDefaultHosts(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.yaml")
+
+	yamlData := `provider: ollama
+model: llama3.2:latest
+openai:
+  host: http://localhost:8080/v1
+providers:
+  - name: ollama
+  - name: anthropic
+  - name: gemini
+  - name: afm
+`
+	if err := os.WriteFile(path, []byte(yamlData), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	cfg, err := loadFrom(path)
+	if err != nil {
+		t.Fatalf("loadFrom() error: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() error: %v", err)
+	}
+	if cfg.Ollama.Host != DefaultOllamaHost {
+		t.Errorf("Ollama.Host = %q, want %q", cfg.Ollama.Host, DefaultOllamaHost)
+	}
+	if cfg.Anthropic.Host != DefaultAnthropicHost {
+		t.Errorf("Anthropic.Host = %q, want %q", cfg.Anthropic.Host, DefaultAnthropicHost)
+	}
+	if cfg.Gemini.Host != DefaultGeminiHost {
+		t.Errorf("Gemini.Host = %q, want %q", cfg.Gemini.Host, DefaultGeminiHost)
+	}
+	if cfg.AFM.Command != DefaultAFMCommand {
+		t.Errorf("AFM.Command = %q, want %q", cfg.AFM.Command, DefaultAFMCommand)
+	}
+	if cfg.OpenAI.Host != "http://localhost:8080/v1" {
+		t.Errorf("OpenAI.Host = %q, a configured host should be kept", cfg.OpenAI.Host)
+	}
+}
+
+func TestChainDefaultsToProvider(t *testing.T) {
+	cfg := Default()
+	got := cfg.Chain()
+	if len(got) != 1 || got[0].Name != DefaultProvider || got[0].Model != "" {
+		t.Errorf("Chain() = %+v, want single %q entry", got, DefaultProvider)
+	}
+
+	data, err := marshalConfig(cfg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(data), "providers") {
+		t.Errorf("empty chain should be omitted from YAML, got:\n%s", data)
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	cfg := &Config{ContextWindows: map[string]int{"big-model": 128000, "broken": -1}}
+
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"big-model", 128000},
+		{"unknown-model", DefaultContextWindow},
+		{"broken", DefaultContextWindow},
+	}
+	for _, tt := range tests {
+		if got := cfg.ContextWindow(tt.model); got != tt.want {
+			t.Errorf("ContextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestDisabledSafetyFamilies(t *testing.T) {
+	cfg := &Config{SafetyFamilies: map[string]bool{"git": false, "cloud": true, "container": false}}
+	if got := strings.Join(cfg.DisabledSafetyFamilies(), ","); got != "container,git" {
+		t.Errorf("DisabledSafetyFamilies() = %q, want container,git", got)
+	}
+	if got := Default().DisabledSafetyFamilies(); got != nil {
+		t.Errorf("Default().DisabledSafetyFamilies() = %q, want none", got)
+	}
+}
@@ -0,0 +1,267 @@
+// Package conversation keeps chat conversations inside the model's context window.
+//
+// Token counts are estimated (roughly four bytes per token plus per-message
+// framing) and calibrated against the Usage the provider reports, so the
+// estimate tracks the real tokenizer without shipping one. When a request
+// would not fit, Budget.Fit shrinks the oldest command outputs first, then
+// drops the oldest turns, and only as a last resort truncates the newest
+// message. The stored history is never modified; Fit returns a copy.
+//
+// Dropped turns can optionally be folded into a rolling Summary that is
+// pinned after the system prompt.
+package conversation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+const (
+	// bytesPerToken is the uncalibrated estimate for English text and code.
+	bytesPerToken = 4
+	// messageOverhead covers role markers and separators per message.
+	messageOverhead = 4
+	// maxReserveTokens caps the room held back for the model's reply.
+	maxReserveTokens = 1024
+	// shrunkOutputBytes is how much of an old command output survives shrinking.
+	shrunkOutputBytes = 512
+	// minScale and maxScale bound calibration so one odd Usage report
+	// cannot wreck the estimate.
+	minScale = 0.5
+	maxScale = 3.0
+)
+
+// outputFence opens the captured-output block in a command result message.
+const outputFence = "\nOutput:\n```\n"
+
+// CommandResult builds the conversation message that reports a command's
+// outcome to the model. Fit recognizes this shape and shrinks the output of
+// old results before dropping whole turns.
+func CommandResult(command string, exitCode int, output string) provider.Message {
+	content := fmt.Sprintf("I ran `%s` — exit code %d.", command, exitCode)
+	if output != "" {
+		content += outputFence + output + "\n```"
+	}
+	return provider.Message{Role: "user", Content: content, FollowUp: true}
+}
+
+// BatchStep is one command run as part of a batch.
+type BatchStep struct {
+	Command  string
+	ExitCode int
+	Output   string
+}
+
+// BatchResult builds the message that reports a batch of suggested commands
+// run in order. planned is how many were in the batch; steps has fewer when
+// the batch stopped at a failure. Each step's output is fenced like a
+// CommandResult's, so Fit can shrink it the same way.
+func BatchResult(steps []BatchStep, planned int) provider.Message {
+	var b strings.Builder
+	if len(steps) == planned && (planned == 0 || steps[planned-1].ExitCode == 0) {
+		fmt.Fprintf(&b, "I ran your suggested commands in order; all %d succeeded.", planned)
+	} else {
+		fmt.Fprintf(&b, "I ran your suggested commands in order, stopping at the first failure; %d of %d ran.", len(steps), planned)
+	}
+	for i, step := range steps {
+		fmt.Fprintf(&b, "\n%d. `%s` — exit code %d.", i+1, step.Command, step.ExitCode)
+		if step.Output != "" {
+			b.WriteString(outputFence + step.Output + "\n```")
+		}
+	}
+	return provider.Message{Role: "user", Content: b.String(), FollowUp: true}
+}
+
+// ExplainRequest builds the message that asks the model to explain a
+// suggested command.
+func ExplainRequest(command string) provider.Message {
+	return provider.Message{
+		Role:     "user",
+		Content:  fmt.Sprintf("Explain what this command does step by step: `%s`", command),
+		FollowUp: true,
+	}
+}
+
+// EditedCommand builds the message that tells the model the user corrected
+// its suggested command before running it, so later suggestions can follow
+// the correction.
+func EditedCommand(suggested, edited string) provider.Message {
+	return provider.Message{
+		Role:     "user",
+		Content:  fmt.Sprintf("I changed your suggested command `%s` to `%s` before running it.", suggested, edited),
+		FollowUp: true,
+	}
+}
+
+// IsFollowUp reports whether m was generated by ShellBud on the user's
+// behalf (a CommandResult, BatchResult, ExplainRequest or EditedCommand)
+// rather than typed by the user. A turn starts at each user message that is
+// not a follow-up.
+func IsFollowUp(m provider.Message) bool {
+	return m.Role == "user" && m.FollowUp
+}
+
+// Budget fits conversations into a model's context window.
+type Budget struct {
+	window  int
+	reserve int
+	// scale corrects the byte-based estimate toward the provider's tokenizer.
+	scale float64
+}
+
+// NewBudget creates a Budget for a model with the given context window in
+// tokens. Part of the window is reserved for the reply.
+func NewBudget(window int) *Budget {
+	return &Budget{
+		window:  window,
+		reserve: min(maxReserveTokens, window/4),
+		scale:   1,
+	}
+}
+
+// Window returns the model context window in tokens.
+func (b *Budget) Window() int { return b.window }
+
+// Limit returns the token budget available to the request itself.
+func (b *Budget) Limit() int { return b.window - b.reserve }
+
+// Estimate returns the estimated token count of msgs.
+func (b *Budget) Estimate(msgs ...provider.Message) int {
+	return int(float64(rawEstimate(msgs...))*b.scale + 0.5)
+}
+
+// Observe calibrates the estimator from a completed request: sent is what was
+// sent and usage is what the provider reported. Reports without input token
+// counts are ignored.
+func (b *Budget) Observe(sent []provider.Message, usage provider.Usage) {
+	raw := rawEstimate(sent...)
+	if usage.InputTokens <= 0 || raw <= 0 {
+		return
+	}
+	ratio := float64(usage.InputTokens) / float64(raw)
+	// Average with the previous scale so a single report moves it halfway.
+	b.scale = min(max((b.scale+ratio)/2, minScale), maxScale)
+}
+
+// Fit returns the system message followed by as much of history as fits the
+// budget. Older command outputs are shrunk first, then the oldest messages
+// are dropped; the newest message is always kept, truncated if it alone
+// exceeds the budget.
+func (b *Budget) Fit(system provider.Message, history []provider.Message) []provider.Message {
+	msgs, _ := b.FitPinned([]provider.Message{system}, history)
+	return msgs
+}
+
+// FitPinned is Fit with several leading messages that are always kept, such
+// as the system prompt and a conversation summary. It also reports how many
+// of the oldest history messages were dropped.
+func (b *Budget) FitPinned(pinned, history []provider.Message) (msgs []provider.Message, dropped int) {
+	msgs = make([]provider.Message, 0, len(pinned)+len(history))
+	msgs = append(msgs, pinned...)
+	msgs = append(msgs, history...)
+	if len(history) == 0 || b.Estimate(msgs...) <= b.Limit() {
+		return msgs, 0
+	}
+
+	// 1. Shrink command outputs, oldest first, leaving the newest message alone.
+	for i := len(pinned); i < len(msgs)-1; i++ {
+		shrunk, ok := shrinkOutput(msgs[i])
+		if !ok {
+			continue
+		}
+		msgs[i].Content = shrunk
+		if b.Estimate(msgs...) <= b.Limit() {
+			return msgs, 0
+		}
+	}
+
+	// 2. Drop the oldest messages. The kept history must start with a user
+	// turn, since some APIs reject a conversation that opens with the model.
+	kept := msgs[len(pinned):]
+	total := b.Estimate(msgs...)
+	for len(kept) > 1 && (total > b.Limit() || kept[0].Role != "user") {
+		total -= b.Estimate(kept[0])
+		kept = kept[1:]
+		dropped++
+	}
+
+	// 3. The newest message alone is too large; keep its head and tail.
+	if total > b.Limit() && len(kept) == 1 {
+		room := b.Limit() - b.Estimate(pinned...) - messageOverhead
+		maxBytes := max(int(float64(room*bytesPerToken)/b.scale), 0)
+		kept[0].Content = truncateMiddle(kept[0].Content, maxBytes)
+	}
+
+	return append(append([]provider.Message(nil), pinned...), kept...), dropped
+}
+
+func rawEstimate(msgs ...provider.Message) int {
+	total := 0
+	for _, m := range msgs {
+		total += messageOverhead + (len(m.Content)+bytesPerToken-1)/bytesPerToken
+	}
+	return total
+}
+
+// shrinkOutput reduces the captured outputs inside a CommandResult or
+// BatchResult message. It reports false when m is not a follow-up with
+// output or its outputs are already small.
+func shrinkOutput(m provider.Message) (string, bool) {
+	content := m.Content
+	if !IsFollowUp(m) {
+		return content, false
+	}
+	var b strings.Builder
+	shrunk := false
+	rest := content
+	for {
+		start := strings.Index(rest, outputFence)
+		if start < 0 {
+			break
+		}
+		bodyStart := start + len(outputFence)
+		// An output may itself contain a fence, so its block ends at the
+		// last fence before the next output block.
+		block := rest[bodyStart:]
+		if next := strings.Index(block, outputFence); next >= 0 {
+			block = block[:next]
+		}
+		bodyEnd := strings.LastIndex(block, "\n```")
+		if bodyEnd < 0 {
+			break
+		}
+		output := block[:bodyEnd]
+		if len(output) > shrunkOutputBytes {
+			output = truncateMiddle(output, shrunkOutputBytes)
+			shrunk = true
+		}
+		b.WriteString(rest[:bodyStart])
+		b.WriteString(output)
+		rest = rest[bodyStart+bodyEnd:]
+	}
+	if !shrunk {
+		return content, false
+	}
+	b.WriteString(rest)
+	return b.String(), true
+}
+
+// truncateMiddle keeps the head and tail of s within maxBytes, marking the
+// elided middle. Tails matter for command output (errors, summaries), heads
+// for questions.
+func truncateMiddle(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	marker := fmt.Sprintf("\n[... %d bytes trimmed ...]\n", len(s)-maxBytes)
+	keep := maxBytes - len(marker)
+	if keep <= 0 {
+		return marker
+	}
+	head := keep / 2
+	tail := keep - head
+	marker = fmt.Sprintf("\n[... %d bytes trimmed ...]\n", len(s)-head-tail)
+	return strings.ToValidUTF8(s[:head], "") + marker + strings.ToValidUTF8(s[len(s)-tail:], "")
+}
@@ -0,0 +1,330 @@
+package conversation
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+func msg(role, content string) provider.Message {
+	return provider.Message{Role: role, Content: content}
+}
+
+func TestCommandResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+	}{
+		{"no output", "", "I ran `true` — exit code 0."},
+		{"with output", "hi", "I ran `true` — exit code 0.\nOutput:\n```\nhi\n```"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CommandResult("true", 0, tt.output)
+			if got.Role != "user" || got.Content != tt.want {
+				t.Errorf("CommandResult() = %+v, want user %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []BatchStep
+		planned int
+		want    string
+	}{
+		{
+			name:    "all succeeded",
+			steps:   []BatchStep{{Command: "mkdir out", ExitCode: 0}, {Command: "ls out", ExitCode: 0, Output: "a"}},
+			planned: 2,
+			want:    "I ran your suggested commands in order; all 2 succeeded.\n1. `mkdir out` — exit code 0.\n2. `ls out` — exit code 0.\nOutput:\n```\na\n```",
+		},
+		{
+			name:    "stopped at a failure",
+			steps:   []BatchStep{{Command: "make", ExitCode: 2, Output: "no rule"}},
+			planned: 3,
+			want:    "I ran your suggested commands in order, stopping at the first failure; 1 of 3 ran.\n1. `make` — exit code 2.\nOutput:\n```\nno rule\n```",
+		},
+		{
+			name:    "last command failed",
+			steps:   []BatchStep{{Command: "true", ExitCode: 0}, {Command: "false", ExitCode: 1}},
+			planned: 2,
+			want:    "I ran your suggested commands in order, stopping at the first failure; 2 of 2 ran.\n1. `true` — exit code 0.\n2. `false` — exit code 1.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BatchResult(tt.steps, tt.planned)
+			if got.Role != "user" || got.Content != tt.want {
+				t.Errorf("BatchResult() = %q, want %q", got.Content, tt.want)
+			}
+			if !IsFollowUp(got) {
+				t.Error("BatchResult() should be a follow-up")
+			}
+		})
+	}
+}
+
+func TestShrinkOutputBatch(t *testing.T) {
+	big := strings.Repeat("line\n", 400) + "```\nfenced in output\n```"
+	msg := BatchResult([]BatchStep{
+		{Command: "a", Output: big},
+		{Command: "b"},
+		{Command: "c", Output: "small"},
+		{Command: "d", Output: big},
+	}, 4)
+
+	got, ok := shrinkOutput(msg)
+	if !ok {
+		t.Fatal("shrinkOutput() did not shrink a batch with large outputs")
+	}
+	if len(got) >= len(msg.Content)/2 {
+		t.Errorf("shrunk to %d bytes from %d, want both outputs trimmed", len(got), len(msg.Content))
+	}
+	for _, want := range []string{"1. `a`", "2. `b` — exit code 0.\n3. `c`", "Output:\n```\nsmall\n```", "4. `d`", "bytes trimmed"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("shrunk batch missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "bytes trimmed") != 2 {
+		t.Errorf("want both large outputs trimmed:\n%s", got)
+	}
+}
+
+func TestEditedCommand(t *testing.T) {
+	got := EditedCommand("rm -rf build", "rm -rf build/tmp")
+	want := "I changed your suggested command `rm -rf build` to `rm -rf build/tmp` before running it."
+	if got.Role != "user" || got.Content != want {
+		t.Errorf("EditedCommand() = %+v, want user %q", got, want)
+	}
+}
+
+func TestIsFollowUp(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  provider.Message
+		want bool
+	}{
+		{"command result", CommandResult("ls", 0, "a"), true},
+		{"explain request", ExplainRequest("ls -la"), true},
+		{"edited command", EditedCommand("ls", "ls -la"), true},
+		{"typed question", msg("user", "list files"), false},
+		{"typed like a result", msg("user", "I ran `make` and it failed"), false},
+		{"typed like an explain request", msg("user", "Explain what this command does step by step: `ls`"), false},
+		{"assistant reply", msg("assistant", "I ran `ls` — exit code 0."), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFollowUp(tt.msg); got != tt.want {
+				t.Errorf("IsFollowUp(%q) = %v, want %v", tt.msg.Content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetEstimateAndLimit(t *testing.T) {
+	b := NewBudget(8192)
+	if b.Window() != 8192 {
+		t.Errorf("Window() = %d, want 8192", b.Window())
+	}
+	if b.Limit() != 8192-1024 {
+		t.Errorf("Limit() = %d, want %d", b.Limit(), 8192-1024)
+	}
+
+	small := NewBudget(400)
+	if small.Limit() != 300 {
+		t.Errorf("small Limit() = %d, want 300 (quarter reserved)", small.Limit())
+	}
+
+	// 40 bytes = 10 tokens + 4 overhead.
+	if got := b.Estimate(msg("user", strings.Repeat("a", 40))); got != 14 {
+		t.Errorf("Estimate() = %d, want 14", got)
+	}
+	if got := b.Estimate(); got != 0 {
+		t.Errorf("Estimate() of nothing = %d, want 0", got)
+	}
+}
+
+func TestBudgetObserve(t *testing.T) {
+	sent := []provider.Message{msg("user", strings.Repeat("a", 396))} // raw 103
+
+	b := NewBudget(8192)
+	b.Observe(sent, provider.Usage{InputTokens: 206})
+	// Ratio 2.0 averaged with 1.0.
+	if got := b.Estimate(sent...); got != 155 {
+		t.Errorf("Estimate() after Observe = %d, want 155", got)
+	}
+
+	b.Observe(sent, provider.Usage{})
+	if got := b.Estimate(sent...); got != 155 {
+		t.Errorf("Estimate() after empty usage = %d, want unchanged 155", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		b.Observe(sent, provider.Usage{InputTokens: 100000})
+	}
+	if got, limit := b.Estimate(sent...), 309; got != limit { // 103 * maxScale, rounded
+		t.Errorf("Estimate() after outliers = %d, want clamped %d", got, limit)
+	}
+}
+
+func TestFitWithinBudget(t *testing.T) {
+	b := NewBudget(8192)
+	sys := msg("system", "sys")
+	history := []provider.Message{msg("user", "hi"), msg("assistant", "hello")}
+
+	got := b.Fit(sys, history)
+	if len(got) != 3 || got[0] != sys || got[1] != history[0] || got[2] != history[1] {
+		t.Errorf("Fit() = %+v, want system + full history", got)
+	}
+}
+
+func TestFitShrinksOldOutputFirst(t *testing.T) {
+	b := NewBudget(1024) // limit 768 tokens
+	sys := msg("system", "sys")
+	bigOutput := strings.Repeat("0123456789abcdef\n", 200) // 3400 bytes
+	history := []provider.Message{
+		msg("user", "list"),
+		msg("assistant", `{"text":"ok","commands":["ls"]}`),
+		CommandResult("ls", 0, bigOutput),
+		msg("user", "and then?"),
+	}
+
+	got := b.Fit(sys, history)
+	if len(got) != 5 {
+		t.Fatalf("Fit() kept %d messages, want all 5", len(got))
+	}
+	if !strings.Contains(got[3].Content, "bytes trimmed") {
+		t.Error("old command output should be shrunk")
+	}
+	if !strings.HasSuffix(got[3].Content, "\n```") || !strings.HasPrefix(got[3].Content, "I ran `ls`") {
+		t.Errorf("shrunk result lost its framing: %q", got[3].Content)
+	}
+	if history[2].Content != CommandResult("ls", 0, bigOutput).Content {
+		t.Error("Fit() must not modify the caller's history")
+	}
+	if est := b.Estimate(got...); est > b.Limit() {
+		t.Errorf("Fit() result estimated at %d tokens, limit %d", est, b.Limit())
+	}
+}
+
+func TestFitDropsOldestTurns(t *testing.T) {
+	b := NewBudget(400) // limit 300 tokens
+	sys := msg("system", "sys")
+	var history []provider.Message
+	for i := 0; i < 20; i++ {
+		history = append(history,
+			msg("user", strings.Repeat("q", 80)),
+			msg("assistant", strings.Repeat("a", 80)),
+		)
+	}
+	history = append(history, msg("user", "latest"))
+
+	got := b.Fit(sys, history)
+	if est := b.Estimate(got...); est > b.Limit() {
+		t.Errorf("Fit() result estimated at %d tokens, limit %d", est, b.Limit())
+	}
+	if got[0] != sys {
+		t.Error("system message must come first")
+	}
+	if got[1].Role != "user" {
+		t.Errorf("first kept turn role = %q, want user", got[1].Role)
+	}
+	if got[len(got)-1].Content != "latest" {
+		t.Errorf("newest message = %q, want %q", got[len(got)-1].Content, "latest")
+	}
+	if len(got) >= len(history)+1 {
+		t.Errorf("Fit() kept %d messages, want some dropped", len(got))
+	}
+}
+
+func TestFitTruncatesOversizedNewestMessage(t *testing.T) {
+	b := NewBudget(400)
+	sys := msg("system", "sys")
+	huge := "HEAD" + strings.Repeat("é", 5000) + "TAIL"
+
+	got := b.Fit(sys, []provider.Message{msg("assistant", "old"), msg("user", huge)})
+	if len(got) != 2 {
+		t.Fatalf("Fit() kept %d messages, want system + newest", len(got))
+	}
+	content := got[1].Content
+	if !strings.HasPrefix(content, "HEAD") || !strings.HasSuffix(content, "TAIL") {
+		t.Error("truncation should keep head and tail")
+	}
+	if !strings.Contains(content, "bytes trimmed") {
+		t.Error("truncation should mark the elided middle")
+	}
+	if !utf8.ValidString(content) {
+		t.Error("truncation must not split UTF-8 sequences")
+	}
+	if est := b.Estimate(got...); est > b.Limit() {
+		t.Errorf("Fit() result estimated at %d tokens, limit %d", est, b.Limit())
+	}
+}
+
+func TestFitPinnedReportsDropped(t *testing.T) {
+	b := NewBudget(400) // limit 300 tokens
+	pinned := []provider.Message{msg("system", "sys"), msg("system", "summary")}
+	var history []provider.Message
+	for i := 0; i < 10; i++ {
+		history = append(history,
+			msg("user", strings.Repeat("q", 80)),
+			msg("assistant", strings.Repeat("a", 80)),
+		)
+	}
+
+	got, dropped := b.FitPinned(pinned, history)
+	if got[0] != pinned[0] || got[1] != pinned[1] {
+		t.Error("pinned messages must come first")
+	}
+	if dropped == 0 || len(got) != len(pinned)+len(history)-dropped {
+		t.Errorf("FitPinned() kept %d messages and reported %d dropped of %d", len(got), dropped, len(history))
+	}
+	if got[2] != history[dropped] {
+		t.Errorf("first kept message = %+v, want history[%d]", got[2], dropped)
+	}
+
+	if _, dropped := b.FitPinned(pinned, history[:2]); dropped != 0 {
+		t.Errorf("FitPinned() within budget reported %d dropped, want 0", dropped)
+	}
+}
+
+func TestShrinkOutputIgnoresOtherMessages(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  provider.Message
+	}{
+		{"plain user message", msg("user", "Output:\n```\n"+strings.Repeat("x", 2000)+"\n```")},
+		{"typed like a result", msg("user", "I ran `make` and it failed\nOutput:\n```\n"+strings.Repeat("x", 2000)+"\n```")},
+		{"small output", CommandResult("ls", 0, "a\nb")},
+		{"no output", CommandResult("true", 0, "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, ok := shrinkOutput(tt.msg); ok || got != tt.msg.Content {
+				t.Errorf("shrinkOutput() changed %q", tt.msg.Content)
+			}
+		})
+	}
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	if got := truncateMiddle("short", 10); got != "short" {
+		t.Errorf("truncateMiddle() = %q, want unchanged", got)
+	}
+	got := truncateMiddle(strings.Repeat("x", 1000), 100)
+	if len(got) > 100 {
+		t.Errorf("len = %d, want <= 100", len(got))
+	}
+	if !strings.Contains(got, "trimmed") {
+		t.Errorf("missing marker: %q", got)
+	}
+	if got := truncateMiddle(strings.Repeat("x", 1000), 5); !strings.Contains(got, "trimmed") || strings.Contains(got, "x") {
+		t.Errorf("tiny budget should leave only the marker, got %q", got)
+	}
+}
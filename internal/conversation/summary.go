@@ -0,0 +1,70 @@
+package conversation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+// maxSummaryBytes caps a summary so it never crowds out the turns it sits
+// beside.
+const maxSummaryBytes = 2048
+
+// Summary is a rolling digest of the oldest history messages, written by the
+// model when they no longer fit the context window. It is pinned after the
+// system prompt in place of the messages it covers. A summary is context
+// only: it is never shown as a reply and never parsed for commands.
+type Summary struct {
+	// Text is the model-written digest.
+	Text string
+	// Covers is how many leading history messages the summary replaces.
+	Covers int
+	// Usage is what the summarization request cost.
+	Usage provider.Usage
+}
+
+// Message returns the summary as a system message.
+func (s Summary) Message() provider.Message {
+	return provider.Message{
+		Role:    "system",
+		Content: "Conversation so far (summary of earlier messages that no longer fit):\n" + s.Text,
+	}
+}
+
+// Pinned returns system followed by the summary message, if there is one.
+func (s Summary) Pinned(system provider.Message) []provider.Message {
+	if s.Text == "" {
+		return []provider.Message{system}
+	}
+	return []provider.Message{system, s.Message()}
+}
+
+// SummaryRequest builds the messages that ask the model to fold evicted into
+// the previous summary text. instructions is the system prompt. The
+// transcript has old command output shrunk and is fitted to the budget.
+func (b *Budget) SummaryRequest(instructions, previous string, evicted []provider.Message) []provider.Message {
+	var sb strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&sb, "<previous_summary>\n%s\n</previous_summary>\n\n", previous)
+	}
+	sb.WriteString("<transcript>\n")
+	for _, m := range evicted {
+		content, _ := shrinkOutput(m)
+		fmt.Fprintf(&sb, "%s: %s\n\n", m.Role, content)
+	}
+	sb.WriteString("</transcript>")
+
+	return b.Fit(
+		provider.Message{Role: "system", Content: instructions},
+		[]provider.Message{{Role: "user", Content: sb.String()}},
+	)
+}
+
+// ClampSummary trims a generated summary and bounds it to a quarter of the
+// budget (at most maxSummaryBytes), so a verbose model cannot let the
+// summary push out the turns it was meant to make room for.
+func (b *Budget) ClampSummary(text string) string {
+	limit := min(maxSummaryBytes, int(float64(b.Limit()*bytesPerToken)/b.scale)/4)
+	return truncateMiddle(strings.TrimSpace(text), limit)
+}
@@ -0,0 +1,97 @@
+package conversation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+func TestSummaryPinned(t *testing.T) {
+	sys := msg("system", "sys")
+
+	if got := (Summary{}).Pinned(sys); len(got) != 1 || got[0] != sys {
+		t.Errorf("empty Summary.Pinned() = %+v, want only the system message", got)
+	}
+
+	got := Summary{Text: "user is fixing a build", Covers: 4}.Pinned(sys)
+	if len(got) != 2 || got[0] != sys {
+		t.Fatalf("Summary.Pinned() = %+v, want system + summary", got)
+	}
+	if got[1].Role != "system" {
+		t.Errorf("summary role = %q, want system", got[1].Role)
+	}
+	if !strings.HasPrefix(got[1].Content, "Conversation so far") || !strings.HasSuffix(got[1].Content, "user is fixing a build") {
+		t.Errorf("summary content = %q", got[1].Content)
+	}
+}
+
+func TestSummaryRequest(t *testing.T) {
+	b := NewBudget(8192)
+	evicted := []provider.Message{
+		msg("user", "list files"),
+		msg("assistant", `{"text":"ok","commands":["ls"]}`),
+		CommandResult("ls", 0, strings.Repeat("file\n", 400)),
+	}
+
+	tests := []struct {
+		name     string
+		previous string
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:    "first summary",
+			want:    []string{"<transcript>", "user: list files", `assistant: {"text":"ok"`, "bytes trimmed", "</transcript>"},
+			notWant: []string{"<previous_summary>"},
+		},
+		{
+			name:     "folds previous summary",
+			previous: "user set up the repo",
+			want:     []string{"<previous_summary>\nuser set up the repo\n</previous_summary>", "<transcript>"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := b.SummaryRequest("summarize", tt.previous, evicted)
+			if len(got) != 2 || got[0].Role != "system" || got[0].Content != "summarize" || got[1].Role != "user" {
+				t.Fatalf("SummaryRequest() = %+v, want instructions + transcript", got)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got[1].Content, want) {
+					t.Errorf("transcript missing %q", want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got[1].Content, notWant) {
+					t.Errorf("transcript should not contain %q", notWant)
+				}
+			}
+		})
+	}
+
+	// The request itself fits the budget, keeping the closing tag.
+	small := NewBudget(400)
+	got := small.SummaryRequest("summarize", "", []provider.Message{msg("user", strings.Repeat("q", 5000))})
+	if est := small.Estimate(got...); est > small.Limit() {
+		t.Errorf("SummaryRequest() estimated at %d tokens, limit %d", est, small.Limit())
+	}
+	if !strings.HasSuffix(got[1].Content, "</transcript>") {
+		t.Error("truncated transcript should keep its closing tag")
+	}
+}
+
+func TestClampSummary(t *testing.T) {
+	b := NewBudget(100000)
+	if got := b.ClampSummary("  short summary \n"); got != "short summary" {
+		t.Errorf("ClampSummary() = %q, want trimmed", got)
+	}
+	if got := b.ClampSummary(strings.Repeat("x", 10000)); len(got) > maxSummaryBytes {
+		t.Errorf("ClampSummary() len = %d, want <= %d", len(got), maxSummaryBytes)
+	}
+
+	small := NewBudget(400) // limit 300 tokens, a quarter is ~300 bytes
+	if got := small.ClampSummary(strings.Repeat("x", 2000)); len(got) > 300 {
+		t.Errorf("ClampSummary() len = %d, want a quarter of the budget", len(got))
+	}
+}
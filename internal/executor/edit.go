@@ -0,0 +1,145 @@
+package executor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hpkotak/shellbud/internal/lineedit"
+)
+
+// Answer is the reply to ConfirmEdit.
+type Answer int
+
+const (
+	// No declines the command.
+	No Answer = iota
+	// Yes runs the command.
+	Yes
+	// Modify asks to edit the command first.
+	Modify
+)
+
+// ConfirmEdit is Confirm with a third choice, "m" to modify the command
+// before deciding.
+func ConfirmEdit(prompt string, defaultYes bool, in io.Reader, out io.Writer) Answer {
+	hint := "[Y/n/m]"
+	if !defaultYes {
+		hint = "[y/N/m]"
+	}
+	_, _ = fmt.Fprintf(out, "%s %s: ", prompt, hint)
+
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		return No
+	}
+
+	switch strings.TrimSpace(strings.ToLower(scanner.Text())) {
+	case "":
+		if defaultYes {
+			return Yes
+		}
+		return No
+	case "y", "yes":
+		return Yes
+	case "m", "modify":
+		return Modify
+	default:
+		return No
+	}
+}
+
+// Editor returns the user's editor command from $VISUAL or $EDITOR, or ""
+// when neither is set.
+func Editor() string {
+	if e := os.Getenv("VISUAL"); e != "" {
+		return e
+	}
+	return os.Getenv("EDITOR")
+}
+
+// EditInEditor opens command in editor and returns the saved text, trimmed.
+// editor may carry arguments (for example "code --wait"), so it is run by
+// /bin/sh with the file as its last argument.
+func EditInEditor(editor, command string) (string, error) {
+	f, err := os.CreateTemp("", "sb-command-*.sh")
+	if err != nil {
+		return "", fmt.Errorf("creating edit file: %w", err)
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err := f.WriteString(command + "\n"); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("writing edit file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("writing edit file: %w", err)
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", editor+` "$1"`, "sh", f.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running editor %q: %w", editor, err)
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", fmt.Errorf("reading edit file: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// LineReader reads one line of input after showing prompt. It returns io.EOF
+// at the end of input and lineedit.ErrInterrupted on Ctrl-C.
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// LineEditor is a LineReader that can also edit text in place, as
+// lineedit.Editor does at a terminal.
+type LineEditor interface {
+	LineReader
+	EditLine(prompt, text string) (string, error)
+}
+
+// EditCommand lets the user change command before running it: in their
+// editor when $VISUAL or $EDITOR is set, else on a line from in, edited in
+// place when in is a LineEditor. An empty, failed or interrupted edit keeps
+// the command. The error is set only when reading in fails or input ends,
+// and command is returned with it.
+func EditCommand(command string, in LineReader, out io.Writer) (string, error) {
+	if editor := Editor(); editor != "" {
+		edited, err := EditInEditor(editor, command)
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "  Edit error: %v\n", err)
+			return command, nil
+		}
+		if edited == "" {
+			return command, nil
+		}
+		return edited, nil
+	}
+
+	var line string
+	var err error
+	if e, ok := in.(LineEditor); ok {
+		line, err = e.EditLine("  edit> ", command)
+	} else {
+		line, err = in.ReadLine("  New command (Enter keeps it): ")
+	}
+	if errors.Is(err, lineedit.ErrInterrupted) {
+		return command, nil
+	}
+	if err != nil {
+		return command, err
+	}
+	if line = strings.TrimSpace(line); line == "" {
+		return command, nil
+	}
+	return line, nil
+}
@@ -0,0 +1,127 @@
+package executor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/lineedit"
+)
+
+func TestConfirmEdit(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		defaultYes bool
+		want       Answer
+	}{
+		{"enter with default yes", "\n", true, Yes},
+		{"enter with default no", "\n", false, No},
+		{"explicit y", "y\n", false, Yes},
+		{"explicit n", "n\n", true, No},
+		{"modify", "m\n", true, Modify},
+		{"modify spelled out", "Modify\n", false, Modify},
+		{"garbage input", "asdf\n", true, No},
+		{"end of input", "", true, No},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if got := ConfirmEdit("Run?", tt.defaultYes, strings.NewReader(tt.input), out); got != tt.want {
+				t.Errorf("ConfirmEdit(%q, defaultYes=%v) = %v, want %v", tt.input, tt.defaultYes, got, tt.want)
+			}
+			if !strings.Contains(out.String(), "/m]") {
+				t.Errorf("prompt = %q, want the modify choice", out.String())
+			}
+		})
+	}
+}
+
+func TestEditor(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "vi")
+	if got := Editor(); got != "vi" {
+		t.Errorf("Editor() = %q, want vi", got)
+	}
+	t.Setenv("VISUAL", "code --wait")
+	if got := Editor(); got != "code --wait" {
+		t.Errorf("Editor() = %q, want $VISUAL", got)
+	}
+}
+
+func TestEditInEditor(t *testing.T) {
+	// A fake editor that records what it was given and saves a new command.
+	dir := t.TempDir()
+	seen := filepath.Join(dir, "seen")
+	script := filepath.Join(dir, "editor")
+	body := "#!/bin/sh\ncp \"$2\" " + seen + "\nprintf '  ls -la /tmp\\n\\n' > \"$2\"\n"
+	if err := os.WriteFile(script, []byte(body), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := EditInEditor(script+" --flag", "ls -la")
+	if err != nil {
+		t.Fatalf("EditInEditor() error: %v", err)
+	}
+	if got != "ls -la /tmp" {
+		t.Errorf("EditInEditor() = %q, want trimmed edited command", got)
+	}
+	if data, _ := os.ReadFile(seen); string(data) != "ls -la\n" {
+		t.Errorf("editor was given %q, want the suggested command", data)
+	}
+
+	if _, err := EditInEditor("false", "ls"); err == nil || !strings.Contains(err.Error(), "running editor") {
+		t.Errorf("EditInEditor(false) error = %v, want editor failure", err)
+	}
+}
+
+// lines is a LineReader that returns its lines in turn, then io.EOF.
+type lines []string
+
+func (l *lines) ReadLine(string) (string, error) {
+	if len(*l) == 0 {
+		return "", io.EOF
+	}
+	line := (*l)[0]
+	*l = (*l)[1:]
+	return line, nil
+}
+
+func TestEditCommand(t *testing.T) {
+	t.Setenv("EDITOR", "")
+
+	tests := []struct {
+		name    string
+		visual  string
+		in      LineReader
+		want    string
+		wantErr error
+		wantOut string
+	}{
+		{name: "plain line", in: &lines{"  ls -la  "}, want: "ls -la"},
+		{name: "empty line keeps the command", in: &lines{""}, want: "ls"},
+		{name: "end of input", in: &lines{}, want: "ls", wantErr: io.EOF},
+		{name: "edited in place", in: lineedit.New(strings.NewReader(" -la\r"), io.Discard), want: "ls -la"},
+		{name: "Ctrl-C keeps the command", in: lineedit.New(strings.NewReader(" -la\x03"), io.Discard), want: "ls"},
+		{name: "editor", visual: `printf 'ls -la ~\n' >`, want: "ls -la ~"},
+		{name: "editor saves nothing", visual: `: >`, want: "ls"},
+		{name: "editor failure keeps the command", visual: "false", want: "ls", wantOut: "Edit error:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VISUAL", tt.visual)
+			out := &bytes.Buffer{}
+			got, err := EditCommand("ls", tt.in, out)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("EditCommand() = %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output = %q, want %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
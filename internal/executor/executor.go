@@ -4,7 +4,9 @@
 // Run inherits the user's terminal (stdin/stdout/stderr) so interactive commands
 // work naturally. RunCapture tees output for conversation context while preserving
 // real-time display. Truncation at MaxOutputBytes prevents conversation bloat from
-// verbose commands.
+// verbose commands. Shell and ShellSession run a chat's commands so state
+// carries from one to the next. EditInEditor opens a suggested command in the user's
+// editor so it can be corrected before it runs.
 package executor
 
 import (
@@ -66,8 +68,11 @@ func Run(command string) error {
 // capturing it for conversation context. Output is truncated at MaxOutputBytes.
 // Non-zero exit codes are returned as data, not as Go errors.
 func RunCapture(command string) (output string, exitCode int, err error) {
-	shell := platform.Shell()
-	cmd := exec.Command(shell, "-c", command)
+	return capture(exec.Command(platform.Shell(), "-c", command))
+}
+
+// capture runs cmd with output teed to the terminal and a buffer.
+func capture(cmd *exec.Cmd) (output string, exitCode int, err error) {
 	cmd.Stdin = os.Stdin
 
 	var buf bytes.Buffer
@@ -85,9 +90,13 @@ func RunCapture(command string) (output string, exitCode int, err error) {
 		}
 	}
 
-	out := buf.String()
+	return truncateOutput(buf.String()), exitCode, nil
+}
+
+// truncateOutput caps captured output at MaxOutputBytes.
+func truncateOutput(out string) string {
 	if len(out) > MaxOutputBytes {
 		out = out[:MaxOutputBytes] + "\n[output truncated]"
 	}
-	return out, exitCode, nil
+	return out
 }
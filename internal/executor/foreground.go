@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// handTerminal makes the process group pgid the foreground group of the
+// terminal on stdin, so a command in it can read the terminal, and returns
+// a func that takes the terminal back. It does nothing when stdin is not a
+// terminal.
+func handTerminal(pgid int) (takeBack func()) {
+	fd := os.Stdin.Fd()
+	own, err := tcgetpgrp(fd)
+	if err != nil {
+		return func() {}
+	}
+	if err := tcsetpgrp(fd, pgid); err != nil {
+		return func() {}
+	}
+	return func() {
+		// sb is in the background now, and a background process that
+		// changes the foreground group is sent SIGTTOU.
+		signal.Ignore(syscall.SIGTTOU)
+		defer signal.Reset(syscall.SIGTTOU)
+		_ = tcsetpgrp(fd, own)
+	}
+}
+
+func tcgetpgrp(fd uintptr) (int, error) {
+	var pgid int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgid))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgid), nil
+}
+
+func tcsetpgrp(fd uintptr, pgid int) error {
+	id := int32(pgid)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&id))); errno != 0 {
+		return errno
+	}
+	return nil
+}
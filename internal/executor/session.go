@@ -0,0 +1,321 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hpkotak/shellbud/internal/platform"
+)
+
+// DefaultCommandTimeout bounds one command in a ShellSession.
+const DefaultCommandTimeout = 10 * time.Minute
+
+// Runner runs a chat session's commands, carrying state from one command to
+// the next. Shell spawns a shell per command and carries the directory and
+// environment; ShellSession keeps one shell alive, so everything carries.
+type Runner interface {
+	// RunCapture runs command like the package-level RunCapture.
+	RunCapture(command string) (output string, exitCode int, err error)
+	// WorkDir is the directory the next command runs in; empty means sb's
+	// own.
+	WorkDir() string
+	// Reset forgets the carried state.
+	Reset() error
+	// Close releases the runner's resources.
+	Close() error
+}
+
+// WorkDir implements Runner.
+func (sh *Shell) WorkDir() string { return sh.Dir }
+
+// Reset implements Runner.
+func (sh *Shell) Reset() error {
+	sh.Dir, sh.Env = "", nil
+	return nil
+}
+
+// Close implements Runner; a Shell holds nothing between commands.
+func (sh *Shell) Close() error { return nil }
+
+// ShellSession runs every command in one long-lived $SHELL, so aliases,
+// functions, sourced virtualenvs and unexported variables persist, as they
+// would in a terminal.
+//
+// The shell reads the path of each command's script from fd 3 and sources
+// it, leaving stdin to the command. After the script it prints an end marker
+// with a per-session nonce, the exit status and $PWD; output up to the
+// marker is the command's. The shell runs in its own process group, which
+// holds the terminal while a command runs. A command that runs past Timeout
+// kills the whole group, and one that exits the shell ends the session.
+// Either way the next command starts a fresh shell.
+type ShellSession struct {
+	// Timeout bounds each command. Zero means DefaultCommandTimeout.
+	Timeout time.Duration
+
+	cmd     *exec.Cmd
+	control *os.File
+	chunks  chan []byte
+	done    chan struct{}
+	tmpDir  string
+	marker  []byte
+	// early holds output the shell wrote after the last end marker, which
+	// belongs to the next command.
+	early []byte
+	seq   int
+	dir   string
+}
+
+// NewShellSession returns a ShellSession whose commands are bounded by
+// timeout. The shell starts with the first command.
+func NewShellSession(timeout time.Duration) *ShellSession {
+	return &ShellSession{Timeout: timeout}
+}
+
+// markerEnd closes the end marker.
+const markerEnd = '\x1e'
+
+// sessionProgram is the loop the shell runs: source each script named on fd
+// 3, then print the end marker.
+func sessionProgram(shell, nonce string) string {
+	if filepath.Base(shell) == "fish" {
+		return `while read -l __sb_file <&3
+	source $__sb_file
+	printf '\036sb-end-` + nonce + `:%d:%s\036' $status "$PWD"
+end`
+	}
+	return `shopt -s expand_aliases 2>/dev/null
+while IFS= read -r __sb_file <&3; do
+	. "$__sb_file"
+	printf '\036sb-end-` + nonce + `:%d:%s\036' "$?" "$PWD"
+done`
+}
+
+func (ss *ShellSession) start() error {
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return fmt.Errorf("starting shell session: %w", err)
+	}
+	tmpDir, err := os.MkdirTemp("", "sb-session-*")
+	if err != nil {
+		return fmt.Errorf("starting shell session: %w", err)
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return fmt.Errorf("starting shell session: %w", err)
+	}
+	ctlR, ctlW, err := os.Pipe()
+	if err != nil {
+		_ = outR.Close()
+		_ = outW.Close()
+		_ = os.RemoveAll(tmpDir)
+		return fmt.Errorf("starting shell session: %w", err)
+	}
+
+	shell := platform.Shell()
+	cmd := exec.Command(shell, "-c", sessionProgram(shell, hex.EncodeToString(nonce[:])))
+	cmd.Dir = ss.dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = outW
+	cmd.Stderr = outW
+	cmd.ExtraFiles = []*os.File{ctlR}
+	// Its own process group, so a timeout can kill what the command started.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	err = cmd.Start()
+	// The shell has its own copies now.
+	_ = outW.Close()
+	_ = ctlR.Close()
+	if err != nil {
+		_ = outR.Close()
+		_ = ctlW.Close()
+		_ = os.RemoveAll(tmpDir)
+		return fmt.Errorf("starting shell session: %w", err)
+	}
+
+	ss.cmd, ss.control, ss.tmpDir = cmd, ctlW, tmpDir
+	ss.marker = []byte("\x1esb-end-" + hex.EncodeToString(nonce[:]) + ":")
+	ss.chunks, ss.done = make(chan []byte), make(chan struct{})
+	go readChunks(outR, ss.chunks, ss.done)
+	return nil
+}
+
+// readChunks forwards what the shell writes until it closes its output or
+// the session stops listening.
+func readChunks(r *os.File, chunks chan<- []byte, done <-chan struct{}) {
+	defer func() { _ = r.Close() }()
+	defer close(chunks)
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			select {
+			case chunks <- bytes.Clone(buf[:n]):
+			case <-done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// RunCapture implements Runner. Output is shown as it arrives and captured,
+// truncated at MaxOutputBytes.
+func (ss *ShellSession) RunCapture(command string) (output string, exitCode int, err error) {
+	if ss.cmd == nil {
+		if err := ss.start(); err != nil {
+			return "", 0, err
+		}
+	}
+
+	ss.seq++
+	script := filepath.Join(ss.tmpDir, strconv.Itoa(ss.seq)+".sh")
+	if err := os.WriteFile(script, []byte(command+"\n"), 0o600); err != nil {
+		return "", 0, fmt.Errorf("writing command: %w", err)
+	}
+	defer func() { _ = os.Remove(script) }()
+	defer handTerminal(ss.cmd.Process.Pid)()
+	if _, err := fmt.Fprintln(ss.control, script); err != nil {
+		ss.stop()
+		return "", 0, fmt.Errorf("shell session ended; the next command starts a new one: %w", err)
+	}
+
+	timeout := ss.Timeout
+	if timeout <= 0 {
+		timeout = DefaultCommandTimeout
+	}
+	return ss.collect(timeout)
+}
+
+// collect shows and captures the shell's output up to the end marker of the
+// running command, for at most timeout.
+func (ss *ShellSession) collect(timeout time.Duration) (output string, exitCode int, err error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var captured bytes.Buffer
+	show := func(b []byte) {
+		_, _ = os.Stdout.Write(b)
+		captured.Write(b)
+	}
+	pending := ss.early
+	ss.early = nil
+	for {
+		select {
+		case chunk, ok := <-ss.chunks:
+			if !ok {
+				// The command exited the shell.
+				show(pending)
+				exitCode = ss.wait()
+				return truncateOutput(captured.String()), exitCode, nil
+			}
+			pending = append(pending, chunk...)
+			if i := bytes.Index(pending, ss.marker); i >= 0 {
+				rest := pending[i+len(ss.marker):]
+				if j := bytes.IndexByte(rest, markerEnd); j >= 0 {
+					show(pending[:i])
+					// The same read can carry what a background job wrote
+					// next.
+					if after := rest[j+1:]; len(after) > 0 {
+						ss.early = bytes.Clone(after)
+					}
+					exitCode = ss.parseMarker(string(rest[:j]))
+					return truncateOutput(captured.String()), exitCode, nil
+				}
+				show(pending[:i])
+				pending = pending[i:]
+				continue
+			}
+			// Hold back a tail that may be the start of the marker.
+			keep := partialPrefix(pending, ss.marker)
+			show(pending[:len(pending)-keep])
+			pending = pending[len(pending)-keep:]
+		case <-timer.C:
+			show(pending)
+			ss.stop()
+			return truncateOutput(captured.String()), 0, fmt.Errorf("command timed out after %s; the shell session was restarted", timeout)
+		}
+	}
+}
+
+// parseMarker reads "status:pwd" from an end marker and returns the status.
+func (ss *ShellSession) parseMarker(body string) int {
+	status, dir, _ := strings.Cut(body, ":")
+	if dir != "" {
+		ss.dir = dir
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return -1
+	}
+	return code
+}
+
+// partialPrefix returns the length of the longest suffix of b that is a
+// proper prefix of marker.
+func partialPrefix(b, marker []byte) int {
+	for n := min(len(b), len(marker)-1); n > 0; n-- {
+		if bytes.HasSuffix(b, marker[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// wait collects the exit status of a shell that has exited and releases
+// the session.
+func (ss *ShellSession) wait() int {
+	err := ss.cmd.Wait()
+	ss.release()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 0
+}
+
+// stop kills the shell with everything it started and releases the
+// session.
+func (ss *ShellSession) stop() {
+	if ss.cmd == nil {
+		return
+	}
+	_ = syscall.Kill(-ss.cmd.Process.Pid, syscall.SIGKILL)
+	_ = ss.cmd.Wait()
+	ss.release()
+}
+
+func (ss *ShellSession) release() {
+	close(ss.done)
+	_ = ss.control.Close()
+	_ = os.RemoveAll(ss.tmpDir)
+	ss.cmd, ss.control, ss.early = nil, nil, nil
+}
+
+// WorkDir implements Runner.
+func (ss *ShellSession) WorkDir() string { return ss.dir }
+
+// Reset implements Runner: the shell is stopped, and the next command
+// starts a fresh one in sb's own directory.
+func (ss *ShellSession) Reset() error {
+	ss.stop()
+	ss.dir = ""
+	return nil
+}
+
+// Close implements Runner.
+func (ss *ShellSession) Close() error {
+	ss.stop()
+	return nil
+}
@@ -0,0 +1,285 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShellSessionKeepsShellState(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	ss := NewShellSession(0)
+	defer func() { _ = ss.Close() }()
+	dir := t.TempDir()
+
+	steps := []struct {
+		command  string
+		want     string
+		wantCode int
+	}{
+		{"SB_VAR=kept; greet() { printf 'hi %s' \"$1\"; }", "", 0},
+		{"cd '" + dir + "'", "", 0},
+		{"greet \"$SB_VAR\"", "hi kept", 0},
+		{"pwd", dir + "\n", 0},
+		{"echo oops >&2; false", "oops\n", 1},
+		{"(exit 7)", "", 7},
+	}
+	for _, step := range steps {
+		output, code, err := ss.RunCapture(step.command)
+		if err != nil {
+			t.Fatalf("RunCapture(%q) error: %v", step.command, err)
+		}
+		if output != step.want || code != step.wantCode {
+			t.Errorf("RunCapture(%q) = %q, %d; want %q, %d", step.command, output, code, step.want, step.wantCode)
+		}
+	}
+	if ss.WorkDir() != dir {
+		t.Errorf("WorkDir() = %q, want %q", ss.WorkDir(), dir)
+	}
+}
+
+func TestShellSessionAliases(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	t.Setenv("SHELL", bash)
+	ss := NewShellSession(0)
+	defer func() { _ = ss.Close() }()
+
+	if _, _, err := ss.RunCapture("alias sbhello='echo hello from alias'"); err != nil {
+		t.Fatalf("RunCapture() error: %v", err)
+	}
+	output, code, err := ss.RunCapture("sbhello")
+	if err != nil || code != 0 || output != "hello from alias\n" {
+		t.Errorf("RunCapture(alias) = %q, %d, %v; want the alias expanded", output, code, err)
+	}
+}
+
+func TestShellSessionExitRestarts(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	ss := NewShellSession(0)
+	defer func() { _ = ss.Close() }()
+
+	if _, _, err := ss.RunCapture("SB_VAR=lost"); err != nil {
+		t.Fatalf("RunCapture() error: %v", err)
+	}
+	output, code, err := ss.RunCapture("echo bye; exit 4")
+	if err != nil || code != 4 || output != "bye\n" {
+		t.Errorf("RunCapture(exit) = %q, %d, %v; want bye, 4", output, code, err)
+	}
+	output, _, err = ss.RunCapture(`printf '%s' "${SB_VAR-unset}"`)
+	if err != nil || output != "unset" {
+		t.Errorf("after exit, RunCapture() = %q, %v; want a fresh shell", output, err)
+	}
+}
+
+func TestShellSessionTimeout(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	ss := &ShellSession{Timeout: 200 * time.Millisecond}
+	defer func() { _ = ss.Close() }()
+
+	if _, _, err := ss.RunCapture("SB_VAR=lost"); err != nil {
+		t.Fatalf("RunCapture() error: %v", err)
+	}
+	output, _, err := ss.RunCapture("echo started; while :; do :; done")
+	if err == nil || !strings.Contains(err.Error(), "timed out after 200ms") {
+		t.Fatalf("RunCapture() error = %v, want timeout", err)
+	}
+	if output != "started\n" {
+		t.Errorf("output before timeout = %q, want %q", output, "started\n")
+	}
+	output, _, err = ss.RunCapture(`printf '%s' "${SB_VAR-unset}"`)
+	if err != nil || output != "unset" {
+		t.Errorf("after timeout, RunCapture() = %q, %v; want a fresh shell", output, err)
+	}
+}
+
+func TestShellSessionTimeoutKillsCommand(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	ss := &ShellSession{Timeout: 500 * time.Millisecond}
+	defer func() { _ = ss.Close() }()
+
+	// exec keeps the pid, so the printed pid is the sleep's.
+	output, _, err := ss.RunCapture(`sh -c 'echo $$; exec sleep 37'`)
+	if err == nil {
+		t.Fatal("RunCapture() should time out")
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		t.Fatalf("output = %q, want the child's pid", output)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("process %d started by the command outlived the timeout", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestShellSessionResetKillsBackgroundJobs(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	ss := NewShellSession(0)
+	defer func() { _ = ss.Close() }()
+
+	output, _, err := ss.RunCapture(`sleep 37 & echo $!`)
+	if err != nil {
+		t.Fatalf("RunCapture() error: %v", err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(output))
+	if err != nil {
+		t.Fatalf("output = %q, want the job's pid", output)
+	}
+	if err := ss.Reset(); err != nil {
+		t.Fatalf("Reset() error: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+			t.Fatalf("background job %d outlived Reset()", pid)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// processAlive reports whether pid runs; a zombie waiting to be reaped by
+// init does not count.
+func processAlive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true // no /proc (macOS): signal 0 is all there is
+	}
+	_, rest, _ := strings.Cut(string(stat), ") ")
+	return !strings.HasPrefix(rest, "Z")
+}
+
+func TestShellSessionReset(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	ss := NewShellSession(0)
+	defer func() { _ = ss.Close() }()
+
+	if _, _, err := ss.RunCapture("SB_VAR=lost; cd /"); err != nil {
+		t.Fatalf("RunCapture() error: %v", err)
+	}
+	if err := ss.Reset(); err != nil {
+		t.Fatalf("Reset() error: %v", err)
+	}
+	if ss.WorkDir() != "" {
+		t.Errorf("WorkDir() after Reset() = %q, want empty", ss.WorkDir())
+	}
+	output, _, err := ss.RunCapture(`printf '%s' "${SB_VAR-unset}"`)
+	if err != nil || output != "unset" {
+		t.Errorf("after Reset(), RunCapture() = %q, %v; want a fresh shell", output, err)
+	}
+	// Reset and Close of a stopped session are no-ops.
+	if err := ss.Close(); err != nil {
+		t.Errorf("Close() error: %v", err)
+	}
+	if err := ss.Reset(); err != nil {
+		t.Errorf("Reset() after Close() error: %v", err)
+	}
+}
+
+func TestShellSessionLargeOutput(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	ss := NewShellSession(0)
+	defer func() { _ = ss.Close() }()
+
+	output, code, err := ss.RunCapture("i=0; while [ $i -lt 2000 ]; do echo 'line of output'; i=$((i+1)); done")
+	if err != nil || code != 0 {
+		t.Fatalf("RunCapture() = %d, %v", code, err)
+	}
+	if !strings.HasSuffix(output, "[output truncated]") {
+		t.Errorf("output should end with truncation marker, got %q", output[len(output)-50:])
+	}
+}
+
+func TestShellSessionStartError(t *testing.T) {
+	t.Setenv("SHELL", "/nonexistent/shell")
+	ss := NewShellSession(0)
+	if _, _, err := ss.RunCapture("true"); err == nil || !strings.Contains(err.Error(), "starting shell session") {
+		t.Errorf("RunCapture() error = %v, want start error", err)
+	}
+}
+
+func TestShellReset(t *testing.T) {
+	sh := &Shell{Dir: "/tmp", Env: []string{"A=1"}}
+	if sh.WorkDir() != "/tmp" {
+		t.Errorf("WorkDir() = %q, want /tmp", sh.WorkDir())
+	}
+	if err := sh.Reset(); err != nil || sh.Dir != "" || sh.Env != nil {
+		t.Errorf("Reset() = %v, state %q, %q; want it cleared", err, sh.Dir, sh.Env)
+	}
+	if err := sh.Close(); err != nil {
+		t.Errorf("Close() error: %v", err)
+	}
+}
+
+func TestPartialPrefix(t *testing.T) {
+	marker := []byte("\x1esb-end-ab:")
+	tests := []struct {
+		name string
+		b    string
+		want int
+	}{
+		{"none", "output\n", 0},
+		{"marker start", "output\x1e", 1},
+		{"most of marker", "output\x1esb-end-ab", 10},
+		{"unrelated byte", "output\x1ex", 0},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := partialPrefix([]byte(tt.b), marker); got != tt.want {
+				t.Errorf("partialPrefix(%q) = %d, want %d", tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionProgram(t *testing.T) {
+	if got := sessionProgram("/usr/bin/fish", "ab"); !strings.Contains(got, "source $__sb_file") || !strings.Contains(got, "$status") {
+		t.Errorf("sessionProgram(fish) = %q, want fish syntax", got)
+	}
+	if got := sessionProgram("/bin/bash", "ab"); !strings.Contains(got, `. "$__sb_file"`) || !strings.Contains(got, "sb-end-ab") {
+		t.Errorf("sessionProgram(bash) = %q, want POSIX syntax with the nonce", got)
+	}
+}
+
+func TestParseMarker(t *testing.T) {
+	ss := &ShellSession{dir: "/before"}
+	if code := ss.parseMarker("2:/a:b"); code != 2 || ss.dir != "/a:b" {
+		t.Errorf("parseMarker() = %d, dir %q; want 2, /a:b", code, ss.dir)
+	}
+	if code := ss.parseMarker("x"); code != -1 || ss.dir != "/a:b" {
+		t.Errorf("parseMarker(bad) = %d, dir %q; want -1 and the dir kept", code, ss.dir)
+	}
+}
+
+func TestShellSessionKeepsOutputAfterMarker(t *testing.T) {
+	ss := &ShellSession{marker: []byte("\x1esb-end-ab:"), chunks: make(chan []byte, 2)}
+	// A background job's output arrives in the same read as the first end
+	// marker.
+	ss.chunks <- []byte("first\n\x1esb-end-ab:0:/a\x1ejob done\n")
+	ss.chunks <- []byte("second\n\x1esb-end-ab:3:/b\x1e")
+
+	if output, code, _ := ss.collect(time.Second); output != "first\n" || code != 0 {
+		t.Errorf("collect() = %q, %d; want the first command's output", output, code)
+	}
+	if output, code, _ := ss.collect(time.Second); output != "job done\nsecond\n" || code != 3 {
+		t.Errorf("collect() = %q, %d; want the held output first", output, code)
+	}
+	if ss.early != nil || ss.dir != "/b" {
+		t.Errorf("early = %q, dir = %q after the second command", ss.early, ss.dir)
+	}
+}
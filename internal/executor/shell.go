@@ -0,0 +1,89 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hpkotak/shellbud/internal/platform"
+)
+
+// Shell carries the working directory and exported environment from one
+// command to the next, so `cd build` followed by `make` runs make in build
+// even though each command gets a fresh $SHELL -c.
+//
+// After the command, a sentinel appended to the script writes `pwd` and
+// `env -0` to a temp file, and the exit status of the command is kept. A
+// command that exits the shell early skips the sentinel and leaves the state
+// as it was; so does a system without `env -0`, for the environment.
+type Shell struct {
+	// Dir is the working directory; empty means sb's own.
+	Dir string
+	// Env is the environment as KEY=VALUE pairs; nil means sb's own.
+	Env []string
+}
+
+// shellOwnedVars are set by every shell itself, so carrying them over would
+// be wrong (PWD) or make them drift (SHLVL grows by one per command).
+var shellOwnedVars = []string{"_", "PWD", "SHLVL"}
+
+// RunCapture is the package-level RunCapture, run in the shell's directory
+// and environment. Afterwards the shell holds the directory and environment
+// the command left behind.
+func (sh *Shell) RunCapture(command string) (output string, exitCode int, err error) {
+	state, err := os.CreateTemp("", "sb-state-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("creating state file: %w", err)
+	}
+	_ = state.Close()
+	defer func() { _ = os.Remove(state.Name()) }()
+
+	shell := platform.Shell()
+	// The blank line ends a trailing line continuation in command, which
+	// would otherwise join the sentinel to it, without resetting $?.
+	cmd := exec.Command(shell, "-c", command+"\n\n"+sentinel(shell, state.Name()))
+	cmd.Dir = sh.Dir
+	cmd.Env = sh.Env
+
+	output, exitCode, err = capture(cmd)
+	if err != nil {
+		return "", 0, err
+	}
+	if data, err := os.ReadFile(state.Name()); err == nil {
+		sh.update(string(data))
+	}
+	return output, exitCode, nil
+}
+
+// sentinel returns the script that records the state to path while keeping
+// the command's exit status.
+func sentinel(shell, path string) string {
+	quoted := "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+	if filepath.Base(shell) == "fish" {
+		return "set __sb_status $status; begin; pwd; env -0; end > " + quoted + " 2>/dev/null; exit $__sb_status"
+	}
+	return "__sb_status=$?; { pwd; env -0; } > " + quoted + " 2>/dev/null; exit $__sb_status"
+}
+
+// update applies a recorded state: the directory on the first line, then
+// the NUL-separated environment.
+func (sh *Shell) update(state string) {
+	dir, env, _ := strings.Cut(state, "\n")
+	if dir != "" {
+		sh.Dir = dir
+	}
+	var vars []string
+	for _, kv := range strings.Split(env, "\x00") {
+		name, _, ok := strings.Cut(kv, "=")
+		if !ok || name == "" || slices.Contains(shellOwnedVars, name) {
+			continue
+		}
+		vars = append(vars, kv)
+	}
+	if len(vars) > 0 {
+		sh.Env = vars
+	}
+}
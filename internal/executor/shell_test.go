@@ -0,0 +1,114 @@
+package executor
+
+import (
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestShellCarriesDirAndEnv(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	dir := t.TempDir()
+	sh := &Shell{}
+
+	if _, code, err := sh.RunCapture("cd '" + dir + "' && export SB_TEST_VAR='two\nlines'"); err != nil || code != 0 {
+		t.Fatalf("RunCapture() = %d, %v", code, err)
+	}
+	if sh.Dir != dir {
+		t.Errorf("Dir = %q, want %q", sh.Dir, dir)
+	}
+	if !slices.Contains(sh.Env, "SB_TEST_VAR=two\nlines") {
+		t.Errorf("Env is missing the exported variable: %q", sh.Env)
+	}
+	for _, kv := range sh.Env {
+		if strings.HasPrefix(kv, "SHLVL=") || strings.HasPrefix(kv, "PWD=") {
+			t.Errorf("Env carries shell-owned %q", kv)
+		}
+	}
+
+	output, code, err := sh.RunCapture(`pwd; printf '%s\n' "$SB_TEST_VAR"; false`)
+	if err != nil {
+		t.Fatalf("RunCapture() error: %v", err)
+	}
+	if want := dir + "\ntwo\nlines\n"; output != want {
+		t.Errorf("output = %q, want %q", output, want)
+	}
+	if code != 1 {
+		t.Errorf("exit code = %d, want the command's status 1", code)
+	}
+}
+
+func TestShellExitKeepsState(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	dir := t.TempDir()
+	sh := &Shell{Dir: dir}
+
+	_, code, err := sh.RunCapture("cd / && exit 3")
+	if err != nil {
+		t.Fatalf("RunCapture() error: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	if sh.Dir != dir || sh.Env != nil {
+		t.Errorf("state = %q, %d vars; want it unchanged", sh.Dir, len(sh.Env))
+	}
+}
+
+func TestShellTrailingBackslash(t *testing.T) {
+	t.Setenv("SHELL", "/bin/sh")
+	dir := t.TempDir()
+	sh := &Shell{}
+
+	output, code, err := sh.RunCapture("cd '" + dir + "' && echo done \\")
+	if err != nil || code != 0 {
+		t.Fatalf("RunCapture() = %d, %v", code, err)
+	}
+	if output != "done\n" {
+		t.Errorf("output = %q, want the sentinel kept out of the command", output)
+	}
+	if sh.Dir != dir {
+		t.Errorf("Dir = %q, want %q", sh.Dir, dir)
+	}
+
+	if _, code, _ := sh.RunCapture("false \\"); code != 1 {
+		t.Errorf("exit code = %d, want the command's status 1", code)
+	}
+}
+
+func TestShellMissingDir(t *testing.T) {
+	sh := &Shell{Dir: filepath.Join(t.TempDir(), "gone")}
+	if _, _, err := sh.RunCapture("true"); err == nil || !strings.Contains(err.Error(), "executing command") {
+		t.Errorf("RunCapture() error = %v, want execution error", err)
+	}
+}
+
+func TestSentinelFish(t *testing.T) {
+	fish, err := exec.LookPath("fish")
+	if err != nil {
+		if got := sentinel("/usr/bin/fish", "/tmp/it's"); !strings.Contains(got, "$status") || !strings.Contains(got, `'/tmp/it'\''s'`) {
+			t.Errorf("sentinel() = %q, want fish syntax with a quoted path", got)
+		}
+		t.Skip("fish not installed")
+	}
+	t.Setenv("SHELL", fish)
+	sh := &Shell{}
+	dir := t.TempDir()
+	if _, code, err := sh.RunCapture("cd " + dir + "; false"); err != nil || code != 1 {
+		t.Fatalf("RunCapture() = %d, %v", code, err)
+	}
+	if sh.Dir != dir {
+		t.Errorf("Dir = %q, want %q", sh.Dir, dir)
+	}
+}
+
+func TestShellUpdateIgnoresEmptyState(t *testing.T) {
+	sh := &Shell{Dir: "/keep", Env: []string{"A=1"}}
+	sh.update("")
+	sh.update("\n")
+	if sh.Dir != "/keep" || len(sh.Env) != 1 {
+		t.Errorf("state = %q, %q; want it unchanged", sh.Dir, sh.Env)
+	}
+}
@@ -0,0 +1,123 @@
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// History is the input history, persisted one entry per line. Entries are
+// escaped so a multi-line entry still takes one line in the file.
+type History struct {
+	path    string
+	max     int
+	entries []string
+	// fileLines counts lines in the file, which may exceed len(entries)
+	// until the file is compacted.
+	fileLines int
+}
+
+// LoadHistory reads the history at path, keeping the newest max entries. A
+// missing file is an empty history. An empty path keeps history in memory.
+func LoadHistory(path string, max int) (*History, error) {
+	h := &History{path: path, max: max}
+	if path == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return h, nil
+		}
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		h.fileLines++
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, unescape(line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+	}
+	return h, nil
+}
+
+// Entries returns the history, oldest first.
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Add appends line to the history and the history file. Blank lines and
+// repeats of the previous entry are skipped. The file is rewritten once it
+// holds twice the entries kept, so it stays bounded without a rewrite on
+// every line.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return nil
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+	if h.path == "" {
+		return nil
+	}
+
+	if h.fileLines+1 > 2*h.max {
+		return h.compact()
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err != nil {
+		return fmt.Errorf("creating history dir: %w", err)
+	}
+	f, err := os.OpenFile(h.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.WriteString(escape(line) + "\n"); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	h.fileLines++
+	return nil
+}
+
+// compact rewrites the history file with only the kept entries.
+func (h *History) compact() error {
+	var b strings.Builder
+	for _, e := range h.entries {
+		b.WriteString(escape(e))
+		b.WriteByte('\n')
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	h.fileLines = len(h.entries)
+	return nil
+}
+
+var (
+	escaper   = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	unescaper = strings.NewReplacer(`\\`, `\`, `\n`, "\n")
+)
+
+func escape(s string) string   { return escaper.Replace(s) }
+func unescape(s string) string { return unescaper.Replace(s) }
@@ -0,0 +1,112 @@
+package lineedit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryAdd(t *testing.T) {
+	h, err := LoadHistory("", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"a", "", "  ", "b", "b", "c", "d"} {
+		if err := h.Add(line); err != nil {
+			t.Fatalf("Add(%q): %v", line, err)
+		}
+	}
+	if got := strings.Join(h.Entries(), ","); got != "b,c,d" {
+		t.Errorf("Entries() = %q, want %q", got, "b,c,d")
+	}
+}
+
+func TestHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "chat_history")
+
+	h, err := LoadHistory(path, 10)
+	if err != nil {
+		t.Fatalf("LoadHistory() of missing file: %v", err)
+	}
+	for _, line := range []string{"ls", `printf 'a\nb'`, "multi\nline"} {
+		if err := h.Add(line); err != nil {
+			t.Fatalf("Add(): %v", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("history file mode = %o, want 600", perm)
+	}
+
+	reloaded, err := LoadHistory(path, 10)
+	if err != nil {
+		t.Fatalf("LoadHistory(): %v", err)
+	}
+	want := []string{"ls", `printf 'a\nb'`, "multi\nline"}
+	got := reloaded.Entries()
+	if len(got) != len(want) {
+		t.Fatalf("Entries() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Entries()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestHistoryCompacts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat_history")
+	h, err := LoadHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"1", "2", "3", "4", "5"} {
+		if err := h.Add(line); err != nil {
+			t.Fatalf("Add(): %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines > 4 {
+		t.Errorf("history file has %d lines, want at most 4", lines)
+	}
+	reloaded, err := LoadHistory(path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(reloaded.Entries(), ","); got != "4,5" {
+		t.Errorf("Entries() = %q, want %q", got, "4,5")
+	}
+}
+
+func TestHistoryErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadHistory(dir, 10); err == nil {
+		t.Error("LoadHistory() of a directory should fail")
+	}
+
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Reading succeeds (nothing there yet) but writing cannot.
+	h, err := LoadHistory(filepath.Join(dir, "missing", "chat_history"), 10)
+	if err != nil {
+		t.Fatalf("LoadHistory(): %v", err)
+	}
+	h.path = filepath.Join(blocker, "chat_history")
+	if err := h.Add("ls"); err == nil {
+		t.Error("Add() under a file should fail")
+	}
+	if got := h.Entries(); len(got) != 1 {
+		t.Errorf("Entries() = %q, want the line kept in memory", got)
+	}
+}
@@ -0,0 +1,568 @@
+// Package lineedit is a small emacs-style line editor for the chat prompt.
+//
+// It reads keys from a terminal in raw mode and redraws the line itself, so
+// arrow keys move the cursor instead of printing escape codes. It supports
+// the common readline bindings, history recall and reverse search, and tab
+// completion through a caller-supplied Completer. Long lines are not
+// wrapped specially; the prompt is a single short line in practice.
+//
+// Pasted text arrives in one piece: NewTerminal turns on bracketed paste,
+// and the pasted lines are inserted into the line, newlines included, instead
+// of each newline submitting it.
+//
+// The editor works on any io.Reader and io.Writer so tests can drive it with
+// scripted key sequences; NewTerminal adds raw mode for a real TTY.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// Completer returns completions for the word that ends at the cursor. line
+// is the text before the cursor; start is the byte offset in line where the
+// word being completed begins. Each candidate replaces line[start:].
+type Completer func(line string) (start int, candidates []string)
+
+// Editor reads lines with editing, history and completion.
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// History, when set, is offered by up/down and Ctrl-R. ReadLine does
+	// not add to it; callers decide which lines are worth keeping.
+	History *History
+	// Complete, when set, is called on Tab.
+	Complete Completer
+	// raw switches the terminal to raw mode and returns a restore func.
+	raw func() (func(), error)
+	// kill holds the last killed text for Ctrl-Y.
+	kill []rune
+}
+
+// New returns an Editor reading keys from in and drawing on out. The caller
+// is responsible for putting the terminal in raw mode.
+func New(in io.Reader, out io.Writer) *Editor {
+	return &Editor{in: bufio.NewReader(in), out: out}
+}
+
+// NewTerminal returns an Editor for the terminal f, switching it to raw mode
+// only while a line is being read so command output in between is
+// unaffected.
+func NewTerminal(f *os.File, out io.Writer) *Editor {
+	e := New(f, out)
+	fd := int(f.Fd())
+	e.raw = func() (func(), error) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return nil, err
+		}
+		e.write(bracketedPasteOn)
+		return func() {
+			e.write(bracketedPasteOff)
+			_ = term.Restore(fd, state)
+		}, nil
+	}
+	return e
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// Control keys.
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlJ     = 10
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlT     = 20
+	ctrlU     = 21
+	ctrlW     = 23
+	ctrlY     = 25
+	esc       = 27
+	backspace = 127
+)
+
+const (
+	bracketedPasteOn  = "\x1b[?2004h"
+	bracketedPasteOff = "\x1b[?2004l"
+	pasteEnd          = "\x1b[201~"
+)
+
+// Keys decoded from escape sequences, outside the Unicode range.
+const (
+	keyUp rune = unicode.MaxRune + 1 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyAltB
+	keyAltF
+	keyAltD
+	keyAltBackspace
+	keyPaste
+	keyUnknown
+)
+
+// line is the state of one ReadLine call.
+type line struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int
+	// histIdx is the history entry shown; len(entries) means the draft.
+	histIdx int
+	draft   []rune
+	lastTab bool
+	// Reverse search state.
+	searching bool
+	query     []rune
+	matchIdx  int
+	failing   bool
+}
+
+// ReadLine prints prompt and returns the edited line. It returns io.EOF on
+// Ctrl-D at an empty line and ErrInterrupted on Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	return e.EditLine(prompt, "")
+}
+
+// EditLine is ReadLine with text already in the line and the cursor at its
+// end, for changing a suggestion instead of retyping it.
+func (e *Editor) EditLine(prompt, text string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", fmt.Errorf("entering raw mode: %w", err)
+		}
+		defer restore()
+	}
+
+	l := &line{e: e, prompt: prompt, histIdx: len(e.history())}
+	l.insert([]rune(text))
+	l.refresh()
+	for {
+		k, err := e.readKey()
+		if err != nil {
+			if errors.Is(err, io.EOF) && len(l.buf) > 0 {
+				e.write("\r\n")
+				return string(l.buf), nil
+			}
+			return "", err
+		}
+		text, done, err := l.handle(k)
+		if done || err != nil {
+			return text, err
+		}
+	}
+}
+
+// readKey reads one key, decoding escape sequences.
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != esc {
+		return r, err
+	}
+
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return esc, nil
+	}
+	switch next {
+	case 'b':
+		return keyAltB, nil
+	case 'f':
+		return keyAltF, nil
+	case 'd':
+		return keyAltD, nil
+	case backspace, ctrlH:
+		return keyAltBackspace, nil
+	case '[', 'O':
+	default:
+		return keyUnknown, nil
+	}
+
+	// CSI/SS3: parameter bytes, then a final byte in 0x40-0x7E.
+	var params strings.Builder
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return keyUnknown, nil
+		}
+		if c >= 0x40 && c <= 0x7e {
+			return csiKey(params.String(), c), nil
+		}
+		params.WriteRune(c)
+	}
+}
+
+func csiKey(params string, final rune) rune {
+	switch final {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch params {
+		case "1", "7":
+			return keyHome
+		case "4", "8":
+			return keyEnd
+		case "3":
+			return keyDelete
+		case "200":
+			return keyPaste
+		}
+	}
+	return keyUnknown
+}
+
+// handle applies one key. done reports that the line is finished.
+func (l *line) handle(k rune) (text string, done bool, err error) {
+	if l.searching {
+		if text, done, handled := l.handleSearch(k); handled {
+			return text, done, nil
+		}
+	}
+	wasTab := l.lastTab
+	l.lastTab = false
+
+	switch k {
+	case enter, ctrlJ:
+		l.e.write("\r\n")
+		return string(l.buf), true, nil
+	case ctrlC:
+		l.e.write("^C\r\n")
+		return "", true, ErrInterrupted
+	case ctrlD:
+		if len(l.buf) == 0 {
+			return "", true, io.EOF
+		}
+		l.deleteRange(l.pos, l.pos+1)
+	case ctrlA, keyHome:
+		l.pos = 0
+	case ctrlE, keyEnd:
+		l.pos = len(l.buf)
+	case ctrlB, keyLeft:
+		l.pos = max(l.pos-1, 0)
+	case ctrlF, keyRight:
+		l.pos = min(l.pos+1, len(l.buf))
+	case keyAltB:
+		l.pos = l.wordStart()
+	case keyAltF:
+		l.pos = l.wordEnd()
+	case ctrlH, backspace:
+		l.deleteRange(l.pos-1, l.pos)
+	case keyDelete:
+		l.deleteRange(l.pos, l.pos+1)
+	case ctrlK:
+		l.killRange(l.pos, len(l.buf))
+	case ctrlU:
+		l.killRange(0, l.pos)
+	case ctrlW, keyAltBackspace:
+		l.killRange(l.wordStart(), l.pos)
+	case keyAltD:
+		l.killRange(l.pos, l.wordEnd())
+	case ctrlY:
+		l.insert(l.e.kill)
+	case ctrlT:
+		l.transpose()
+	case ctrlP, keyUp:
+		l.recall(l.histIdx - 1)
+	case ctrlN, keyDown:
+		l.recall(l.histIdx + 1)
+	case ctrlR:
+		l.startSearch()
+	case ctrlL:
+		l.e.write("\x1b[H\x1b[2J")
+	case keyPaste:
+		l.insert(l.e.readPaste())
+	case tab:
+		l.complete(wasTab)
+		l.lastTab = true
+	default:
+		if k < ' ' || k > unicode.MaxRune || k == backspace {
+			// Unbound control key or unknown escape sequence.
+			return "", false, nil
+		}
+		l.insert([]rune{k})
+	}
+	l.refresh()
+	return "", false, nil
+}
+
+// readPaste reads pasted text up to the end marker, with line endings
+// normalized to "\n".
+func (e *Editor) readPaste() []rune {
+	var b strings.Builder
+	for !strings.HasSuffix(b.String(), pasteEnd) {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return []rune(normalizeNewlines(b.String()))
+		}
+		b.WriteRune(r)
+	}
+	return []rune(normalizeNewlines(strings.TrimSuffix(b.String(), pasteEnd)))
+}
+
+func normalizeNewlines(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\r", "\n")
+}
+
+func (l *line) insert(rs []rune) {
+	buf := make([]rune, 0, len(l.buf)+len(rs))
+	buf = append(buf, l.buf[:l.pos]...)
+	buf = append(buf, rs...)
+	l.buf = append(buf, l.buf[l.pos:]...)
+	l.pos += len(rs)
+}
+
+func (l *line) deleteRange(from, to int) {
+	from, to = max(from, 0), min(to, len(l.buf))
+	if from >= to {
+		return
+	}
+	l.buf = append(l.buf[:from], l.buf[to:]...)
+	if l.pos > from {
+		l.pos = max(from, l.pos-(to-from))
+	}
+}
+
+func (l *line) killRange(from, to int) {
+	if from >= to {
+		return
+	}
+	l.e.kill = append([]rune(nil), l.buf[from:to]...)
+	l.deleteRange(from, to)
+}
+
+func (l *line) transpose() {
+	if len(l.buf) < 2 || l.pos == 0 {
+		return
+	}
+	if l.pos == len(l.buf) {
+		l.pos--
+	}
+	l.buf[l.pos-1], l.buf[l.pos] = l.buf[l.pos], l.buf[l.pos-1]
+	l.pos++
+}
+
+// wordStart returns the start of the word before the cursor.
+func (l *line) wordStart() int {
+	i := l.pos
+	for i > 0 && unicode.IsSpace(l.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !unicode.IsSpace(l.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor.
+func (l *line) wordEnd() int {
+	i := l.pos
+	for i < len(l.buf) && unicode.IsSpace(l.buf[i]) {
+		i++
+	}
+	for i < len(l.buf) && !unicode.IsSpace(l.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// recall shows history entry idx, keeping the line being typed as a draft.
+func (l *line) recall(idx int) {
+	entries := l.e.history()
+	if idx < 0 || idx > len(entries) || idx == l.histIdx {
+		return
+	}
+	if l.histIdx == len(entries) {
+		l.draft = append([]rune(nil), l.buf...)
+	}
+	l.histIdx = idx
+	if idx == len(entries) {
+		l.buf = append([]rune(nil), l.draft...)
+	} else {
+		l.buf = []rune(entries[idx])
+	}
+	l.pos = len(l.buf)
+}
+
+// complete runs the completer. A unique match is inserted with a trailing
+// space (unless it is a directory); several matches extend to their common
+// prefix, and a second Tab lists them.
+func (l *line) complete(listing bool) {
+	if l.e.Complete == nil {
+		return
+	}
+	before := string(l.buf[:l.pos])
+	start, candidates := l.e.Complete(before)
+	if len(candidates) == 0 || start < 0 || start > len(before) {
+		l.e.write("\a")
+		return
+	}
+	word := before[start:]
+	startRune := utf8.RuneCountInString(before[:start])
+
+	replacement := commonPrefix(candidates)
+	if len(candidates) == 1 && !strings.HasSuffix(replacement, "/") {
+		replacement += " "
+	}
+	if replacement != word && strings.HasPrefix(replacement, word) {
+		l.buf = append(append(append([]rune(nil), l.buf[:startRune]...), []rune(replacement)...), l.buf[l.pos:]...)
+		l.pos = startRune + utf8.RuneCountInString(replacement)
+		return
+	}
+	if listing {
+		l.e.write("\r\n" + strings.Join(candidates, "  ") + "\r\n")
+		return
+	}
+	l.e.write("\a")
+}
+
+func commonPrefix(ss []string) string {
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+func (l *line) startSearch() {
+	l.searching = true
+	l.draft = append([]rune(nil), l.buf...)
+	l.query = nil
+	l.matchIdx = len(l.e.history())
+	l.failing = false
+}
+
+// handleSearch applies a key in reverse search. handled is false for keys
+// that end the search and should then be applied to the found line.
+func (l *line) handleSearch(k rune) (text string, done, handled bool) {
+	switch {
+	case k == ctrlR:
+		l.search(l.matchIdx - 1)
+	case k == ctrlH || k == backspace:
+		if len(l.query) > 0 {
+			l.query = l.query[:len(l.query)-1]
+		}
+		l.search(len(l.e.history()) - 1)
+	case k == ctrlG || k == ctrlC:
+		l.searching = false
+		l.buf = l.draft
+		l.pos = len(l.buf)
+	case k == enter || k == ctrlJ:
+		l.searching = false
+		return "", false, false
+	case k >= ' ' && k <= unicode.MaxRune && k != backspace:
+		l.query = append(l.query, k)
+		l.search(min(l.matchIdx, len(l.e.history())-1))
+	default:
+		l.searching = false
+		l.pos = len(l.buf)
+		return "", false, false
+	}
+	l.refresh()
+	return "", false, true
+}
+
+// search finds the newest entry at or before from containing the query and
+// shows it.
+func (l *line) search(from int) {
+	entries := l.e.history()
+	q := string(l.query)
+	for i := from; i >= 0; i-- {
+		if idx := strings.Index(entries[i], q); idx >= 0 {
+			l.matchIdx = i
+			l.failing = false
+			l.buf = []rune(entries[i])
+			l.pos = utf8.RuneCountInString(entries[i][:idx])
+			return
+		}
+	}
+	l.failing = true
+}
+
+// refresh redraws the prompt and line and places the cursor.
+func (l *line) refresh() {
+	prompt := l.prompt
+	if l.searching {
+		label := "reverse-i-search"
+		if l.failing {
+			label = "failing " + label
+		}
+		prompt = fmt.Sprintf("(%s)`%s': ", label, string(l.query))
+	}
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(prompt)
+	b.WriteString(display(l.buf))
+	b.WriteString("\x1b[K")
+	if n := len(l.buf) - l.pos; n > 0 {
+		fmt.Fprintf(&b, "\x1b[%dD", n)
+	}
+	l.e.write(b.String())
+}
+
+// display renders the line on one row: newlines show as ↵ and tabs as a
+// space, so every rune takes one column and cursor moves stay simple.
+func display(buf []rune) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\n':
+			return '↵'
+		case '\t':
+			return ' '
+		}
+		return r
+	}, string(buf))
+}
+
+func (e *Editor) history() []string {
+	if e.History == nil {
+		return nil
+	}
+	return e.History.Entries()
+}
+
+func (e *Editor) write(s string) {
+	_, _ = io.WriteString(e.out, s)
+}
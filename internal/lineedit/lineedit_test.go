@@ -0,0 +1,310 @@
+package lineedit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Key sequences as a terminal sends them.
+const (
+	up       = "\x1b[A"
+	down     = "\x1b[B"
+	right    = "\x1b[C"
+	left     = "\x1b[D"
+	home     = "\x1b[H"
+	end      = "\x1b[F"
+	del      = "\x1b[3~"
+	altB     = "\x1bb"
+	altF     = "\x1bf"
+	altD     = "\x1bd"
+	altBS    = "\x1b\x7f"
+	bs       = "\x7f"
+	cr       = "\r"
+	ctrlASeq = "\x01"
+	ctrlESeq = "\x05"
+	ctrlKSeq = "\x0b"
+	ctrlUSeq = "\x15"
+	ctrlWSeq = "\x17"
+	ctrlYSeq = "\x19"
+	ctrlTSeq = "\x14"
+	ctrlRSeq = "\x12"
+	ctrlGSeq = "\x07"
+)
+
+func newEditor(t *testing.T, keys string, history ...string) (*Editor, *bytes.Buffer) {
+	t.Helper()
+	h, _ := LoadHistory("", 100)
+	for _, entry := range history {
+		if err := h.Add(entry); err != nil {
+			t.Fatalf("Add(): %v", err)
+		}
+	}
+	out := &bytes.Buffer{}
+	e := New(strings.NewReader(keys), out)
+	e.History = h
+	return e, out
+}
+
+func TestReadLineEditing(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"plain", "ls -la" + cr, "ls -la"},
+		{"newline ends line", "pwd\n", "pwd"},
+		{"backspace", "lss" + bs + cr, "ls"},
+		{"backspace at start", bs + "ls" + cr, "ls"},
+		{"insert mid-line", "ls" + left + left + "x" + cr, "xls"},
+		{"home and end", "b" + home + "a" + end + "c" + cr, "abc"},
+		{"ctrl-a and ctrl-e", "b" + ctrlASeq + "a" + ctrlESeq + "c" + cr, "abc"},
+		{"right past end", "ab" + right + right + "c" + cr, "abc"},
+		{"delete", "abc" + home + del + cr, "bc"},
+		{"ctrl-d deletes under cursor", "abc" + home + "\x04" + cr, "bc"},
+		{"kill to end and yank", "git status" + home + altF + ctrlKSeq + ctrlASeq + ctrlYSeq + cr, " statusgit"},
+		{"kill to start", "foo bar" + left + left + left + ctrlUSeq + cr, "bar"},
+		{"kill word back", "git log --oneline" + ctrlWSeq + cr, "git log "},
+		{"alt-backspace", "git log" + altBS + cr, "git "},
+		{"alt-b then alt-d", "one two three" + altB + altB + altD + cr, "one  three"},
+		{"transpose", "sl" + ctrlTSeq + cr, "ls"},
+		{"transpose mid-line", "abc" + left + ctrlTSeq + cr, "acb"},
+		{"unbound control key ignored", "a\x0fb" + cr, "ab"},
+		{"unknown escape ignored", "a\x1b[5~b\x1bxc" + cr, "abc"},
+		{"unicode", "héllo" + bs + cr, "héll"},
+		{"eof with text returns text", "partial", "partial"},
+		{"bracketed paste keeps newlines", "explain \x1b[200~line one\r\nline two\rthree\x1b[201~" + cr, "explain line one\nline two\nthree"},
+		{"unterminated paste", "\x1b[200~a\rb", "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newEditor(t, tt.keys)
+			got, err := e.ReadLine("sb> ")
+			if err != nil {
+				t.Fatalf("ReadLine(): %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLineEndings(t *testing.T) {
+	e, out := newEditor(t, "\x04")
+	if _, err := e.ReadLine("sb> "); !errors.Is(err, io.EOF) {
+		t.Errorf("Ctrl-D on empty line: err = %v, want io.EOF", err)
+	}
+	if !strings.HasPrefix(out.String(), "\rsb> ") {
+		t.Errorf("prompt not drawn: %q", out.String())
+	}
+
+	e, out = newEditor(t, "rm -rf\x03next"+cr)
+	if _, err := e.ReadLine("sb> "); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("Ctrl-C: err = %v, want ErrInterrupted", err)
+	}
+	if !strings.Contains(out.String(), "^C\r\n") {
+		t.Errorf("Ctrl-C output = %q, want ^C", out.String())
+	}
+	// The next line starts fresh.
+	if got, err := e.ReadLine("sb> "); err != nil || got != "next" {
+		t.Errorf("ReadLine() after Ctrl-C = %q, %v, want %q", got, err, "next")
+	}
+
+	e, _ = newEditor(t, "")
+	if _, err := e.ReadLine("sb> "); !errors.Is(err, io.EOF) {
+		t.Errorf("empty input: err = %v, want io.EOF", err)
+	}
+}
+
+func TestReadLineHistory(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"up recalls newest", up + cr, "git status"},
+		{"up twice", up + up + cr, "ls -la"},
+		{"up stops at oldest", up + up + up + up + cr, "ls -la"},
+		{"ctrl-p and ctrl-n", "\x10\x10\x0e" + cr, "git status"},
+		{"down restores draft", "dra" + up + up + down + down + "ft" + cr, "draft"},
+		{"down past draft is ignored", down + "x" + cr, "x"},
+		{"recalled line is editable", up + " -s" + cr, "git status -s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newEditor(t, tt.keys, "ls -la", "git status")
+			got, err := e.ReadLine("sb> ")
+			if err != nil {
+				t.Fatalf("ReadLine(): %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadLineReverseSearch(t *testing.T) {
+	history := []string{"docker ps", "git log", "docker images", "ls"}
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{"finds newest match", ctrlRSeq + "dock" + cr, "docker images"},
+		{"ctrl-r again finds older", ctrlRSeq + "dock" + ctrlRSeq + cr, "docker ps"},
+		{"ctrl-r past oldest keeps match", ctrlRSeq + "dock" + ctrlRSeq + ctrlRSeq + cr, "docker ps"},
+		{"backspace widens query", ctrlRSeq + "gix" + bs + cr, "git log"},
+		{"ctrl-g cancels", "draft" + ctrlRSeq + "git" + ctrlGSeq + cr, "draft"},
+		{"ctrl-c cancels search only", "draft" + ctrlRSeq + "git\x03" + cr, "draft"},
+		{"other key accepts and edits", ctrlRSeq + "log" + ctrlESeq + " -1" + cr, "git log -1"},
+		{"no match keeps line", ctrlRSeq + "zzz" + cr, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, _ := newEditor(t, tt.keys, history...)
+			got, err := e.ReadLine("sb> ")
+			if err != nil {
+				t.Fatalf("ReadLine(): %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverseSearchPrompt(t *testing.T) {
+	e, out := newEditor(t, ctrlRSeq+"zz"+cr, "ls")
+	if _, err := e.ReadLine("sb> "); err != nil {
+		t.Fatalf("ReadLine(): %v", err)
+	}
+	for _, want := range []string{"(reverse-i-search)`': ", "(failing reverse-i-search)`zz': "} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q: %q", want, out.String())
+		}
+	}
+}
+
+func TestReadLineCompletion(t *testing.T) {
+	complete := func(line string) (int, []string) {
+		start := strings.LastIndex(line, " ") + 1
+		var matches []string
+		for _, c := range []string{"/help", "/history", "/model", "docs/", "héllo.txt"} {
+			if strings.HasPrefix(c, line[start:]) {
+				matches = append(matches, c)
+			}
+		}
+		return start, matches
+	}
+
+	tests := []struct {
+		name       string
+		keys       string
+		want       string
+		wantOutput string
+	}{
+		{"unique match adds space", "/mo\t" + cr, "/model ", ""},
+		{"directory gets no space", "cat do\t" + cr, "cat docs/", ""},
+		{"common prefix", "/h\t" + cr, "/h", "\a"},
+		{"second tab lists", "/he\t\t" + cr, "/help ", ""},
+		{"list candidates", "/\t\t" + cr, "/", "/help  /history  /model"},
+		{"no match rings bell", "xyz\t" + cr, "xyz", "\a"},
+		{"multibyte word", "cat hé\t" + cr, "cat héllo.txt ", ""},
+		{"completes mid-line", "/mo" + "x" + left + "\t" + cr, "/model x", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, out := newEditor(t, tt.keys)
+			e.Complete = complete
+			got, err := e.ReadLine("sb> ")
+			if err != nil {
+				t.Fatalf("ReadLine(): %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+			if tt.wantOutput != "" && !strings.Contains(out.String(), tt.wantOutput) {
+				t.Errorf("output = %q, want it to contain %q", out.String(), tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestCompletionWithoutCompleter(t *testing.T) {
+	e, _ := newEditor(t, "a\tb"+cr)
+	if got, _ := e.ReadLine("sb> "); got != "ab" {
+		t.Errorf("ReadLine() = %q, want %q", got, "ab")
+	}
+}
+
+func TestEditLine(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		keys string
+		want string
+	}{
+		{"accept as is", "ls -la", cr, "ls -la"},
+		{"append", "ls -la", " /tmp" + cr, "ls -la /tmp"},
+		{"edit at start", "ls -la", ctrlASeq + altD + "du" + cr, "du -la"},
+		{"clear", "rm -rf build", ctrlUSeq + "make clean" + cr, "make clean"},
+		{"end of input keeps text", "ls -la", "", "ls -la"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, out := newEditor(t, tt.keys)
+			got, err := e.EditLine("edit> ", tt.text)
+			if err != nil {
+				t.Fatalf("EditLine() error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("EditLine() = %q, want %q", got, tt.want)
+			}
+			if !strings.HasPrefix(out.String(), "\redit> "+tt.text) {
+				t.Errorf("output = %q, want the text shown first", out.String())
+			}
+		})
+	}
+}
+
+func TestRefreshPlacesCursor(t *testing.T) {
+	e, out := newEditor(t, "abc"+left+left+cr)
+	if _, err := e.ReadLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "\r> abc\x1b[K\x1b[2D") {
+		t.Errorf("output = %q, want cursor moved back 2", out.String())
+	}
+}
+
+func TestRefreshShowsNewlines(t *testing.T) {
+	e, out := newEditor(t, "\x1b[200~a\nb\tc\x1b[201~"+cr)
+	if _, err := e.ReadLine("> "); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "\r> a↵b c\x1b[K") {
+		t.Errorf("output = %q, want pasted text on one row", out.String())
+	}
+}
+
+func TestRawModeError(t *testing.T) {
+	e, _ := newEditor(t, "ls"+cr)
+	e.raw = func() (func(), error) { return nil, errors.New("not a tty") }
+	if _, err := e.ReadLine("sb> "); err == nil || !strings.Contains(err.Error(), "raw mode") {
+		t.Errorf("ReadLine() error = %v, want raw mode error", err)
+	}
+
+	restored := false
+	e, _ = newEditor(t, "ls"+cr)
+	e.raw = func() (func(), error) { return func() { restored = true }, nil }
+	if got, err := e.ReadLine("sb> "); err != nil || got != "ls" {
+		t.Errorf("ReadLine() = %q, %v", got, err)
+	}
+	if !restored {
+		t.Error("terminal not restored after ReadLine")
+	}
+}
@@ -0,0 +1,123 @@
+// Package output defines the machine-readable documents written by
+// --output json and --output ndjson.
+//
+// The schema is versioned. Within a version, fields are only ever added;
+// renaming or removing a field, or changing its meaning, bumps Version.
+// Every document carries the version so scripts can check it first.
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hpkotak/shellbud/internal/prompt"
+	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/safety"
+)
+
+// Version is the schema version of every document.
+const Version = 1
+
+// Format is an output mode.
+type Format string
+
+// Output modes.
+const (
+	Text   Format = "text"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+)
+
+// ParseFormat validates an --output value.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case Text, JSON, NDJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (use text, json or ndjson)", s)
+}
+
+// Result is the document for one model response. Commands are listed with
+// their safety level but are never executed in machine-readable mode.
+type Result struct {
+	Version  int    `json:"version"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Text     string `json:"text"`
+	// Structured is false when the model did not return the structured
+	// contract; Commands is then always empty (fail closed).
+	Structured   bool      `json:"structured"`
+	Commands     []Command `json:"commands"`
+	Usage        Usage     `json:"usage"`
+	FinishReason string    `json:"finish_reason"`
+	Warning      string    `json:"warning"`
+}
+
+// Command is a suggested command with its safety classification.
+type Command struct {
+	Command string `json:"command"`
+	Safety  string `json:"safety"`
+}
+
+// Usage is token usage; zero when the provider does not report it.
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// Failure is the document written instead of a Result when a request fails.
+type Failure struct {
+	Version int    `json:"version"`
+	Error   string `json:"error"`
+}
+
+// NewResult builds the document for a response from providerName.
+func NewResult(providerName, model string, resp provider.ChatResponse, parsed prompt.ParsedResponse) Result {
+	commands := make([]Command, 0, len(parsed.Commands))
+	for _, c := range parsed.Commands {
+		commands = append(commands, Command{Command: c, Safety: safety.Classify(c).String()})
+	}
+	return Result{
+		Version:    Version,
+		Provider:   providerName,
+		Model:      model,
+		Text:       parsed.Text,
+		Structured: parsed.Structured,
+		Commands:   commands,
+		Usage: Usage{
+			InputTokens:  resp.Usage.InputTokens,
+			OutputTokens: resp.Usage.OutputTokens,
+			TotalTokens:  resp.Usage.TotalTokens,
+		},
+		FinishReason: resp.FinishReason,
+		Warning:      resp.Warning,
+	}
+}
+
+// Encoder writes documents in a machine-readable format: indented for
+// JSON, one per line for NDJSON.
+type Encoder struct {
+	enc *json.Encoder
+}
+
+// NewEncoder returns an Encoder writing to w in format f.
+func NewEncoder(w io.Writer, f Format) *Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if f == JSON {
+		enc.SetIndent("", "  ")
+	}
+	return &Encoder{enc: enc}
+}
+
+// Result writes a Result.
+func (e *Encoder) Result(r Result) error {
+	return e.enc.Encode(r)
+}
+
+// Error writes a Failure for err.
+func (e *Encoder) Error(err error) error {
+	return e.enc.Encode(Failure{Version: Version, Error: err.Error()})
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/prompt"
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"text", "json", "ndjson"} {
+		if f, err := ParseFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseFormat("yaml"); err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("ParseFormat(yaml) error = %v", err)
+	}
+}
+
+func TestNewResult(t *testing.T) {
+	resp := provider.ChatResponse{
+		Text:         `{"text":"Here.","commands":["ls -la","rm -rf build"]}`,
+		FinishReason: "stop",
+		Usage:        provider.Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		Warning:      "context trimmed",
+	}
+	got := NewResult("ollama", "llama3.2:latest", resp, prompt.ParseChatResponse(resp.Text))
+
+	want := Result{
+		Version:      Version,
+		Provider:     "ollama",
+		Model:        "llama3.2:latest",
+		Text:         "Here.",
+		Structured:   true,
+		Commands:     []Command{{"ls -la", "safe"}, {"rm -rf build", "destructive"}},
+		Usage:        Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15},
+		FinishReason: "stop",
+		Warning:      "context trimmed",
+	}
+	if got.Version != want.Version || got.Provider != want.Provider || got.Model != want.Model ||
+		got.Text != want.Text || got.Structured != want.Structured || got.Usage != want.Usage ||
+		got.FinishReason != want.FinishReason || got.Warning != want.Warning {
+		t.Errorf("NewResult() = %+v, want %+v", got, want)
+	}
+	if len(got.Commands) != 2 || got.Commands[0] != want.Commands[0] || got.Commands[1] != want.Commands[1] {
+		t.Errorf("Commands = %+v, want %+v", got.Commands, want.Commands)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	unstructured := NewResult("openai", "", provider.ChatResponse{Text: "rm -rf /"}, prompt.ParseChatResponse("rm -rf /"))
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, NDJSON)
+	if err := enc.Result(unstructured); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Error(errors.New("query failed: <timeout>")); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"version":1,"provider":"openai","model":"","text":"rm -rf /","structured":false,"commands":[],"usage":{"input_tokens":0,"output_tokens":0,"total_tokens":0},"finish_reason":"","warning":""}
+{"version":1,"error":"query failed: <timeout>"}
+`
+	if buf.String() != want {
+		t.Errorf("NDJSON output =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := NewEncoder(&buf, JSON).Error(errors.New("x")); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "{\n  \"version\": 1,\n  \"error\": \"x\"\n}\n" {
+		t.Errorf("JSON output = %q, want indented document", got)
+	}
+}
@@ -0,0 +1,161 @@
+package prompt
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// TextStream incrementally extracts the "text" field of a structured response
+// while the model is still generating it, so callers can render progress.
+//
+// It is display-only: it never yields commands, and it gives up silently when
+// the stream does not look like a JSON object. Commands must still come from
+// ParseChatResponse on the complete response.
+type TextStream struct {
+	depth     int
+	inString  bool
+	escape    bool
+	expectKey bool
+	isKey     bool
+	isText    bool
+	key       strings.Builder
+	lastKey   string
+	unicode   []byte // pending \uXXXX hex digits
+	surrogate rune   // pending high surrogate from a previous \u escape
+	done      bool
+}
+
+// Feed consumes the next chunk of raw model output and returns any newly
+// decoded text-field content. Chunks may split tokens and escapes anywhere.
+func (s *TextStream) Feed(chunk string) string {
+	if s.done {
+		return ""
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(chunk) && !s.done; i++ {
+		c := chunk[i]
+		if s.inString {
+			s.feedString(c, &out)
+			continue
+		}
+
+		switch c {
+		case '{':
+			s.depth++
+			if s.depth == 1 {
+				s.expectKey = true
+			}
+		case '[':
+			s.depth++
+		case '}', ']':
+			s.depth--
+		case ',':
+			if s.depth == 1 {
+				s.expectKey = true
+			}
+		case ':':
+			if s.depth == 1 {
+				s.expectKey = false
+			}
+		case '"':
+			s.inString = true
+			s.isKey = s.depth == 1 && s.expectKey
+			s.isText = s.depth == 1 && !s.expectKey && s.lastKey == "text"
+			s.key.Reset()
+		case ' ', '\t', '\n', '\r':
+		default:
+			if s.depth == 0 {
+				// Not a JSON object; nothing to extract.
+				s.done = true
+			}
+		}
+	}
+	return out.String()
+}
+
+func (s *TextStream) feedString(c byte, out *strings.Builder) {
+	if s.unicode != nil {
+		s.unicode = append(s.unicode, c)
+		if len(s.unicode) < 4 {
+			return
+		}
+		n, err := strconv.ParseUint(string(s.unicode), 16, 16)
+		s.unicode = nil
+		if err != nil {
+			return
+		}
+		s.emitRune(rune(n), out)
+		return
+	}
+
+	if s.escape {
+		s.escape = false
+		if c == 'u' {
+			s.unicode = make([]byte, 0, 4)
+			return
+		}
+		s.emitRune(unescape(c), out)
+		return
+	}
+
+	switch c {
+	case '\\':
+		s.escape = true
+	case '"':
+		s.inString = false
+		if s.isKey {
+			s.lastKey = s.key.String()
+		}
+		if s.isText {
+			// The text field is complete; the rest of the object is not ours.
+			s.done = true
+		}
+	default:
+		if s.isKey {
+			s.key.WriteByte(c)
+		}
+		if s.isText {
+			out.WriteByte(c)
+		}
+	}
+}
+
+func (s *TextStream) emitRune(r rune, out *strings.Builder) {
+	if s.surrogate != 0 {
+		high := s.surrogate
+		s.surrogate = 0
+		if utf16.IsSurrogate(r) {
+			r = utf16.DecodeRune(high, r)
+		}
+	} else if r >= 0xD800 && r < 0xDC00 {
+		s.surrogate = r
+		return
+	}
+
+	if s.isKey {
+		s.key.WriteRune(r)
+	}
+	if s.isText {
+		out.WriteRune(r)
+	}
+}
+
+func unescape(c byte) rune {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case 'b':
+		return '\b'
+	case 'f':
+		return '\f'
+	default:
+		// \" \\ \/ and anything unexpected map to the literal character.
+		return rune(c)
+	}
+}
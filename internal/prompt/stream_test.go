@@ -0,0 +1,57 @@
+package prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextStream(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"simple", `{"text":"hello world","commands":["ls"]}`, "hello world"},
+		{"commands first", `{"commands":["rm -rf /tmp/x"],"text":"cleanup"}`, "cleanup"},
+		{"whitespace", "  {\n  \"text\" : \"spaced\" }", "spaced"},
+		{"escapes", `{"text":"line1\nline2 \"quoted\" back\\slash \/"}`, "line1\nline2 \"quoted\" back\\slash /"},
+		{"unicode escape", `{"text":"caf\u00e9"}`, "café"},
+		{"surrogate pair", `{"text":"\ud83d\ude00!"}`, "😀!"},
+		{"raw utf8", `{"text":"naïve ↵"}`, "naïve ↵"},
+		{"nested text key ignored", `{"meta":{"text":"nope"},"text":"yes"}`, "yes"},
+		{"text in array ignored", `{"commands":["text"],"text":"ok"}`, "ok"},
+		{"text value not string", `{"text":null}`, ""},
+		{"not json", "ls -la", ""},
+		{"fenced", "```json\n{\"text\":\"x\"}\n```", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Feed one byte at a time to exercise every split point.
+			var s TextStream
+			var got strings.Builder
+			for i := 0; i < len(tt.raw); i++ {
+				got.WriteString(s.Feed(tt.raw[i : i+1]))
+			}
+			if got.String() != tt.want {
+				t.Errorf("byte-wise Feed(%q) = %q, want %q", tt.raw, got.String(), tt.want)
+			}
+
+			var whole TextStream
+			if out := whole.Feed(tt.raw); out != tt.want {
+				t.Errorf("Feed(%q) = %q, want %q", tt.raw, out, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextStreamStopsAfterText(t *testing.T) {
+	var s TextStream
+	if got := s.Feed(`{"text":"done"`); got != "done" {
+		t.Fatalf("Feed() = %q, want %q", got, "done")
+	}
+	if got := s.Feed(`,"text":"again"}`); got != "" {
+		t.Errorf("Feed() after text closed = %q, want empty", got)
+	}
+}
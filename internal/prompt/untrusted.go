@@ -0,0 +1,67 @@
+package prompt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ansiEscape matches terminal escape sequences (colors, cursor moves) that
+// tools such as journalctl or ls --color leave in their output.
+var ansiEscape = regexp.MustCompile(`\x1b(\[[0-9;?]*[ -/]*[@-~]|\][^\x07\x1b]*(\x07|\x1b\\)|.)`)
+
+// WithPipedInput appends input piped into sb to the user's query, inside a
+// <stdin> block the system prompt marks as untrusted data. truncated notes
+// that only the beginning of the input is included.
+func WithPipedInput(query, input string, truncated bool) string {
+	s := query + "\n\n" + dataBlock("stdin", input)
+	if truncated {
+		s += "\nThe piped input was too long; only its beginning is shown."
+	}
+	return s
+}
+
+// FixRequest asks for a diagnosis of a failed command and a corrected one.
+// exitCode is negative when unknown. Captured output, if any, goes in a
+// <command_output> block the system prompt marks as untrusted data.
+func FixRequest(command string, exitCode int, output string) string {
+	var b strings.Builder
+	b.WriteString("This command did not work. Explain briefly what went wrong and suggest a corrected command.\n\n")
+	fmt.Fprintf(&b, "Command: `%s`\n", command)
+	if exitCode >= 0 {
+		fmt.Fprintf(&b, "Exit status: %d\n", exitCode)
+	}
+	if strings.TrimSpace(output) != "" {
+		b.WriteString("\n" + dataBlock("command_output", output))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// dataBlock wraps untrusted text in <tag>...</tag> after sanitizing it.
+func dataBlock(tag, text string) string {
+	return "<" + tag + ">\n" + sanitizeBlock(text, tag) + "\n</" + tag + ">"
+}
+
+// sanitizeBlock makes untrusted text safe to embed in a <tag> block. It
+// keeps the text readable (newlines and tabs stay) but drops terminal escape
+// sequences and other control characters, and defuses anything that looks
+// like the block's own tag so the text cannot close the block early.
+func sanitizeBlock(s, tag string) string {
+	s = strings.ToValidUTF8(s, "�")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = ansiEscape.ReplaceAllString(s, "")
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			return r
+		case r == '\r':
+			return '\n'
+		case r < ' ' || r == 0x7f:
+			return -1
+		}
+		return r
+	}, s)
+	tagPattern := regexp.MustCompile(`(?i)<\s*/?\s*` + regexp.QuoteMeta(tag) + `\s*>`)
+	s = tagPattern.ReplaceAllString(s, "["+tag+" tag removed]")
+	return strings.TrimRight(s, "\n")
+}
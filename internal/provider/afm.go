@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -22,6 +23,9 @@ const (
 // Bridge contract:
 // - stdin:  {"model":"...","messages":[{"role":"...","content":"..."}]}
 // - stdout: {"content":"assistant response"}
+//
+// With "stream":true in the request, stdout is NDJSON: {"delta":"..."} lines
+// followed by a final {"content":"...","done":true} line.
 type AFMProvider struct {
 	model   string
 	command string
@@ -45,6 +49,7 @@ func (a *AFMProvider) Capabilities() Capabilities {
 		JSONMode:     true,
 		Usage:        false,
 		FinishReason: false,
+		Streaming:    true,
 	}
 }
 
@@ -70,6 +75,117 @@ func (a *AFMProvider) Available(_ context.Context) error {
 }
 
 func (a *AFMProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	reqBody, err := a.encodeRequest(req, false)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	cmd := exec.CommandContext(ctx, a.command)
+	cmd.Stdin = bytes.NewReader(reqBody)
+
+	stdout := newLimitedBuffer(afmStdoutLimitBytes)
+	stderr := newLimitedBuffer(afmStderrLimitBytes)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return ChatResponse{}, afmExecError(err, &stderr)
+	}
+
+	if stdout.overflow || stderr.overflow {
+		return ChatResponse{}, fmt.Errorf("afm bridge output exceeded limit (%d bytes stdout, %d bytes stderr)",
+			afmStdoutLimitBytes, afmStderrLimitBytes)
+	}
+
+	var decoded afmResponse
+	if err := json.Unmarshal(stdout.Bytes(), &decoded); err != nil {
+		return ChatResponse{}, fmt.Errorf("decoding afm response: %w", err)
+	}
+
+	return decoded.toChatResponse(req.ExpectJSON)
+}
+
+// ChatStream runs the bridge in NDJSON mode ("stream":true). The bridge writes
+// one {"delta":"..."} line per increment, then a final line in the normal
+// response shape with "done":true. Only the final line is authoritative.
+func (a *AFMProvider) ChatStream(ctx context.Context, req ChatRequest, fn StreamFunc) (ChatResponse, error) {
+	reqBody, err := a.encodeRequest(req, true)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	cmd := exec.CommandContext(ctx, a.command)
+	cmd.Stdin = bytes.NewReader(reqBody)
+
+	stderr := newLimitedBuffer(afmStderrLimitBytes)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("afm bridge stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return ChatResponse{}, fmt.Errorf("afm bridge execution failed: %w", err)
+	}
+
+	var (
+		final     *afmResponse
+		decodeErr error
+		total     int
+	)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), afmStdoutLimitBytes)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		total += len(line) + 1
+		if total > afmStdoutLimitBytes {
+			decodeErr = fmt.Errorf("afm bridge output exceeded limit (%d bytes stdout, %d bytes stderr)",
+				afmStdoutLimitBytes, afmStderrLimitBytes)
+			break
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var chunk afmResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			decodeErr = fmt.Errorf("decoding afm stream line: %w", err)
+			break
+		}
+		if chunk.Done {
+			final = &chunk
+			continue
+		}
+		if chunk.Delta != "" {
+			fn(chunk.Delta)
+		}
+	}
+	if decodeErr == nil {
+		if err := scanner.Err(); err != nil {
+			decodeErr = fmt.Errorf("afm bridge output exceeded limit (%d bytes stdout, %d bytes stderr): %w",
+				afmStdoutLimitBytes, afmStderrLimitBytes, err)
+		}
+	}
+	if decodeErr != nil {
+		// Stop the bridge rather than wait for output nobody will read. Closing
+		// our end of the pipe also unblocks any children still writing to it.
+		_ = cmd.Process.Kill()
+		_ = stdout.Close()
+		_ = cmd.Wait()
+		return ChatResponse{}, decodeErr
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return ChatResponse{}, afmExecError(err, &stderr)
+	}
+	if final == nil {
+		return ChatResponse{}, fmt.Errorf("afm bridge stream ended without a final response")
+	}
+
+	return final.toChatResponse(req.ExpectJSON)
+}
+
+func (a *AFMProvider) encodeRequest(req ChatRequest, stream bool) ([]byte, error) {
 	type afmMessage struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
@@ -79,11 +195,12 @@ func (a *AFMProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse,
 		Model      string       `json:"model"`
 		Messages   []afmMessage `json:"messages"`
 		ExpectJSON bool         `json:"expect_json,omitempty"`
+		Stream     bool         `json:"stream,omitempty"`
 	}
 
 	apiMessages := make([]afmMessage, len(req.Messages))
 	for i, m := range req.Messages {
-		apiMessages[i] = afmMessage(m)
+		apiMessages[i] = afmMessage{Role: m.Role, Content: m.Content}
 	}
 
 	model := resolveModel(req.Model, a.model)
@@ -92,60 +209,52 @@ func (a *AFMProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse,
 		Model:      model,
 		Messages:   apiMessages,
 		ExpectJSON: req.ExpectJSON,
+		Stream:     stream,
 	})
 	if err != nil {
-		return ChatResponse{}, fmt.Errorf("encoding afm request: %w", err)
-	}
-
-	cmd := exec.CommandContext(ctx, a.command)
-	cmd.Stdin = bytes.NewReader(reqBody)
-
-	stdout := newLimitedBuffer(afmStdoutLimitBytes)
-	stderr := newLimitedBuffer(afmStderrLimitBytes)
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		errText := strings.TrimSpace(stderr.String())
-		if errText == "" {
-			return ChatResponse{}, fmt.Errorf("afm bridge execution failed: %w", err)
-		}
-		return ChatResponse{}, fmt.Errorf("afm bridge execution failed: %w: %s", err, errText)
+		return nil, fmt.Errorf("encoding afm request: %w", err)
 	}
+	return reqBody, nil
+}
 
-	if stdout.overflow || stderr.overflow {
-		return ChatResponse{}, fmt.Errorf("afm bridge output exceeded limit (%d bytes stdout, %d bytes stderr)",
-			afmStdoutLimitBytes, afmStderrLimitBytes)
+func afmExecError(err error, stderr *limitedBuffer) error {
+	errText := strings.TrimSpace(stderr.String())
+	if errText == "" {
+		return fmt.Errorf("afm bridge execution failed: %w", err)
 	}
+	return fmt.Errorf("afm bridge execution failed: %w: %s", err, errText)
+}
 
-	var decoded struct {
-		Content        string `json:"content"`
-		FinishReason   string `json:"finish_reason,omitempty"`
-		ContextTrimmed bool   `json:"context_trimmed,omitempty"`
-		Usage          struct {
-			InputTokens  int `json:"input_tokens,omitempty"`
-			OutputTokens int `json:"output_tokens,omitempty"`
-			TotalTokens  int `json:"total_tokens,omitempty"`
-		} `json:"usage,omitempty"`
-	}
-	if err := json.Unmarshal(stdout.Bytes(), &decoded); err != nil {
-		return ChatResponse{}, fmt.Errorf("decoding afm response: %w", err)
-	}
+// afmResponse is the bridge's response object. In NDJSON stream mode the same
+// shape is used for delta lines (Delta set) and the final line (Done set).
+type afmResponse struct {
+	Content        string `json:"content"`
+	Delta          string `json:"delta,omitempty"`
+	Done           bool   `json:"done,omitempty"`
+	FinishReason   string `json:"finish_reason,omitempty"`
+	ContextTrimmed bool   `json:"context_trimmed,omitempty"`
+	Usage          struct {
+		InputTokens  int `json:"input_tokens,omitempty"`
+		OutputTokens int `json:"output_tokens,omitempty"`
+		TotalTokens  int `json:"total_tokens,omitempty"`
+	} `json:"usage,omitempty"`
+}
 
-	result := strings.TrimSpace(decoded.Content)
+func (r afmResponse) toChatResponse(expectJSON bool) (ChatResponse, error) {
+	result := strings.TrimSpace(r.Content)
 	if result == "" {
 		return ChatResponse{}, fmt.Errorf("empty response from model")
 	}
 
 	var warning string
-	if decoded.ContextTrimmed {
+	if r.ContextTrimmed {
 		warning = "conversation history was too long and was trimmed"
 	}
 
 	usage := Usage{
-		InputTokens:  decoded.Usage.InputTokens,
-		OutputTokens: decoded.Usage.OutputTokens,
-		TotalTokens:  decoded.Usage.TotalTokens,
+		InputTokens:  r.Usage.InputTokens,
+		OutputTokens: r.Usage.OutputTokens,
+		TotalTokens:  r.Usage.TotalTokens,
 	}
 	if usage.TotalTokens == 0 {
 		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
@@ -154,8 +263,8 @@ func (a *AFMProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse,
 	return ChatResponse{
 		Text:         result,
 		Raw:          result,
-		Structured:   isStructuredJSON(req.ExpectJSON, result),
-		FinishReason: decoded.FinishReason,
+		Structured:   isStructuredJSON(expectJSON, result),
+		FinishReason: r.FinishReason,
 		Usage:        usage,
 		Warning:      warning,
 	}, nil
@@ -62,6 +62,7 @@ func TestAFMNameAndCapabilities(t *testing.T) {
 		JSONMode:     true,
 		Usage:        false,
 		FinishReason: false,
+		Streaming:    true,
 	}
 	if gotCaps != wantCaps {
 		t.Errorf("Capabilities() = %+v, want %+v", gotCaps, wantCaps)
@@ -240,3 +241,117 @@ yes a | head -c 2000000
 		})
 	}
 }
+
+func TestAFMChatStream(t *testing.T) {
+	tmpDir := t.TempDir()
+	reqPath := filepath.Join(tmpDir, "request.json")
+	script := filepath.Join(tmpDir, "afm-bridge")
+
+	body := `#!/bin/sh
+cat > "$AFM_REQ_FILE"
+echo '{"delta":"{\"text\":\"o"}'
+echo '{"delta":"k"}'
+echo '{"content":"{\"text\":\"ok\",\"commands\":[]}","finish_reason":"stop","context_trimmed":true,"done":true}'
+`
+	writeExecutable(t, script, body)
+	t.Setenv("AFM_REQ_FILE", reqPath)
+
+	p, _ := NewAFM("default-model", script)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var deltas []string
+	got, err := p.ChatStream(ctx, ChatRequest{
+		Messages:   []Message{{Role: "user", Content: "hello"}},
+		ExpectJSON: true,
+	}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() unexpected error: %v", err)
+	}
+
+	if strings.Join(deltas, "") != `{"text":"ok` {
+		t.Errorf("deltas = %q, want %q", deltas, []string{`{"text":"o`, "k"})
+	}
+	if got.Text != `{"text":"ok","commands":[]}` {
+		t.Errorf("Text = %q, want %q", got.Text, `{"text":"ok","commands":[]}`)
+	}
+	if !got.Structured {
+		t.Error("Structured = false, want true")
+	}
+	if got.Warning == "" {
+		t.Error("Warning should be set when context was trimmed")
+	}
+
+	reqData, err := os.ReadFile(reqPath)
+	if err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if !strings.Contains(string(reqData), `"stream":true`) {
+		t.Errorf("request missing stream flag: %s", reqData)
+	}
+}
+
+func TestAFMChatStreamErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		scriptBody string
+		wantErr    string
+	}{
+		{
+			name: "bridge execution fails",
+			scriptBody: `#!/bin/sh
+cat >/dev/null
+echo "boom" 1>&2
+exit 1
+`,
+			wantErr: "boom",
+		},
+		{
+			name: "invalid stream line",
+			scriptBody: `#!/bin/sh
+cat >/dev/null
+echo "{not-json"
+`,
+			wantErr: "decoding afm stream line",
+		},
+		{
+			name: "missing final line",
+			scriptBody: `#!/bin/sh
+cat >/dev/null
+echo '{"delta":"partial"}'
+`,
+			wantErr: "without a final response",
+		},
+		{
+			name: "output too large",
+			scriptBody: `#!/bin/sh
+cat >/dev/null
+yes '{"delta":"aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}' | head -c 2000000
+`,
+			wantErr: "output exceeded limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script := filepath.Join(t.TempDir(), "afm-bridge")
+			writeExecutable(t, script, tt.scriptBody)
+
+			p, _ := NewAFM("afm-latest", script)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := p.ChatStream(ctx, ChatRequest{
+				Messages: []Message{{Role: "user", Content: "hello"}},
+			}, func(string) {})
+			if err == nil {
+				t.Fatalf("ChatStream() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ChatStream() error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 4096
+	// anthropicJSONPrefill is the assistant prefill used to emulate JSON mode:
+	// the Messages API has no response_format, but a response that must
+	// continue from "{" reliably stays a JSON object.
+	anthropicJSONPrefill = "{"
+)
+
+// AnthropicProvider implements Provider using the Anthropic Messages API.
+type AnthropicProvider struct {
+	client *http.Client
+	host   string
+	model  string
+	apiKey string
+	retry  RetryPolicy
+}
+
+// NewAnthropic creates an AnthropicProvider connected to the given host and model.
+func NewAnthropic(host, model, apiKey string) (*AnthropicProvider, error) {
+	base := strings.TrimSpace(host)
+	if base == "" {
+		return nil, fmt.Errorf("anthropic host cannot be empty")
+	}
+	if _, err := url.ParseRequestURI(base); err != nil {
+		return nil, fmt.Errorf("parsing anthropic host URL: %w", err)
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("model cannot be empty")
+	}
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, fmt.Errorf("anthropic api key is required (set ANTHROPIC_API_KEY)")
+	}
+
+	return &AnthropicProvider{
+		client: &http.Client{Timeout: 30 * time.Second},
+		host:   strings.TrimRight(base, "/"),
+		model:  model,
+		apiKey: apiKey,
+		retry:  DefaultRetryPolicy,
+	}, nil
+}
+
+func (a *AnthropicProvider) Name() string { return "anthropic" }
+
+func (a *AnthropicProvider) Capabilities() Capabilities {
+	return Capabilities{
+		JSONMode:     true,
+		Usage:        true,
+		FinishReason: true,
+	}
+}
+
+// Available checks if Anthropic is reachable and the configured model exists.
+// GET /models/{id} also resolves aliases such as "claude-sonnet-4-5", which a
+// plain list lookup would miss.
+func (a *AnthropicProvider) Available(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.host+"/models/"+url.PathEscape(a.model), nil)
+	if err != nil {
+		return fmt.Errorf("building anthropic availability request: %w", err)
+	}
+	a.setHeaders(req)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("checking anthropic availability: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		err := newHTTPError("anthropic availability check", resp)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("model %q not found in Anthropic models list: %w", a.model, err)
+		}
+		return err
+	}
+
+	var decoded struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("decoding anthropic model response: %w", err)
+	}
+	if decoded.ID == "" {
+		return fmt.Errorf("model %q not found in Anthropic models list", a.model)
+	}
+	return nil
+}
+
+// Chat sends the conversation to the Messages API and returns the assistant
+// response. System messages move to the top-level system field. JSON mode is
+// emulated by prefilling the assistant turn with "{" and restoring it on the
+// returned text.
+func (a *AnthropicProvider) Chat(ctx context.Context, chatReq ChatRequest) (ChatResponse, error) {
+	type anthropicMessage struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+
+	type anthropicRequest struct {
+		Model     string             `json:"model"`
+		MaxTokens int                `json:"max_tokens"`
+		System    string             `json:"system,omitempty"`
+		Messages  []anthropicMessage `json:"messages"`
+	}
+
+	var systemParts []string
+	apiMessages := make([]anthropicMessage, 0, len(chatReq.Messages)+1)
+	for _, m := range chatReq.Messages {
+		if m.Role == "system" {
+			systemParts = append(systemParts, m.Content)
+			continue
+		}
+		apiMessages = append(apiMessages, anthropicMessage{Role: m.Role, Content: m.Content})
+	}
+
+	// Prefill only when the model is answering a user turn; a trailing
+	// assistant message is already a prefill of the caller's choosing.
+	prefill := ""
+	if chatReq.ExpectJSON && len(apiMessages) > 0 && apiMessages[len(apiMessages)-1].Role == "user" {
+		prefill = anthropicJSONPrefill
+		apiMessages = append(apiMessages, anthropicMessage{Role: "assistant", Content: prefill})
+	}
+
+	body, err := json.Marshal(anthropicRequest{
+		Model:     resolveModel(chatReq.Model, a.model),
+		MaxTokens: anthropicMaxTokens,
+		System:    strings.Join(systemParts, "\n\n"),
+		Messages:  apiMessages,
+	})
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("encoding anthropic chat request: %w", err)
+	}
+
+	resp, err := a.send(ctx, body)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var decoded struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      struct {
+			InputTokens  int `json:"input_tokens"`
+			OutputTokens int `json:"output_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return ChatResponse{}, fmt.Errorf("decoding anthropic chat response: %w", err)
+	}
+
+	var text strings.Builder
+	for _, block := range decoded.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if strings.TrimSpace(text.String()) == "" {
+		return ChatResponse{}, fmt.Errorf("empty response from model")
+	}
+
+	result := strings.TrimSpace(prefill + text.String())
+
+	usage := Usage{
+		InputTokens:  decoded.Usage.InputTokens,
+		OutputTokens: decoded.Usage.OutputTokens,
+	}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+
+	return ChatResponse{
+		Text:         result,
+		Raw:          result,
+		Structured:   isStructuredJSON(chatReq.ExpectJSON, result),
+		FinishReason: decoded.StopReason,
+		Usage:        usage,
+	}, nil
+}
+
+// send posts the Messages API request and returns the successful response,
+// retrying rate limits and transient errors, such as 529 overloaded, per
+// a.retry. The caller must close the response body.
+func (a *AnthropicProvider) send(ctx context.Context, body []byte) (*http.Response, error) {
+	var resp *http.Response
+	err := a.retry.do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.host+"/messages", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("building anthropic chat request: %w", err)
+		}
+		a.setHeaders(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		r, err := a.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("anthropic chat: %w", err)
+		}
+		if r.StatusCode >= http.StatusBadRequest {
+			defer func() { _ = r.Body.Close() }()
+			return newHTTPError("anthropic chat", r)
+		}
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+func (a *AnthropicProvider) setHeaders(req *http.Request) {
+	req.Header.Set("x-api-key", a.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+}
@@ -0,0 +1,433 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAnthropic(t *testing.T, serverURL, model string) *AnthropicProvider {
+	t.Helper()
+	p, err := NewAnthropic(serverURL, model, "test-key")
+	if err != nil {
+		t.Fatalf("NewAnthropic(%q, %q): %v", serverURL, model, err)
+	}
+	return p
+}
+
+func TestNewAnthropic(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		model   string
+		key     string
+		wantErr string
+	}{
+		{name: "valid", host: "https://api.anthropic.com/v1", model: "claude-sonnet-4-5", key: "sk-ant-test"},
+		{name: "empty host", host: "", model: "claude-sonnet-4-5", key: "sk-ant-test", wantErr: "host cannot be empty"},
+		{name: "invalid host", host: "://broken", model: "claude-sonnet-4-5", key: "sk-ant-test", wantErr: "parsing anthropic host URL"},
+		{name: "empty model", host: "https://api.anthropic.com/v1", model: "", key: "sk-ant-test", wantErr: "model cannot be empty"},
+		{name: "empty key", host: "https://api.anthropic.com/v1", model: "claude-sonnet-4-5", key: "", wantErr: "ANTHROPIC_API_KEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewAnthropic(tt.host, tt.model, tt.key)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewAnthropic() unexpected error: %v", err)
+				}
+				if p == nil {
+					t.Fatal("NewAnthropic() returned nil provider")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("NewAnthropic() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnthropicNameAndCapabilities(t *testing.T) {
+	p, _ := NewAnthropic("https://api.anthropic.com/v1", "claude-sonnet-4-5", "test-key")
+
+	if got := p.Name(); got != "anthropic" {
+		t.Errorf("Name() = %q, want %q", got, "anthropic")
+	}
+
+	gotCaps := p.Capabilities()
+	wantCaps := Capabilities{
+		JSONMode:     true,
+		Usage:        true,
+		FinishReason: true,
+	}
+	if gotCaps != wantCaps {
+		t.Errorf("Capabilities() = %+v, want %+v", gotCaps, wantCaps)
+	}
+}
+
+func TestAnthropicAvailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{
+			name:  "model found",
+			model: "claude-sonnet-4-5",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/models/claude-sonnet-4-5" {
+					t.Errorf("path = %q, want %q", r.URL.Path, "/models/claude-sonnet-4-5")
+				}
+				if got := r.Header.Get("x-api-key"); got != "test-key" {
+					t.Errorf("x-api-key header = %q, want %q", got, "test-key")
+				}
+				if got := r.Header.Get("anthropic-version"); got != anthropicVersion {
+					t.Errorf("anthropic-version header = %q, want %q", got, anthropicVersion)
+				}
+				_ = json.NewEncoder(w).Encode(map[string]string{
+					"id":   "claude-sonnet-4-5-20250929",
+					"type": "model",
+				})
+			},
+		},
+		{
+			name:  "model missing",
+			model: "missing-model",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"type":"error","error":{"type":"not_found_error"}}`, http.StatusNotFound)
+			},
+			wantErr: "not found",
+		},
+		{
+			name:  "unauthorized",
+			model: "claude-sonnet-4-5",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "invalid x-api-key", http.StatusUnauthorized)
+			},
+			wantErr: "availability check failed",
+		},
+		{
+			name:  "invalid JSON",
+			model: "claude-sonnet-4-5",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("{not-json"))
+			},
+			wantErr: "decoding anthropic model response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			p := newTestAnthropic(t, srv.URL, tt.model)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := p.Available(ctx)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Available() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Available() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Available() error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnthropicChat(t *testing.T) {
+	var gotReq struct {
+		Model     string              `json:"model"`
+		MaxTokens int                 `json:"max_tokens"`
+		System    string              `json:"system"`
+		Messages  []map[string]string `json:"messages"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Fatalf("path = %q, want %q", r.URL.Path, "/messages")
+		}
+		if got := r.Header.Get("x-api-key"); got != "test-key" {
+			t.Fatalf("x-api-key header = %q, want %q", got, "test-key")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+
+		// The prefilled "{" is not repeated by the API.
+		resp := map[string]any{
+			"content": []map[string]string{
+				{"type": "text", "text": `"text":"ok","commands":[]}`},
+			},
+			"stop_reason": "end_turn",
+			"usage": map[string]int{
+				"input_tokens":  11,
+				"output_tokens": 6,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	p := newTestAnthropic(t, srv.URL, "default-model")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := p.Chat(ctx, ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: "sys"},
+			{Role: "user", Content: "hello"},
+			{Role: "assistant", Content: "hi"},
+			{Role: "user", Content: "list files"},
+		},
+		Model:      "override-model",
+		ExpectJSON: true,
+	})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+
+	if gotReq.Model != "override-model" {
+		t.Errorf("request model = %q, want %q", gotReq.Model, "override-model")
+	}
+	if gotReq.MaxTokens <= 0 {
+		t.Errorf("max_tokens = %d, want > 0", gotReq.MaxTokens)
+	}
+	if gotReq.System != "sys" {
+		t.Errorf("system = %q, want %q", gotReq.System, "sys")
+	}
+	if len(gotReq.Messages) != 4 {
+		t.Fatalf("messages len = %d, want 4 (3 turns + prefill)", len(gotReq.Messages))
+	}
+	for _, m := range gotReq.Messages {
+		if m["role"] == "system" {
+			t.Error("system message should not be sent in messages array")
+		}
+	}
+	last := gotReq.Messages[3]
+	if last["role"] != "assistant" || last["content"] != "{" {
+		t.Errorf("last message = %v, want assistant prefill %q", last, "{")
+	}
+
+	want := `{"text":"ok","commands":[]}`
+	if got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+	if !got.Structured {
+		t.Error("Structured = false, want true")
+	}
+	if got.FinishReason != "end_turn" {
+		t.Errorf("FinishReason = %q, want %q", got.FinishReason, "end_turn")
+	}
+	wantUsage := Usage{InputTokens: 11, OutputTokens: 6, TotalTokens: 17}
+	if got.Usage != wantUsage {
+		t.Errorf("Usage = %+v, want %+v", got.Usage, wantUsage)
+	}
+}
+
+func TestAnthropicChatWithoutJSONMode(t *testing.T) {
+	var gotMessages []map[string]string
+	var hasSystem bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		_, hasSystem = req["system"]
+		_ = json.Unmarshal(req["messages"], &gotMessages)
+
+		resp := map[string]any{
+			"content": []map[string]string{
+				{"type": "text", "text": "ls -la"},
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	p := newTestAnthropic(t, srv.URL, "default-model")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := p.Chat(ctx, ChatRequest{
+		Messages: []Message{{Role: "user", Content: "hello"}},
+	})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if hasSystem {
+		t.Error("system should be omitted when there is no system message")
+	}
+	if len(gotMessages) != 1 {
+		t.Errorf("messages len = %d, want 1 (no prefill without ExpectJSON)", len(gotMessages))
+	}
+	if got.Text != "ls -la" {
+		t.Errorf("Text = %q, want %q", got.Text, "ls -la")
+	}
+	if got.Structured {
+		t.Error("Structured = true, want false")
+	}
+}
+
+func TestAnthropicChatErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"type":"error","error":{"type":"overloaded_error"}}`, 529)
+			},
+			wantErr: "anthropic chat failed",
+		},
+		{
+			name: "invalid JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("{not-json"))
+			},
+			wantErr: "decoding anthropic chat response",
+		},
+		{
+			name: "no text blocks",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				resp := map[string]any{
+					"content": []map[string]string{{"type": "thinking", "thinking": "hmm"}},
+				}
+				_ = json.NewEncoder(w).Encode(resp)
+			},
+			wantErr: "empty response from model",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			p := newTestAnthropic(t, srv.URL, "claude-sonnet-4-5")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := p.Chat(ctx, ChatRequest{
+				Messages: []Message{{Role: "user", Content: "hello"}},
+			})
+			if err == nil {
+				t.Fatalf("Chat() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Chat() error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnthropicHTTPErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantKind error
+	}{
+		{"unauthorized", http.StatusUnauthorized, ErrAuth},
+		{"model not found", http.StatusNotFound, ErrModelNotFound},
+		{"rate limited", http.StatusTooManyRequests, ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"type":"error","error":{"type":"some_error"}}`, tt.status)
+			}))
+			defer srv.Close()
+
+			p := newTestAnthropic(t, srv.URL, "claude-sonnet-4-5")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, chatErr := p.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hello"}}})
+			for op, err := range map[string]error{"Chat": chatErr, "Available": p.Available(ctx)} {
+				if !errors.Is(err, tt.wantKind) {
+					t.Errorf("%s() error = %v, want %v", op, err, tt.wantKind)
+				}
+				var httpErr *HTTPError
+				if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+					t.Errorf("%s() HTTPError = %+v, want status %d", op, httpErr, tt.status)
+				}
+			}
+		})
+	}
+}
+
+func TestAnthropicChatRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		status    int
+		body      string
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "overloaded then success", failures: 1, status: 529, body: `{"type":"error","error":{"type":"overloaded_error"}}`, wantCalls: 2},
+		{name: "rate limited then success", failures: 2, status: http.StatusTooManyRequests, body: `{"type":"error","error":{"type":"rate_limit_error"}}`, wantCalls: 3},
+		{name: "overloaded exhausted", failures: 5, status: 529, body: `{"type":"error","error":{"type":"overloaded_error"}}`, wantCalls: 3, wantErr: ErrTransient},
+		{name: "prompt too long not retried", failures: 5, status: http.StatusBadRequest, body: `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 208000 tokens > 200000 maximum"}}`, wantCalls: 1, wantErr: ErrContextLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= tt.failures {
+					http.Error(w, tt.body, tt.status)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"content": []map[string]string{{"type": "text", "text": "ok"}},
+				})
+			}))
+			defer srv.Close()
+
+			p := newTestAnthropic(t, srv.URL, "claude-sonnet-4-5")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			got, err := p.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hello"}}})
+			if calls != tt.wantCalls {
+				t.Errorf("server calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Text != "ok" {
+					t.Errorf("Text = %q, want %q", got.Text, "ok")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
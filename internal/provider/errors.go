@@ -0,0 +1,108 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error classes for backend failures. Match them with errors.Is; the
+// *HTTPError carrying the details is available through errors.As.
+var (
+	// ErrRateLimited means the backend throttled the request (HTTP 429).
+	ErrRateLimited = errors.New("rate limited")
+	// ErrAuth means the credentials were missing, invalid, or lack access.
+	ErrAuth = errors.New("authentication failed")
+	// ErrModelNotFound means the backend does not serve the requested model.
+	ErrModelNotFound = errors.New("model not found")
+	// ErrContextLength means the conversation exceeds the model's context window.
+	ErrContextLength = errors.New("context length exceeded")
+	// ErrTransient means a temporary server-side failure worth retrying.
+	ErrTransient = errors.New("transient backend error")
+)
+
+// HTTPError is a non-2xx response from an HTTP backend.
+type HTTPError struct {
+	// Op names the failed operation, e.g. "openai chat".
+	Op         string
+	StatusCode int
+	// RetryAfter is the server's Retry-After hint, zero when absent.
+	RetryAfter time.Duration
+	// Body is the (truncated) response body, used as the error message.
+	Body string
+	// Kind is one of the Err* classes, nil when the status is unclassified.
+	Kind error
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Op, e.Body)
+}
+
+func (e *HTTPError) Unwrap() error { return e.Kind }
+
+// newHTTPError reads and classifies a failed response. The caller still owns
+// resp.Body and must close it.
+func newHTTPError(op string, resp *http.Response) *HTTPError {
+	body := readErrorBody(resp.Body)
+	return &HTTPError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		Body:       body,
+		Kind:       classifyStatus(resp.StatusCode, body),
+	}
+}
+
+// classifyStatus maps an HTTP status (and, for ambiguous 400s, the error body)
+// to an error class.
+func classifyStatus(status int, body string) error {
+	switch {
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusNotFound:
+		return ErrModelNotFound
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrContextLength
+	case status == http.StatusBadRequest && isContextLengthBody(body):
+		return ErrContextLength
+	case status == http.StatusRequestTimeout || status >= http.StatusInternalServerError:
+		return ErrTransient
+	default:
+		return nil
+	}
+}
+
+// isContextLengthBody recognizes the context overflow errors that OpenAI,
+// Anthropic and compatible gateways report as a plain 400.
+func isContextLengthBody(body string) bool {
+	lower := strings.ToLower(body)
+	return strings.Contains(lower, "context_length_exceeded") ||
+		strings.Contains(lower, "maximum context length") ||
+		strings.Contains(lower, "context window") ||
+		strings.Contains(lower, "prompt is too long")
+}
+
+// parseRetryAfter accepts both Retry-After forms: delay-seconds and HTTP-date.
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
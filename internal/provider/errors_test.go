@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		retryAfter string
+		wantKind   error
+		wantRetry  time.Duration
+	}{
+		{name: "rate limited", status: 429, body: "slow down", retryAfter: "3", wantKind: ErrRateLimited, wantRetry: 3 * time.Second},
+		{name: "unauthorized", status: 401, body: "bad key", wantKind: ErrAuth},
+		{name: "forbidden", status: 403, body: "no access", wantKind: ErrAuth},
+		{name: "model not found", status: 404, body: `{"error":{"code":"model_not_found"}}`, wantKind: ErrModelNotFound},
+		{name: "context length", status: 400, body: `{"error":{"code":"context_length_exceeded"}}`, wantKind: ErrContextLength},
+		{name: "anthropic prompt too long", status: 400, body: `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 208000 tokens > 200000 maximum"}}`, wantKind: ErrContextLength},
+		{name: "payload too large", status: 413, body: "too large", wantKind: ErrContextLength},
+		{name: "plain bad request", status: 400, body: "invalid parameter", wantKind: nil},
+		{name: "server error", status: 500, body: "oops", wantKind: ErrTransient},
+		{name: "unavailable", status: 503, body: "overloaded", retryAfter: "bogus", wantKind: ErrTransient},
+		{name: "anthropic overloaded", status: 529, body: `{"type":"error","error":{"type":"overloaded_error"}}`, wantKind: ErrTransient},
+		{name: "request timeout", status: 408, body: "timeout", wantKind: ErrTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+
+			err := newHTTPError("test chat", resp)
+			if err.StatusCode != tt.status {
+				t.Errorf("StatusCode = %d, want %d", err.StatusCode, tt.status)
+			}
+			if err.RetryAfter != tt.wantRetry {
+				t.Errorf("RetryAfter = %v, want %v", err.RetryAfter, tt.wantRetry)
+			}
+			if want := "test chat failed: " + tt.body; err.Error() != want {
+				t.Errorf("Error() = %q, want %q", err.Error(), want)
+			}
+			if tt.wantKind == nil {
+				if err.Kind != nil {
+					t.Errorf("Kind = %v, want nil", err.Kind)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantKind) {
+				t.Errorf("errors.Is(err, %v) = false, Kind = %v", tt.wantKind, err.Kind)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+
+	tests := []struct {
+		name  string
+		value string
+		check func(time.Duration) bool
+	}{
+		{"empty", "", func(d time.Duration) bool { return d == 0 }},
+		{"seconds", "7", func(d time.Duration) bool { return d == 7*time.Second }},
+		{"negative seconds", "-1", func(d time.Duration) bool { return d == 0 }},
+		{"http date", future, func(d time.Duration) bool { return d > 59*time.Minute && d <= time.Hour }},
+		{"past date", past, func(d time.Duration) bool { return d == 0 }},
+		{"garbage", "soon", func(d time.Duration) bool { return d == 0 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value); !tt.check(got) {
+				t.Errorf("parseRetryAfter(%q) = %v", tt.value, got)
+			}
+		})
+	}
+}
@@ -13,6 +13,11 @@ type BuildConfig struct {
 	OpenAIHost   string
 	OpenAIAPIKey string
 	AFMCommand   string
+
+	AnthropicHost   string
+	AnthropicAPIKey string
+	GeminiHost      string
+	GeminiAPIKey    string
 }
 
 // NewFromConfig builds the configured provider implementation.
@@ -24,6 +29,10 @@ func NewFromConfig(cfg BuildConfig) (Provider, error) {
 		return NewOpenAI(cfg.OpenAIHost, cfg.Model, cfg.OpenAIAPIKey)
 	case "afm":
 		return NewAFM(cfg.Model, cfg.AFMCommand)
+	case "anthropic":
+		return NewAnthropic(cfg.AnthropicHost, cfg.Model, cfg.AnthropicAPIKey)
+	case "gemini":
+		return NewGemini(cfg.GeminiHost, cfg.Model, cfg.GeminiAPIKey)
 	default:
 		return nil, fmt.Errorf("unsupported provider %q", cfg.Name)
 	}
@@ -25,6 +25,7 @@ func TestNewFromConfig(t *testing.T) {
 				JSONMode:     true,
 				Usage:        true,
 				FinishReason: true,
+				Streaming:    true,
 			},
 		},
 		{
@@ -40,6 +41,7 @@ func TestNewFromConfig(t *testing.T) {
 				JSONMode:     true,
 				Usage:        true,
 				FinishReason: true,
+				Streaming:    true,
 			},
 		},
 		{
@@ -54,8 +56,57 @@ func TestNewFromConfig(t *testing.T) {
 				JSONMode:     true,
 				Usage:        false,
 				FinishReason: false,
+				Streaming:    true,
 			},
 		},
+		{
+			name: "anthropic",
+			cfg: BuildConfig{
+				Name:            "anthropic",
+				Model:           "claude-sonnet-4-5",
+				AnthropicHost:   "https://api.anthropic.com/v1",
+				AnthropicAPIKey: "test-key",
+			},
+			want: "anthropic",
+			wantCaps: Capabilities{
+				JSONMode:     true,
+				Usage:        true,
+				FinishReason: true,
+			},
+		},
+		{
+			name: "anthropic missing key",
+			cfg: BuildConfig{
+				Name:          "anthropic",
+				Model:         "claude-sonnet-4-5",
+				AnthropicHost: "https://api.anthropic.com/v1",
+			},
+			wantErr: "ANTHROPIC_API_KEY",
+		},
+		{
+			name: "gemini",
+			cfg: BuildConfig{
+				Name:         "gemini",
+				Model:        "gemini-2.5-flash",
+				GeminiHost:   "https://generativelanguage.googleapis.com/v1beta",
+				GeminiAPIKey: "test-key",
+			},
+			want: "gemini",
+			wantCaps: Capabilities{
+				JSONMode:     true,
+				Usage:        true,
+				FinishReason: true,
+			},
+		},
+		{
+			name: "gemini missing key",
+			cfg: BuildConfig{
+				Name:       "gemini",
+				Model:      "gemini-2.5-flash",
+				GeminiHost: "https://generativelanguage.googleapis.com/v1beta",
+			},
+			wantErr: "GEMINI_API_KEY",
+		},
 		{
 			name: "openai missing key",
 			cfg: BuildConfig{
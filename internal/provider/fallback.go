@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os/exec"
+	"strings"
+)
+
+// FallbackMember is one backend in a Fallback chain.
+type FallbackMember struct {
+	Provider Provider
+	// Model, when set, replaces ChatRequest.Model for this member so a chain
+	// can mix backends whose model names differ.
+	Model string
+}
+
+// Fallback is a composite Provider that tries an ordered chain of backends.
+// It moves on to the next member only when a backend cannot be reached
+// (connection refused, DNS failure, timeout, missing bridge executable).
+// Any other error, such as a model refusal or a bad request, is returned
+// as-is: another backend would not fix it.
+type Fallback struct {
+	members []FallbackMember
+	// down marks members whose last Available check failed; Chat skips them.
+	down []bool
+}
+
+// NewFallback creates a Fallback over members, tried in the given order.
+func NewFallback(members ...FallbackMember) (*Fallback, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("fallback chain needs at least one provider")
+	}
+	for i, m := range members {
+		if m.Provider == nil {
+			return nil, fmt.Errorf("fallback chain member %d is nil", i)
+		}
+	}
+	return &Fallback{members: members, down: make([]bool, len(members))}, nil
+}
+
+func (f *Fallback) Name() string { return "fallback" }
+
+// Capabilities reports Streaming when any member streams, so StreamChat
+// routes through ChatStream; the other features are only claimed when every
+// member supports them.
+func (f *Fallback) Capabilities() Capabilities {
+	caps := Capabilities{JSONMode: true, Usage: true, FinishReason: true}
+	for _, m := range f.members {
+		c := m.Provider.Capabilities()
+		caps.JSONMode = caps.JSONMode && c.JSONMode
+		caps.Usage = caps.Usage && c.Usage
+		caps.FinishReason = caps.FinishReason && c.FinishReason
+		caps.Streaming = caps.Streaming || c.Streaming
+	}
+	return caps
+}
+
+// Available succeeds when at least one member is ready. Members that fail
+// are skipped by subsequent Chat calls until the next Available check.
+func (f *Fallback) Available(ctx context.Context) error {
+	var errs []error
+	ready := false
+	for i, m := range f.members {
+		if err := m.Provider.Available(ctx); err != nil {
+			f.down[i] = true
+			errs = append(errs, fmt.Errorf("%s: %w", m.Provider.Name(), err))
+			continue
+		}
+		f.down[i] = false
+		ready = true
+	}
+	if ready {
+		return nil
+	}
+	return fmt.Errorf("no provider in fallback chain is ready: %w", errors.Join(errs...))
+}
+
+func (f *Fallback) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	return f.chat(ctx, req, func(m FallbackMember, req ChatRequest) (ChatResponse, bool, error) {
+		resp, err := m.Provider.Chat(ctx, req)
+		return resp, false, err
+	})
+}
+
+// ChatStream streams from the first reachable member. Once a member has
+// emitted a delta the chain is committed to it: falling through mid-response
+// would splice two different answers together on screen.
+func (f *Fallback) ChatStream(ctx context.Context, req ChatRequest, fn StreamFunc) (ChatResponse, error) {
+	return f.chat(ctx, req, func(m FallbackMember, req ChatRequest) (ChatResponse, bool, error) {
+		emitted := false
+		resp, err := StreamChat(ctx, m.Provider, req, func(delta string) {
+			emitted = true
+			fn(delta)
+		})
+		return resp, emitted, err
+	})
+}
+
+// chat runs send against each member in order. send reports whether the
+// member already produced user-visible output, which pins the chain to it.
+func (f *Fallback) chat(ctx context.Context, req ChatRequest, send func(FallbackMember, ChatRequest) (ChatResponse, bool, error)) (ChatResponse, error) {
+	var skipped []string
+	var errs []error
+	for i, m := range f.members {
+		if f.down[i] {
+			skipped = append(skipped, m.Provider.Name())
+			continue
+		}
+
+		memberReq := req
+		if m.Model != "" {
+			memberReq.Model = m.Model
+		}
+
+		resp, emitted, err := send(m, memberReq)
+		if err == nil {
+			if len(skipped) > 0 {
+				resp.Warning = joinWarnings(
+					fmt.Sprintf("%s unreachable; answered by %s", strings.Join(skipped, ", "), m.Provider.Name()),
+					resp.Warning,
+				)
+			}
+			return resp, nil
+		}
+		if emitted || ctx.Err() != nil || !isUnreachable(err) {
+			return ChatResponse{}, err
+		}
+		skipped = append(skipped, m.Provider.Name())
+		errs = append(errs, fmt.Errorf("%s: %w", m.Provider.Name(), err))
+	}
+	if len(errs) == 0 {
+		return ChatResponse{}, fmt.Errorf("no provider in fallback chain is ready")
+	}
+	return ChatResponse{}, fmt.Errorf("all providers in fallback chain failed: %w", errors.Join(errs...))
+}
+
+// isUnreachable reports whether err means the backend could not be reached at
+// all, as opposed to a response the backend chose to give.
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	// A missing AFM bridge is the subprocess equivalent of a refused connection.
+	return errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist)
+}
+
+func joinWarnings(warnings ...string) string {
+	var parts []string
+	for _, w := range warnings {
+		if w != "" {
+			parts = append(parts, w)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
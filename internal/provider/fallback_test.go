@@ -0,0 +1,311 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// chainMember is a scripted backend for Fallback tests.
+type chainMember struct {
+	name      string
+	availErr  error
+	chatErr   error
+	text      string
+	warning   string
+	streaming bool
+	deltas    []string
+	chatCalls int
+	gotModel  string
+}
+
+func (c *chainMember) Chat(_ context.Context, req ChatRequest) (ChatResponse, error) {
+	c.chatCalls++
+	c.gotModel = req.Model
+	if c.chatErr != nil {
+		return ChatResponse{}, c.chatErr
+	}
+	return ChatResponse{Text: c.text, Warning: c.warning}, nil
+}
+
+func (c *chainMember) ChatStream(_ context.Context, req ChatRequest, fn StreamFunc) (ChatResponse, error) {
+	c.chatCalls++
+	c.gotModel = req.Model
+	for _, d := range c.deltas {
+		fn(d)
+	}
+	if c.chatErr != nil {
+		return ChatResponse{}, c.chatErr
+	}
+	return ChatResponse{Text: c.text, Warning: c.warning}, nil
+}
+
+func (c *chainMember) Name() string { return c.name }
+func (c *chainMember) Capabilities() Capabilities {
+	return Capabilities{JSONMode: true, Usage: c.streaming, FinishReason: true, Streaming: c.streaming}
+}
+func (c *chainMember) Available(_ context.Context) error { return c.availErr }
+
+var errRefused = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connect: connection refused")}
+
+func newTestFallback(t *testing.T, members ...*chainMember) *Fallback {
+	t.Helper()
+	fm := make([]FallbackMember, len(members))
+	for i, m := range members {
+		fm[i] = FallbackMember{Provider: m}
+	}
+	f, err := NewFallback(fm...)
+	if err != nil {
+		t.Fatalf("NewFallback(): %v", err)
+	}
+	return f
+}
+
+func TestNewFallback(t *testing.T) {
+	if _, err := NewFallback(); err == nil || !strings.Contains(err.Error(), "at least one provider") {
+		t.Errorf("NewFallback() error = %v, want at least one provider", err)
+	}
+	if _, err := NewFallback(FallbackMember{}); err == nil || !strings.Contains(err.Error(), "is nil") {
+		t.Errorf("NewFallback(nil member) error = %v, want nil member error", err)
+	}
+}
+
+func TestFallbackNameAndCapabilities(t *testing.T) {
+	f := newTestFallback(t,
+		&chainMember{name: "a"},
+		&chainMember{name: "b", streaming: true},
+	)
+	if got := f.Name(); got != "fallback" {
+		t.Errorf("Name() = %q, want %q", got, "fallback")
+	}
+	want := Capabilities{JSONMode: true, Usage: false, FinishReason: true, Streaming: true}
+	if got := f.Capabilities(); got != want {
+		t.Errorf("Capabilities() = %+v, want %+v", got, want)
+	}
+}
+
+func TestFallbackAvailable(t *testing.T) {
+	t.Run("any member ready", func(t *testing.T) {
+		f := newTestFallback(t,
+			&chainMember{name: "a", availErr: errRefused},
+			&chainMember{name: "b"},
+		)
+		if err := f.Available(context.Background()); err != nil {
+			t.Errorf("Available() unexpected error: %v", err)
+		}
+	})
+
+	t.Run("no member ready", func(t *testing.T) {
+		f := newTestFallback(t,
+			&chainMember{name: "a", availErr: errRefused},
+			&chainMember{name: "b", availErr: errors.New("model missing")},
+		)
+		err := f.Available(context.Background())
+		if err == nil {
+			t.Fatal("Available() expected error, got nil")
+		}
+		for _, want := range []string{"no provider in fallback chain is ready", "a: dial tcp", "b: model missing"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Available() error = %q, want substring %q", err.Error(), want)
+			}
+		}
+	})
+}
+
+func TestFallbackChat(t *testing.T) {
+	tests := []struct {
+		name        string
+		members     []*chainMember
+		checkAvail  bool
+		wantText    string
+		wantWarning string
+		wantErr     string
+		wantCalls   []int
+	}{
+		{
+			name:      "first member answers",
+			members:   []*chainMember{{name: "a", text: "from a"}, {name: "b", text: "from b"}},
+			wantText:  "from a",
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:        "connection error falls through",
+			members:     []*chainMember{{name: "a", chatErr: fmt.Errorf("ollama chat: %w", errRefused)}, {name: "b", text: "from b"}},
+			wantText:    "from b",
+			wantWarning: "a unreachable; answered by b",
+			wantCalls:   []int{1, 1},
+		},
+		{
+			name:        "timeout falls through",
+			members:     []*chainMember{{name: "a", chatErr: fmt.Errorf("openai chat: %w", timeoutErr{})}, {name: "b", text: "from b"}},
+			wantText:    "from b",
+			wantWarning: "a unreachable; answered by b",
+			wantCalls:   []int{1, 1},
+		},
+		{
+			name:        "missing bridge falls through",
+			members:     []*chainMember{{name: "afm", chatErr: fmt.Errorf("afm bridge execution failed: %w", exec.ErrNotFound)}, {name: "b", text: "from b"}},
+			wantText:    "from b",
+			wantWarning: "afm unreachable; answered by b",
+			wantCalls:   []int{1, 1},
+		},
+		{
+			name:      "model refusal does not fall through",
+			members:   []*chainMember{{name: "a", chatErr: errors.New("a chat failed: content policy")}, {name: "b", text: "from b"}},
+			wantErr:   "content policy",
+			wantCalls: []int{1, 0},
+		},
+		{
+			name:        "member warning preserved",
+			members:     []*chainMember{{name: "a", chatErr: errRefused}, {name: "b", text: "from b", warning: "history trimmed"}},
+			wantText:    "from b",
+			wantWarning: "a unreachable; answered by b; history trimmed",
+			wantCalls:   []int{1, 1},
+		},
+		{
+			name:        "unavailable member skipped",
+			members:     []*chainMember{{name: "a", availErr: errRefused, text: "from a"}, {name: "b", text: "from b"}},
+			checkAvail:  true,
+			wantText:    "from b",
+			wantWarning: "a unreachable; answered by b",
+			wantCalls:   []int{0, 1},
+		},
+		{
+			name:      "all members unreachable",
+			members:   []*chainMember{{name: "a", chatErr: errRefused}, {name: "b", chatErr: errRefused}},
+			wantErr:   "all providers in fallback chain failed",
+			wantCalls: []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestFallback(t, tt.members...)
+			if tt.checkAvail {
+				if err := f.Available(context.Background()); err != nil {
+					t.Fatalf("Available() unexpected error: %v", err)
+				}
+			}
+
+			got, err := f.Chat(context.Background(), ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}})
+			for i, m := range tt.members {
+				if m.chatCalls != tt.wantCalls[i] {
+					t.Errorf("member %s chat calls = %d, want %d", m.name, m.chatCalls, tt.wantCalls[i])
+				}
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Chat() error = %v, want substring %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Chat() unexpected error: %v", err)
+			}
+			if got.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", got.Text, tt.wantText)
+			}
+			if got.Warning != tt.wantWarning {
+				t.Errorf("Warning = %q, want %q", got.Warning, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestFallbackChatCanceledContext(t *testing.T) {
+	a := &chainMember{name: "a", chatErr: context.Canceled}
+	b := &chainMember{name: "b", text: "from b"}
+	f := newTestFallback(t, a, b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := f.Chat(ctx, ChatRequest{}); err == nil {
+		t.Fatal("Chat() expected error for canceled context, got nil")
+	}
+	if b.chatCalls != 0 {
+		t.Errorf("second member called %d times after cancel, want 0", b.chatCalls)
+	}
+}
+
+func TestFallbackPinnedModel(t *testing.T) {
+	a := &chainMember{name: "a", chatErr: errRefused}
+	b := &chainMember{name: "b", text: "ok"}
+	f, err := NewFallback(
+		FallbackMember{Provider: a},
+		FallbackMember{Provider: b, Model: "gpt-4o-mini"},
+	)
+	if err != nil {
+		t.Fatalf("NewFallback(): %v", err)
+	}
+
+	if _, err := f.Chat(context.Background(), ChatRequest{Model: "llama3.2:latest"}); err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if a.gotModel != "llama3.2:latest" {
+		t.Errorf("unpinned member model = %q, want request model", a.gotModel)
+	}
+	if b.gotModel != "gpt-4o-mini" {
+		t.Errorf("pinned member model = %q, want %q", b.gotModel, "gpt-4o-mini")
+	}
+}
+
+func TestFallbackChatStream(t *testing.T) {
+	t.Run("falls through before output", func(t *testing.T) {
+		f := newTestFallback(t,
+			&chainMember{name: "a", streaming: true, chatErr: errRefused},
+			&chainMember{name: "b", streaming: true, deltas: []string{"he", "llo"}, text: "hello"},
+		)
+		var deltas strings.Builder
+		got, err := StreamChat(context.Background(), f, ChatRequest{}, func(d string) { deltas.WriteString(d) })
+		if err != nil {
+			t.Fatalf("StreamChat() unexpected error: %v", err)
+		}
+		if deltas.String() != "hello" || got.Text != "hello" {
+			t.Errorf("deltas = %q, Text = %q, want %q", deltas.String(), got.Text, "hello")
+		}
+		if got.Warning != "a unreachable; answered by b" {
+			t.Errorf("Warning = %q, want fallback notice", got.Warning)
+		}
+	})
+
+	t.Run("non-streaming member in chain", func(t *testing.T) {
+		f := newTestFallback(t,
+			&chainMember{name: "a", streaming: true, chatErr: errRefused},
+			&chainMember{name: "b", text: "blocking"},
+		)
+		var deltas strings.Builder
+		got, err := StreamChat(context.Background(), f, ChatRequest{}, func(d string) { deltas.WriteString(d) })
+		if err != nil {
+			t.Fatalf("StreamChat() unexpected error: %v", err)
+		}
+		if deltas.Len() != 0 || got.Text != "blocking" {
+			t.Errorf("deltas = %q, Text = %q, want no deltas and %q", deltas.String(), got.Text, "blocking")
+		}
+	})
+
+	t.Run("no fall through after output", func(t *testing.T) {
+		b := &chainMember{name: "b", streaming: true, text: "from b"}
+		f := newTestFallback(t,
+			&chainMember{name: "a", streaming: true, deltas: []string{"partial"}, chatErr: errRefused},
+			b,
+		)
+		_, err := StreamChat(context.Background(), f, ChatRequest{}, func(string) {})
+		if err == nil {
+			t.Fatal("StreamChat() expected error after partial output, got nil")
+		}
+		if b.chatCalls != 0 {
+			t.Errorf("second member called %d times after partial output, want 0", b.chatCalls)
+		}
+	})
+}
+
+// timeoutErr is a net.Error that reports a timeout, like http.Client's.
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "Client.Timeout exceeded while awaiting headers" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GeminiProvider implements Provider using the Gemini generateContent API.
+type GeminiProvider struct {
+	client *http.Client
+	host   string
+	model  string
+	apiKey string
+	retry  RetryPolicy
+}
+
+// NewGemini creates a GeminiProvider connected to the given host and model.
+func NewGemini(host, model, apiKey string) (*GeminiProvider, error) {
+	base := strings.TrimSpace(host)
+	if base == "" {
+		return nil, fmt.Errorf("gemini host cannot be empty")
+	}
+	if _, err := url.ParseRequestURI(base); err != nil {
+		return nil, fmt.Errorf("parsing gemini host URL: %w", err)
+	}
+	if strings.TrimSpace(model) == "" {
+		return nil, fmt.Errorf("model cannot be empty")
+	}
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, fmt.Errorf("gemini api key is required (set GEMINI_API_KEY)")
+	}
+
+	return &GeminiProvider{
+		client: &http.Client{Timeout: 30 * time.Second},
+		host:   strings.TrimRight(base, "/"),
+		model:  model,
+		apiKey: apiKey,
+		retry:  DefaultRetryPolicy,
+	}, nil
+}
+
+func (g *GeminiProvider) Name() string { return "gemini" }
+
+func (g *GeminiProvider) Capabilities() Capabilities {
+	return Capabilities{
+		JSONMode:     true,
+		Usage:        true,
+		FinishReason: true,
+	}
+}
+
+// Available checks if Gemini is reachable and the configured model exists.
+func (g *GeminiProvider) Available(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.modelURL(g.model), nil)
+	if err != nil {
+		return fmt.Errorf("building gemini availability request: %w", err)
+	}
+	g.setHeaders(req)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("checking gemini availability: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		err := newHTTPError("gemini availability check", resp)
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("model %q not found in Gemini models list: %w", g.model, err)
+		}
+		return err
+	}
+
+	var decoded struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("decoding gemini model response: %w", err)
+	}
+	if decoded.Name == "" {
+		return fmt.Errorf("model %q not found in Gemini models list", g.model)
+	}
+	return nil
+}
+
+// Chat sends the conversation to generateContent and returns the first
+// candidate. Gemini calls the assistant role "model" and takes system
+// messages as a separate systemInstruction.
+func (g *GeminiProvider) Chat(ctx context.Context, chatReq ChatRequest) (ChatResponse, error) {
+	type geminiPart struct {
+		Text string `json:"text"`
+	}
+
+	type geminiContent struct {
+		Role  string       `json:"role,omitempty"`
+		Parts []geminiPart `json:"parts"`
+	}
+
+	type geminiGenerationConfig struct {
+		ResponseMIMEType string `json:"responseMimeType,omitempty"`
+	}
+
+	type geminiRequest struct {
+		SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+		Contents          []geminiContent         `json:"contents"`
+		GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	}
+
+	var reqBody geminiRequest
+	var systemParts []geminiPart
+	for _, m := range chatReq.Messages {
+		switch m.Role {
+		case "system":
+			systemParts = append(systemParts, geminiPart{Text: m.Content})
+		case "assistant":
+			reqBody.Contents = append(reqBody.Contents, geminiContent{Role: "model", Parts: []geminiPart{{Text: m.Content}}})
+		default:
+			reqBody.Contents = append(reqBody.Contents, geminiContent{Role: m.Role, Parts: []geminiPart{{Text: m.Content}}})
+		}
+	}
+	if len(systemParts) > 0 {
+		reqBody.SystemInstruction = &geminiContent{Parts: systemParts}
+	}
+	if chatReq.ExpectJSON {
+		reqBody.GenerationConfig = &geminiGenerationConfig{ResponseMIMEType: "application/json"}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("encoding gemini chat request: %w", err)
+	}
+
+	resp, err := g.send(ctx, resolveModel(chatReq.Model, g.model), body)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var decoded struct {
+		Candidates []struct {
+			Content struct {
+				Parts []geminiPart `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason string `json:"blockReason"`
+		} `json:"promptFeedback"`
+		UsageMetadata struct {
+			PromptTokenCount     int `json:"promptTokenCount"`
+			CandidatesTokenCount int `json:"candidatesTokenCount"`
+			TotalTokenCount      int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return ChatResponse{}, fmt.Errorf("decoding gemini chat response: %w", err)
+	}
+	if len(decoded.Candidates) == 0 {
+		if decoded.PromptFeedback.BlockReason != "" {
+			return ChatResponse{}, fmt.Errorf("gemini blocked the prompt: %s", decoded.PromptFeedback.BlockReason)
+		}
+		return ChatResponse{}, fmt.Errorf("gemini returned no candidates")
+	}
+
+	candidate := decoded.Candidates[0]
+	var text strings.Builder
+	for _, part := range candidate.Content.Parts {
+		text.WriteString(part.Text)
+	}
+	result := strings.TrimSpace(text.String())
+	if result == "" {
+		return ChatResponse{}, fmt.Errorf("empty response from model")
+	}
+
+	usage := Usage{
+		InputTokens:  decoded.UsageMetadata.PromptTokenCount,
+		OutputTokens: decoded.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:  decoded.UsageMetadata.TotalTokenCount,
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	}
+
+	return ChatResponse{
+		Text:         result,
+		Raw:          result,
+		Structured:   isStructuredJSON(chatReq.ExpectJSON, result),
+		FinishReason: candidate.FinishReason,
+		Usage:        usage,
+	}, nil
+}
+
+// modelURL returns the resource URL for model. Names from the models list
+// already carry the "models/" prefix; bare names like "gemini-2.5-flash" do not.
+func (g *GeminiProvider) modelURL(model string) string {
+	name := strings.TrimPrefix(model, "models/")
+	return g.host + "/models/" + url.PathEscape(name)
+}
+
+// send posts the generateContent request for model and returns the
+// successful response, retrying rate limits and transient errors per
+// g.retry. The caller must close the response body.
+func (g *GeminiProvider) send(ctx context.Context, model string, body []byte) (*http.Response, error) {
+	var resp *http.Response
+	err := g.retry.do(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.modelURL(model)+":generateContent", bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("building gemini chat request: %w", err)
+		}
+		g.setHeaders(req)
+		req.Header.Set("Content-Type", "application/json")
+
+		r, err := g.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("gemini chat: %w", err)
+		}
+		if r.StatusCode >= http.StatusBadRequest {
+			defer func() { _ = r.Body.Close() }()
+			return newHTTPError("gemini chat", r)
+		}
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+func (g *GeminiProvider) setHeaders(req *http.Request) {
+	req.Header.Set("x-goog-api-key", g.apiKey)
+}
@@ -0,0 +1,456 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestGemini(t *testing.T, serverURL, model string) *GeminiProvider {
+	t.Helper()
+	p, err := NewGemini(serverURL, model, "test-key")
+	if err != nil {
+		t.Fatalf("NewGemini(%q, %q): %v", serverURL, model, err)
+	}
+	return p
+}
+
+func TestNewGemini(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		model   string
+		key     string
+		wantErr string
+	}{
+		{name: "valid", host: "https://generativelanguage.googleapis.com/v1beta", model: "gemini-2.5-flash", key: "test"},
+		{name: "empty host", host: "", model: "gemini-2.5-flash", key: "test", wantErr: "host cannot be empty"},
+		{name: "invalid host", host: "://broken", model: "gemini-2.5-flash", key: "test", wantErr: "parsing gemini host URL"},
+		{name: "empty model", host: "https://generativelanguage.googleapis.com/v1beta", model: "", key: "test", wantErr: "model cannot be empty"},
+		{name: "empty key", host: "https://generativelanguage.googleapis.com/v1beta", model: "gemini-2.5-flash", key: "", wantErr: "GEMINI_API_KEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewGemini(tt.host, tt.model, tt.key)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewGemini() unexpected error: %v", err)
+				}
+				if p == nil {
+					t.Fatal("NewGemini() returned nil provider")
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("NewGemini() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGeminiNameAndCapabilities(t *testing.T) {
+	p, _ := NewGemini("https://generativelanguage.googleapis.com/v1beta", "gemini-2.5-flash", "test-key")
+
+	if got := p.Name(); got != "gemini" {
+		t.Errorf("Name() = %q, want %q", got, "gemini")
+	}
+
+	gotCaps := p.Capabilities()
+	wantCaps := Capabilities{
+		JSONMode:     true,
+		Usage:        true,
+		FinishReason: true,
+	}
+	if gotCaps != wantCaps {
+		t.Errorf("Capabilities() = %+v, want %+v", gotCaps, wantCaps)
+	}
+}
+
+func TestGeminiAvailable(t *testing.T) {
+	tests := []struct {
+		name    string
+		model   string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{
+			name:  "model found",
+			model: "gemini-2.5-flash",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/models/gemini-2.5-flash" {
+					t.Errorf("path = %q, want %q", r.URL.Path, "/models/gemini-2.5-flash")
+				}
+				if got := r.Header.Get("x-goog-api-key"); got != "test-key" {
+					t.Errorf("x-goog-api-key header = %q, want %q", got, "test-key")
+				}
+				_ = json.NewEncoder(w).Encode(map[string]string{"name": "models/gemini-2.5-flash"})
+			},
+		},
+		{
+			name:  "prefixed model name",
+			model: "models/gemini-2.5-flash",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/models/gemini-2.5-flash" {
+					t.Errorf("path = %q, want %q", r.URL.Path, "/models/gemini-2.5-flash")
+				}
+				_ = json.NewEncoder(w).Encode(map[string]string{"name": "models/gemini-2.5-flash"})
+			},
+		},
+		{
+			name:  "model missing",
+			model: "missing-model",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":{"status":"NOT_FOUND"}}`, http.StatusNotFound)
+			},
+			wantErr: "not found",
+		},
+		{
+			name:  "forbidden",
+			model: "gemini-2.5-flash",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":{"status":"PERMISSION_DENIED"}}`, http.StatusForbidden)
+			},
+			wantErr: "availability check failed",
+		},
+		{
+			name:  "invalid JSON",
+			model: "gemini-2.5-flash",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("{not-json"))
+			},
+			wantErr: "decoding gemini model response",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			p := newTestGemini(t, srv.URL, tt.model)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := p.Available(ctx)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Available() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Available() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Available() error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGeminiChat(t *testing.T) {
+	type part struct {
+		Text string `json:"text"`
+	}
+	type content struct {
+		Role  string `json:"role"`
+		Parts []part `json:"parts"`
+	}
+	var gotReq struct {
+		SystemInstruction *content  `json:"systemInstruction"`
+		Contents          []content `json:"contents"`
+		GenerationConfig  struct {
+			ResponseMIMEType string `json:"responseMimeType"`
+		} `json:"generationConfig"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models/override-model:generateContent" {
+			t.Fatalf("path = %q, want %q", r.URL.Path, "/models/override-model:generateContent")
+		}
+		if got := r.Header.Get("x-goog-api-key"); got != "test-key" {
+			t.Fatalf("x-goog-api-key header = %q, want %q", got, "test-key")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+
+		resp := map[string]any{
+			"candidates": []map[string]any{
+				{
+					"content": map[string]any{
+						"role":  "model",
+						"parts": []map[string]string{{"text": `{"text":"ok",`}, {"text": `"commands":[]}`}},
+					},
+					"finishReason": "STOP",
+				},
+			},
+			"usageMetadata": map[string]int{
+				"promptTokenCount":     12,
+				"candidatesTokenCount": 5,
+				"totalTokenCount":      17,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	p := newTestGemini(t, srv.URL, "default-model")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := p.Chat(ctx, ChatRequest{
+		Messages: []Message{
+			{Role: "system", Content: "sys"},
+			{Role: "user", Content: "hello"},
+			{Role: "assistant", Content: "hi"},
+			{Role: "user", Content: "list files"},
+		},
+		Model:      "override-model",
+		ExpectJSON: true,
+	})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+
+	if gotReq.SystemInstruction == nil || len(gotReq.SystemInstruction.Parts) != 1 || gotReq.SystemInstruction.Parts[0].Text != "sys" {
+		t.Errorf("systemInstruction = %+v, want single part %q", gotReq.SystemInstruction, "sys")
+	}
+	wantRoles := []string{"user", "model", "user"}
+	if len(gotReq.Contents) != len(wantRoles) {
+		t.Fatalf("contents len = %d, want %d", len(gotReq.Contents), len(wantRoles))
+	}
+	for i, role := range wantRoles {
+		if gotReq.Contents[i].Role != role {
+			t.Errorf("contents[%d].role = %q, want %q", i, gotReq.Contents[i].Role, role)
+		}
+	}
+	if gotReq.GenerationConfig.ResponseMIMEType != "application/json" {
+		t.Errorf("responseMimeType = %q, want %q", gotReq.GenerationConfig.ResponseMIMEType, "application/json")
+	}
+
+	want := `{"text":"ok","commands":[]}`
+	if got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+	if !got.Structured {
+		t.Error("Structured = false, want true")
+	}
+	if got.FinishReason != "STOP" {
+		t.Errorf("FinishReason = %q, want %q", got.FinishReason, "STOP")
+	}
+	wantUsage := Usage{InputTokens: 12, OutputTokens: 5, TotalTokens: 17}
+	if got.Usage != wantUsage {
+		t.Errorf("Usage = %+v, want %+v", got.Usage, wantUsage)
+	}
+}
+
+func TestGeminiChatWithoutJSONMode(t *testing.T) {
+	var gotReq map[string]json.RawMessage
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&gotReq); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		resp := map[string]any{
+			"candidates": []map[string]any{
+				{"content": map[string]any{"parts": []map[string]string{{"text": "ls -la"}}}},
+			},
+			"usageMetadata": map[string]int{
+				"promptTokenCount":     3,
+				"candidatesTokenCount": 2,
+			},
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	p := newTestGemini(t, srv.URL, "gemini-2.5-flash")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := p.Chat(ctx, ChatRequest{
+		Messages: []Message{{Role: "user", Content: "hello"}},
+	})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if _, ok := gotReq["generationConfig"]; ok {
+		t.Error("generationConfig should be omitted without ExpectJSON")
+	}
+	if _, ok := gotReq["systemInstruction"]; ok {
+		t.Error("systemInstruction should be omitted when there is no system message")
+	}
+	if got.Text != "ls -la" {
+		t.Errorf("Text = %q, want %q", got.Text, "ls -la")
+	}
+	if got.Structured {
+		t.Error("Structured = true, want false")
+	}
+	if got.Usage.TotalTokens != 5 {
+		t.Errorf("Usage.TotalTokens = %d, want 5 (derived from input + output)", got.Usage.TotalTokens)
+	}
+}
+
+func TestGeminiChatErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":{"status":"INTERNAL"}}`, http.StatusInternalServerError)
+			},
+			wantErr: "gemini chat failed",
+		},
+		{
+			name: "invalid JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("{not-json"))
+			},
+			wantErr: "decoding gemini chat response",
+		},
+		{
+			name: "blocked prompt",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"promptFeedback":{"blockReason":"SAFETY"}}`))
+			},
+			wantErr: "gemini blocked the prompt: SAFETY",
+		},
+		{
+			name: "no candidates",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"candidates":[]}`))
+			},
+			wantErr: "no candidates",
+		},
+		{
+			name: "empty content",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[]},"finishReason":"SAFETY"}]}`))
+			},
+			wantErr: "empty response from model",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			p := newTestGemini(t, srv.URL, "gemini-2.5-flash")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := p.Chat(ctx, ChatRequest{
+				Messages: []Message{{Role: "user", Content: "hello"}},
+			})
+			if err == nil {
+				t.Fatalf("Chat() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Chat() error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGeminiHTTPErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		wantKind error
+	}{
+		{"unauthorized", http.StatusUnauthorized, ErrAuth},
+		{"model not found", http.StatusNotFound, ErrModelNotFound},
+		{"rate limited", http.StatusTooManyRequests, ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, `{"error":{"status":"SOME_ERROR"}}`, tt.status)
+			}))
+			defer srv.Close()
+
+			p := newTestGemini(t, srv.URL, "gemini-2.5-flash")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, chatErr := p.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hello"}}})
+			for op, err := range map[string]error{"Chat": chatErr, "Available": p.Available(ctx)} {
+				if !errors.Is(err, tt.wantKind) {
+					t.Errorf("%s() error = %v, want %v", op, err, tt.wantKind)
+				}
+				var httpErr *HTTPError
+				if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+					t.Errorf("%s() HTTPError = %+v, want status %d", op, httpErr, tt.status)
+				}
+			}
+		})
+	}
+}
+
+func TestGeminiChatRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		status    int
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "unavailable then success", failures: 1, status: http.StatusServiceUnavailable, wantCalls: 2},
+		{name: "rate limited then success", failures: 2, status: http.StatusTooManyRequests, wantCalls: 3},
+		{name: "rate limit exhausted", failures: 5, status: http.StatusTooManyRequests, wantCalls: 3, wantErr: ErrRateLimited},
+		{name: "auth not retried", failures: 5, status: http.StatusForbidden, wantCalls: 1, wantErr: ErrAuth},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= tt.failures {
+					http.Error(w, `{"error":{"status":"UNAVAILABLE"}}`, tt.status)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"candidates": []map[string]any{{"content": map[string]any{"parts": []map[string]string{{"text": "ok"}}}}},
+				})
+			}))
+			defer srv.Close()
+
+			p := newTestGemini(t, srv.URL, "gemini-2.5-flash")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			got, err := p.Chat(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hello"}}})
+			if calls != tt.wantCalls {
+				t.Errorf("server calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Text != "ok" {
+					t.Errorf("Text = %q, want %q", got.Text, "ok")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
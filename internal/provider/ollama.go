@@ -3,11 +3,11 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/ollama/ollama/api"
 )
@@ -15,7 +15,10 @@ import (
 // OllamaProvider implements Provider using a local Ollama instance.
 type OllamaProvider struct {
 	client *api.Client
-	model  string
+	// streamClient has no overall timeout, so long replies can stream.
+	streamClient *api.Client
+	model        string
+	retry        RetryPolicy
 }
 
 // NewOllama creates an OllamaProvider connected to the given host and model.
@@ -24,9 +27,12 @@ func NewOllama(host, model string) (*OllamaProvider, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parsing ollama host URL: %w", err)
 	}
-	httpClient := &http.Client{Timeout: 30 * time.Second}
-	client := api.NewClient(base, httpClient)
-	return &OllamaProvider{client: client, model: model}, nil
+	return &OllamaProvider{
+		client:       api.NewClient(base, &http.Client{Timeout: requestTimeout}),
+		streamClient: api.NewClient(base, newStreamClient()),
+		model:        model,
+		retry:        DefaultRetryPolicy,
+	}, nil
 }
 
 func (o *OllamaProvider) Name() string { return "ollama" }
@@ -36,6 +42,7 @@ func (o *OllamaProvider) Capabilities() Capabilities {
 		JSONMode:     true,
 		Usage:        true,
 		FinishReason: true,
+		Streaming:    true,
 	}
 }
 
@@ -58,6 +65,18 @@ func (o *OllamaProvider) Available(ctx context.Context) error {
 // Converts provider.Message to api.Message internally so callers stay decoupled
 // from the Ollama client library.
 func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	return o.chat(ctx, req, nil)
+}
+
+// ChatStream sends the conversation to Ollama with streaming enabled, passing
+// each NDJSON chunk's content to fn as it arrives.
+func (o *OllamaProvider) ChatStream(ctx context.Context, req ChatRequest, fn StreamFunc) (ChatResponse, error) {
+	return o.chat(ctx, req, fn)
+}
+
+// chat performs a blocking request when fn is nil and a streaming one otherwise.
+// Either way the final chunk carries done_reason and token metrics.
+func (o *OllamaProvider) chat(ctx context.Context, req ChatRequest, fn StreamFunc) (ChatResponse, error) {
 	apiMessages := make([]api.Message, len(req.Messages))
 	for i, m := range req.Messages {
 		apiMessages[i] = api.Message{Role: m.Role, Content: m.Content}
@@ -65,7 +84,11 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (ChatRespons
 
 	model := resolveModel(req.Model, o.model)
 
-	stream := false
+	stream := fn != nil
+	client := o.client
+	if stream {
+		client = o.streamClient
+	}
 	ollamaReq := &api.ChatRequest{
 		Model:    model,
 		Messages: apiMessages,
@@ -75,16 +98,30 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (ChatRespons
 		ollamaReq.Format = json.RawMessage(`"json"`)
 	}
 
+	// Ollama reports HTTP errors on the first line, before any content, so a
+	// retried attempt never repeats streamed output.
+	var content strings.Builder
 	var finalResp api.ChatResponse
-	err := o.client.Chat(ctx, ollamaReq, func(resp api.ChatResponse) error {
-		finalResp = resp
+	err := o.retry.do(ctx, func() error {
+		content.Reset()
+		err := client.Chat(ctx, ollamaReq, func(resp api.ChatResponse) error {
+			content.WriteString(resp.Message.Content)
+			if fn != nil && resp.Message.Content != "" {
+				fn(resp.Message.Content)
+			}
+			finalResp = resp
+			return nil
+		})
+		if err != nil {
+			return ollamaError(err)
+		}
 		return nil
 	})
 	if err != nil {
-		return ChatResponse{}, fmt.Errorf("ollama chat: %w", err)
+		return ChatResponse{}, err
 	}
 
-	result := finalResp.Message.Content
+	result := content.String()
 	if strings.TrimSpace(result) == "" {
 		return ChatResponse{}, fmt.Errorf("empty response from model")
 	}
@@ -103,3 +140,28 @@ func (o *OllamaProvider) Chat(ctx context.Context, req ChatRequest) (ChatRespons
 		Usage:        usage,
 	}, nil
 }
+
+// ollamaError converts the client library's status errors into *HTTPError so
+// callers can classify them; other errors (transport, mid-stream) keep their
+// original chain.
+func ollamaError(err error) error {
+	var statusErr api.StatusError
+	if errors.As(err, &statusErr) {
+		return &HTTPError{
+			Op:         "ollama chat",
+			StatusCode: statusErr.StatusCode,
+			Body:       statusErr.Error(),
+			Kind:       classifyStatus(statusErr.StatusCode, statusErr.ErrorMessage),
+		}
+	}
+	var authErr api.AuthorizationError
+	if errors.As(err, &authErr) {
+		return &HTTPError{
+			Op:         "ollama chat",
+			StatusCode: authErr.StatusCode,
+			Body:       authErr.Error(),
+			Kind:       ErrAuth,
+		}
+	}
+	return fmt.Errorf("ollama chat: %w", err)
+}
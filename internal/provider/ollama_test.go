@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -58,6 +59,7 @@ func TestOllamaNameAndCapabilities(t *testing.T) {
 		JSONMode:     true,
 		Usage:        true,
 		FinishReason: true,
+		Streaming:    true,
 	}
 	if gotCaps != wantCaps {
 		t.Errorf("Capabilities() = %+v, want %+v", gotCaps, wantCaps)
@@ -345,3 +347,157 @@ func TestOllamaChatDisablesFormatWhenNotExpected(t *testing.T) {
 		t.Errorf("request format = %q, want empty", string(receivedReq.Format))
 	}
 }
+
+func TestOllamaChatStream(t *testing.T) {
+	var receivedReq api.ChatRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&receivedReq); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, part := range []string{`{"text":"hel`, `lo","commands":`, `[]}`} {
+			_ = enc.Encode(api.ChatResponse{Message: api.Message{Role: "assistant", Content: part}})
+		}
+		_ = enc.Encode(api.ChatResponse{
+			Done:       true,
+			DoneReason: "stop",
+			Metrics:    api.Metrics{PromptEvalCount: 8, EvalCount: 3},
+		})
+	}))
+	defer srv.Close()
+
+	p := newTestOllama(t, srv.URL, "test-model")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var deltas []string
+	got, err := p.ChatStream(ctx, ChatRequest{
+		Messages:   []Message{{Role: "user", Content: "hi"}},
+		ExpectJSON: true,
+	}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() unexpected error: %v", err)
+	}
+
+	if receivedReq.Stream == nil || !*receivedReq.Stream {
+		t.Error("request stream flag should be true")
+	}
+	if len(deltas) != 3 {
+		t.Errorf("received %d deltas, want 3: %q", len(deltas), deltas)
+	}
+	want := `{"text":"hello","commands":[]}`
+	if got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+	if !got.Structured {
+		t.Error("Structured = false, want true")
+	}
+	if got.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want %q", got.FinishReason, "stop")
+	}
+	wantUsage := Usage{InputTokens: 8, OutputTokens: 3, TotalTokens: 11}
+	if got.Usage != wantUsage {
+		t.Errorf("Usage = %+v, want %+v", got.Usage, wantUsage)
+	}
+}
+
+func TestOllamaChatRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		status    int
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "transient then success", failures: 1, status: http.StatusServiceUnavailable, wantCalls: 2},
+		{name: "transient exhausted", failures: 5, status: http.StatusInternalServerError, wantCalls: 3, wantErr: ErrTransient},
+		{name: "model not found not retried", failures: 5, status: http.StatusNotFound, wantCalls: 1, wantErr: ErrModelNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= tt.failures {
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(`{"error":"try again"}`))
+					return
+				}
+				_ = json.NewEncoder(w).Encode(api.ChatResponse{
+					Message: api.Message{Role: "assistant", Content: "ok"},
+					Done:    true,
+				})
+			}))
+			defer srv.Close()
+
+			p := newTestOllama(t, srv.URL, "llama3.2:latest")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var deltas strings.Builder
+			got, err := p.ChatStream(ctx, ChatRequest{
+				Messages: []Message{{Role: "user", Content: "hello"}},
+			}, func(d string) { deltas.WriteString(d) })
+
+			if calls != tt.wantCalls {
+				t.Errorf("server calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Text != "ok" || deltas.String() != "ok" {
+					t.Errorf("Text = %q, deltas = %q, want %q once", got.Text, deltas.String(), "ok")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+				t.Errorf("HTTPError = %+v, want status %d", httpErr, tt.status)
+			}
+			if !strings.Contains(err.Error(), "ollama chat failed") {
+				t.Errorf("error = %q, want ollama chat prefix", err.Error())
+			}
+		})
+	}
+}
+
+func TestOllamaChatStreamOutlivesRequestTimeout(t *testing.T) {
+	orig := requestTimeout
+	requestTimeout = 100 * time.Millisecond
+	defer func() { requestTimeout = orig }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		_ = enc.Encode(api.ChatResponse{Message: api.Message{Role: "assistant", Content: "slow "}})
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		_ = enc.Encode(api.ChatResponse{Message: api.Message{Role: "assistant", Content: "reply"}, Done: true})
+	}))
+	defer srv.Close()
+
+	p := newTestOllama(t, srv.URL, "test-model")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The client library ends a timed-out stream quietly, so a cut-off
+	// reply shows up as a short one.
+	got, err := p.ChatStream(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}}, func(string) {})
+	if err != nil {
+		t.Fatalf("ChatStream() error: %v", err)
+	}
+	if got.Text != "slow reply" {
+		t.Errorf("Text = %q, want the whole reply", got.Text)
+	}
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -9,17 +10,22 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 )
 
-const openAIErrorBodyLimit = 512
+const (
+	openAIErrorBodyLimit  = 512
+	openAIStreamLineLimit = 1 << 20 // 1 MiB per SSE data line
+)
 
 // OpenAIProvider implements Provider using the OpenAI Chat Completions API.
 type OpenAIProvider struct {
 	client *http.Client
-	host   string
-	model  string
-	apiKey string
+	// streamClient has no overall timeout, so long replies can stream.
+	streamClient *http.Client
+	host         string
+	model        string
+	apiKey       string
+	retry        RetryPolicy
 }
 
 // NewOpenAI creates an OpenAIProvider connected to the given host and model.
@@ -39,10 +45,12 @@ func NewOpenAI(host, model, apiKey string) (*OpenAIProvider, error) {
 	}
 
 	return &OpenAIProvider{
-		client: &http.Client{Timeout: 30 * time.Second},
-		host:   strings.TrimRight(base, "/"),
-		model:  model,
-		apiKey: apiKey,
+		client:       &http.Client{Timeout: requestTimeout},
+		streamClient: newStreamClient(),
+		host:         strings.TrimRight(base, "/"),
+		model:        model,
+		apiKey:       apiKey,
+		retry:        DefaultRetryPolicy,
 	}, nil
 }
 
@@ -53,6 +61,7 @@ func (o *OpenAIProvider) Capabilities() Capabilities {
 		JSONMode:     true,
 		Usage:        true,
 		FinishReason: true,
+		Streaming:    true,
 	}
 }
 
@@ -71,7 +80,7 @@ func (o *OpenAIProvider) Available(ctx context.Context) error {
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode >= http.StatusBadRequest {
-		return fmt.Errorf("openai availability check failed: %s", readErrorBody(resp.Body))
+		return newHTTPError("openai availability check", resp)
 	}
 
 	var decoded struct {
@@ -94,6 +103,149 @@ func (o *OpenAIProvider) Available(ctx context.Context) error {
 // Chat sends the conversation to OpenAI and returns the assistant response.
 // Request JSON-mode is enforced with response_format.type=json_object.
 func (o *OpenAIProvider) Chat(ctx context.Context, chatReq ChatRequest) (ChatResponse, error) {
+	resp, err := o.send(ctx, chatReq, false)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var decoded struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage openAIUsage `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return ChatResponse{}, fmt.Errorf("decoding openai chat response: %w", err)
+	}
+	if len(decoded.Choices) == 0 {
+		return ChatResponse{}, fmt.Errorf("empty response from model")
+	}
+
+	result := strings.TrimSpace(decoded.Choices[0].Message.Content)
+	if result == "" {
+		return ChatResponse{}, fmt.Errorf("empty response from model")
+	}
+
+	return ChatResponse{
+		Text:         result,
+		Raw:          result,
+		Structured:   isStructuredJSON(chatReq.ExpectJSON, result),
+		FinishReason: decoded.Choices[0].FinishReason,
+		Usage:        decoded.Usage.normalize(),
+	}, nil
+}
+
+// ChatStream sends the conversation with stream=true and consumes the
+// server-sent events, passing each choice delta's content to fn. Usage arrives
+// in a trailing chunk because stream_options.include_usage is requested.
+func (o *OpenAIProvider) ChatStream(ctx context.Context, chatReq ChatRequest, fn StreamFunc) (ChatResponse, error) {
+	resp, err := o.send(ctx, chatReq, true)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var (
+		content      strings.Builder
+		finishReason string
+		usage        openAIUsage
+	)
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), openAIStreamLineLimit)
+	for scanner.Scan() {
+		line := scanner.Text()
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			// Blank separators, comments (": ping") and event names carry no payload.
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage *openAIUsage `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return ChatResponse{}, fmt.Errorf("decoding openai stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		if delta := chunk.Choices[0].Delta.Content; delta != "" {
+			content.WriteString(delta)
+			fn(delta)
+		}
+		if fr := chunk.Choices[0].FinishReason; fr != nil {
+			finishReason = *fr
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return ChatResponse{}, fmt.Errorf("reading openai stream: %w", err)
+	}
+
+	result := strings.TrimSpace(content.String())
+	if result == "" {
+		return ChatResponse{}, fmt.Errorf("empty response from model")
+	}
+
+	return ChatResponse{
+		Text:         result,
+		Raw:          result,
+		Structured:   isStructuredJSON(chatReq.ExpectJSON, result),
+		FinishReason: finishReason,
+		Usage:        usage.normalize(),
+	}, nil
+}
+
+// send posts the chat request and returns the successful response, retrying
+// rate limits and transient server errors per o.retry. Failures before any
+// body is read are the only ones retried, so streamed output never repeats.
+// The caller must close the response body.
+func (o *OpenAIProvider) send(ctx context.Context, chatReq ChatRequest, stream bool) (*http.Response, error) {
+	var resp *http.Response
+	err := o.retry.do(ctx, func() error {
+		req, err := o.newChatRequest(ctx, chatReq, stream)
+		if err != nil {
+			return err
+		}
+
+		client := o.client
+		if stream {
+			client = o.streamClient
+		}
+		r, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("openai chat: %w", err)
+		}
+		if r.StatusCode >= http.StatusBadRequest {
+			defer func() { _ = r.Body.Close() }()
+			return newHTTPError("openai chat", r)
+		}
+		resp = r
+		return nil
+	})
+	return resp, err
+}
+
+// newChatRequest builds the POST /chat/completions request shared by Chat and
+// ChatStream.
+func (o *OpenAIProvider) newChatRequest(ctx context.Context, chatReq ChatRequest, stream bool) (*http.Request, error) {
 	type openAIMessage struct {
 		Role    string `json:"role"`
 		Content string `json:"content"`
@@ -105,11 +257,15 @@ func (o *OpenAIProvider) Chat(ctx context.Context, chatReq ChatRequest) (ChatRes
 		ResponseFormat *struct {
 			Type string `json:"type"`
 		} `json:"response_format,omitempty"`
+		Stream        bool `json:"stream,omitempty"`
+		StreamOptions *struct {
+			IncludeUsage bool `json:"include_usage"`
+		} `json:"stream_options,omitempty"`
 	}
 
 	apiMessages := make([]openAIMessage, len(chatReq.Messages))
 	for i, m := range chatReq.Messages {
-		apiMessages[i] = openAIMessage(m)
+		apiMessages[i] = openAIMessage{Role: m.Role, Content: m.Content}
 	}
 
 	model := resolveModel(chatReq.Model, o.model)
@@ -124,10 +280,18 @@ func (o *OpenAIProvider) Chat(ctx context.Context, chatReq ChatRequest) (ChatRes
 			Type: "json_object",
 		}
 	}
+	if stream {
+		reqBody.Stream = true
+		reqBody.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{
+			IncludeUsage: true,
+		}
+	}
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return ChatResponse{}, fmt.Errorf("encoding openai chat request: %w", err)
+		return nil, fmt.Errorf("encoding openai chat request: %w", err)
 	}
 
 	req, err := http.NewRequestWithContext(
@@ -137,62 +301,33 @@ func (o *OpenAIProvider) Chat(ctx context.Context, chatReq ChatRequest) (ChatRes
 		bytes.NewReader(body),
 	)
 	if err != nil {
-		return ChatResponse{}, fmt.Errorf("building openai chat request: %w", err)
+		return nil, fmt.Errorf("building openai chat request: %w", err)
 	}
 	req.Header.Set("Authorization", "Bearer "+o.apiKey)
 	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := o.client.Do(req)
-	if err != nil {
-		return ChatResponse{}, fmt.Errorf("openai chat: %w", err)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode >= http.StatusBadRequest {
-		return ChatResponse{}, fmt.Errorf("openai chat failed: %s", readErrorBody(resp.Body))
-	}
-
-	var decoded struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-			FinishReason string `json:"finish_reason"`
-		} `json:"choices"`
-		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		} `json:"usage"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
-		return ChatResponse{}, fmt.Errorf("decoding openai chat response: %w", err)
-	}
-	if len(decoded.Choices) == 0 {
-		return ChatResponse{}, fmt.Errorf("empty response from model")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
 	}
+	return req, nil
+}
 
-	result := strings.TrimSpace(decoded.Choices[0].Message.Content)
-	if result == "" {
-		return ChatResponse{}, fmt.Errorf("empty response from model")
-	}
+// openAIUsage is the token accounting block shared by full and streamed responses.
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
 
+func (u openAIUsage) normalize() Usage {
 	usage := Usage{
-		InputTokens:  decoded.Usage.PromptTokens,
-		OutputTokens: decoded.Usage.CompletionTokens,
-		TotalTokens:  decoded.Usage.TotalTokens,
+		InputTokens:  u.PromptTokens,
+		OutputTokens: u.CompletionTokens,
+		TotalTokens:  u.TotalTokens,
 	}
 	if usage.TotalTokens == 0 {
 		usage.TotalTokens = usage.InputTokens + usage.OutputTokens
 	}
-
-	return ChatResponse{
-		Text:         result,
-		Raw:          result,
-		Structured:   isStructuredJSON(chatReq.ExpectJSON, result),
-		FinishReason: decoded.Choices[0].FinishReason,
-		Usage:        usage,
-	}, nil
+	return usage
 }
 
 func readErrorBody(r io.Reader) string {
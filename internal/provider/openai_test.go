@@ -3,6 +3,7 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -68,6 +69,7 @@ func TestOpenAINameAndCapabilities(t *testing.T) {
 		JSONMode:     true,
 		Usage:        true,
 		FinishReason: true,
+		Streaming:    true,
 	}
 	if gotCaps != wantCaps {
 		t.Errorf("Capabilities() = %+v, want %+v", gotCaps, wantCaps)
@@ -345,3 +347,224 @@ func TestOpenAIChatErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestOpenAIChatStream(t *testing.T) {
+	var gotStream bool
+	var gotIncludeUsage bool
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream        bool `json:"stream"`
+			StreamOptions struct {
+				IncludeUsage bool `json:"include_usage"`
+			} `json:"stream_options"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		gotStream = req.Stream
+		gotIncludeUsage = req.StreamOptions.IncludeUsage
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			": keep-alive",
+			`data: {"choices":[{"delta":{"role":"assistant"},"finish_reason":null}]}`,
+			`data: {"choices":[{"delta":{"content":"{\"text\":\"o"},"finish_reason":null}]}`,
+			`data: {"choices":[{"delta":{"content":"k\",\"commands\":[]}"},"finish_reason":null}]}`,
+			`data: {"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+			`data: {"choices":[],"usage":{"prompt_tokens":9,"completion_tokens":4,"total_tokens":13}}`,
+			`data: [DONE]`,
+		}
+		for _, e := range events {
+			_, _ = w.Write([]byte(e + "\n\n"))
+		}
+	}))
+	defer srv.Close()
+
+	p := newTestOpenAI(t, srv.URL, "gpt-4o-mini")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var deltas strings.Builder
+	got, err := p.ChatStream(ctx, ChatRequest{
+		Messages:   []Message{{Role: "user", Content: "hello"}},
+		ExpectJSON: true,
+	}, func(delta string) {
+		deltas.WriteString(delta)
+	})
+	if err != nil {
+		t.Fatalf("ChatStream() unexpected error: %v", err)
+	}
+
+	if !gotStream || !gotIncludeUsage {
+		t.Errorf("stream = %v, include_usage = %v, want both true", gotStream, gotIncludeUsage)
+	}
+	want := `{"text":"ok","commands":[]}`
+	if deltas.String() != want {
+		t.Errorf("concatenated deltas = %q, want %q", deltas.String(), want)
+	}
+	if got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+	if !got.Structured {
+		t.Error("Structured = false, want true")
+	}
+	if got.FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want %q", got.FinishReason, "stop")
+	}
+	wantUsage := Usage{InputTokens: 9, OutputTokens: 4, TotalTokens: 13}
+	if got.Usage != wantUsage {
+		t.Errorf("Usage = %+v, want %+v", got.Usage, wantUsage)
+	}
+}
+
+func TestOpenAIChatStreamErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		wantErr string
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			},
+			wantErr: "openai chat failed",
+		},
+		{
+			name: "malformed chunk",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("data: {not-json\n\n"))
+			},
+			wantErr: "decoding openai stream chunk",
+		},
+		{
+			name: "no content",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			},
+			wantErr: "empty response from model",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+
+			p := newTestOpenAI(t, srv.URL, "gpt-4o-mini")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_, err := p.ChatStream(ctx, ChatRequest{
+				Messages: []Message{{Role: "user", Content: "hello"}},
+			}, func(string) {})
+			if err == nil {
+				t.Fatalf("ChatStream() expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ChatStream() error = %q, want substring %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenAIChatRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		stream    bool
+		failures  int
+		status    int
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "transient then success", failures: 1, status: http.StatusServiceUnavailable, wantCalls: 2},
+		{name: "rate limited then success", failures: 2, status: http.StatusTooManyRequests, wantCalls: 3},
+		{name: "stream transient then success", stream: true, failures: 1, status: http.StatusBadGateway, wantCalls: 2},
+		{name: "rate limit exhausted", failures: 5, status: http.StatusTooManyRequests, wantCalls: 3, wantErr: ErrRateLimited},
+		{name: "auth not retried", failures: 5, status: http.StatusUnauthorized, wantCalls: 1, wantErr: ErrAuth},
+		{name: "context length not retried", failures: 5, status: http.StatusBadRequest, wantCalls: 1, wantErr: ErrContextLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls <= tt.failures {
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, `{"error":{"code":"context_length_exceeded"}}`, tt.status)
+					return
+				}
+				if tt.stream {
+					_, _ = w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n"))
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{
+					"choices": []map[string]any{{"message": map[string]string{"content": "ok"}}},
+				})
+			}))
+			defer srv.Close()
+
+			p := newTestOpenAI(t, srv.URL, "gpt-4o-mini")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			req := ChatRequest{Messages: []Message{{Role: "user", Content: "hello"}}}
+			var got ChatResponse
+			var err error
+			if tt.stream {
+				got, err = p.ChatStream(ctx, req, func(string) {})
+			} else {
+				got, err = p.Chat(ctx, req)
+			}
+
+			if calls != tt.wantCalls {
+				t.Errorf("server calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if got.Text != "ok" {
+					t.Errorf("Text = %q, want %q", got.Text, "ok")
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status || httpErr.RetryAfter != time.Second {
+				t.Errorf("HTTPError = %+v, want status %d with 1s Retry-After", httpErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestOpenAIChatStreamOutlivesRequestTimeout(t *testing.T) {
+	orig := requestTimeout
+	requestTimeout = 100 * time.Millisecond
+	defer func() { requestTimeout = orig }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"choices":[{"delta":{"content":"slow "}}]}` + "\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte(`data: {"choices":[{"delta":{"content":"reply"},"finish_reason":"stop"}]}` + "\n\ndata: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	p := newTestOpenAI(t, srv.URL, "gpt-4o-mini")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got, err := p.ChatStream(ctx, ChatRequest{Messages: []Message{{Role: "user", Content: "hi"}}}, func(string) {})
+	if err != nil {
+		t.Fatalf("ChatStream() error: %v", err)
+	}
+	if got.Text != "slow reply" {
+		t.Errorf("Text = %q, want the whole reply", got.Text)
+	}
+}
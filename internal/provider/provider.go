@@ -1,12 +1,22 @@
 // Package provider defines the LLM backend interface and implementations.
 // New backends (Apple FM, Claude API) implement the Provider interface.
 //
-// Provider is deliberately thin (no streaming, no embeddings) — ShellBud only
-// needs single-turn chat. All backend-specific types stay in implementation
-// files, never leak through the interface.
+// Provider is deliberately thin (no embeddings, no tool calls) — ShellBud only
+// needs chat. Streaming is an optional extension (StreamingProvider) so
+// backends without incremental output stay simple. All backend-specific types
+// stay in implementation files, never leak through the interface.
 package provider
 
-import "context"
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// requestTimeout bounds a blocking request, and connecting for a streamed
+// one. A var so tests can shorten it.
+var requestTimeout = 30 * time.Second
 
 // Message represents a single message in a conversation.
 // Decoupled from any specific LLM API (Ollama, OpenAI, etc.) so callers
@@ -14,6 +24,9 @@ import "context"
 type Message struct {
 	Role    string // "system", "user", "assistant"
 	Content string
+	// FollowUp marks a user message ShellBud sent on the user's behalf,
+	// such as a command's result. Backends send it like any other message.
+	FollowUp bool
 }
 
 // ChatRequest represents a normalized LLM request.
@@ -53,6 +66,8 @@ type Capabilities struct {
 	JSONMode     bool
 	Usage        bool
 	FinishReason bool
+	// Streaming indicates the provider implements StreamingProvider.
+	Streaming bool
 }
 
 // Provider sends conversations to an LLM backend.
@@ -69,3 +84,40 @@ type Provider interface {
 	// Available checks if this provider is ready to use.
 	Available(ctx context.Context) error
 }
+
+// StreamFunc receives assistant content deltas as they are generated.
+// Concatenating every delta yields the final ChatResponse.Text (modulo
+// surrounding whitespace).
+type StreamFunc func(delta string)
+
+// StreamingProvider is implemented by backends that can deliver the response
+// incrementally. Callers should go through StreamChat rather than asserting
+// this interface directly.
+type StreamingProvider interface {
+	Provider
+
+	// ChatStream behaves like Chat but invokes fn with each content delta as
+	// it arrives. The returned response is the complete, aggregated result.
+	ChatStream(ctx context.Context, req ChatRequest, fn StreamFunc) (ChatResponse, error)
+}
+
+// StreamChat sends req to p, streaming content deltas to fn when the provider
+// advertises Capabilities.Streaming and falling back to a blocking Chat call
+// otherwise. Deltas are for display only: callers must still parse the
+// returned response before acting on it.
+func StreamChat(ctx context.Context, p Provider, req ChatRequest, fn StreamFunc) (ChatResponse, error) {
+	if sp, ok := p.(StreamingProvider); ok && fn != nil && p.Capabilities().Streaming {
+		return sp.ChatStream(ctx, req, fn)
+	}
+	return p.Chat(ctx, req)
+}
+
+// newStreamClient returns an HTTP client for streamed responses. A client
+// Timeout also covers reading the body and would cut a slow model off
+// mid-reply, so only connecting is bounded; the caller's context bounds the
+// rest.
+func newStreamClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: requestTimeout, KeepAlive: 30 * time.Second}).DialContext
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// fakeProvider records which entry point StreamChat used.
+type fakeProvider struct {
+	streaming  bool
+	chatCalls  int
+	streamUsed bool
+}
+
+func (f *fakeProvider) Chat(_ context.Context, _ ChatRequest) (ChatResponse, error) {
+	f.chatCalls++
+	return ChatResponse{Text: "blocking"}, nil
+}
+
+func (f *fakeProvider) ChatStream(_ context.Context, _ ChatRequest, fn StreamFunc) (ChatResponse, error) {
+	f.streamUsed = true
+	fn("stre")
+	fn("amed")
+	return ChatResponse{Text: "streamed"}, nil
+}
+
+func (f *fakeProvider) Name() string { return "fake" }
+func (f *fakeProvider) Capabilities() Capabilities {
+	return Capabilities{Streaming: f.streaming}
+}
+func (f *fakeProvider) Available(_ context.Context) error { return nil }
+
+// blockingProvider does not implement StreamingProvider at all.
+type blockingProvider struct{}
+
+func (blockingProvider) Chat(_ context.Context, _ ChatRequest) (ChatResponse, error) {
+	return ChatResponse{Text: "blocking"}, nil
+}
+func (blockingProvider) Name() string                      { return "blocking" }
+func (blockingProvider) Capabilities() Capabilities        { return Capabilities{Streaming: true} }
+func (blockingProvider) Available(_ context.Context) error { return nil }
+
+func TestStreamChat(t *testing.T) {
+	tests := []struct {
+		name       string
+		p          Provider
+		nilFn      bool
+		wantText   string
+		wantDeltas string
+	}{
+		{name: "streaming provider", p: &fakeProvider{streaming: true}, wantText: "streamed", wantDeltas: "streamed"},
+		{name: "capability disabled", p: &fakeProvider{streaming: false}, wantText: "blocking"},
+		{name: "nil callback", p: &fakeProvider{streaming: true}, nilFn: true, wantText: "blocking"},
+		{name: "interface not implemented", p: blockingProvider{}, wantText: "blocking"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deltas strings.Builder
+			fn := func(delta string) { deltas.WriteString(delta) }
+			if tt.nilFn {
+				fn = nil
+			}
+
+			got, err := StreamChat(context.Background(), tt.p, ChatRequest{}, fn)
+			if err != nil {
+				t.Fatalf("StreamChat() unexpected error: %v", err)
+			}
+			if got.Text != tt.wantText {
+				t.Errorf("Text = %q, want %q", got.Text, tt.wantText)
+			}
+			if deltas.String() != tt.wantDeltas {
+				t.Errorf("deltas = %q, want %q", deltas.String(), tt.wantDeltas)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how HTTP providers retry rate-limited and transient
+// failures. Delays use full jitter: each wait is uniform in [0, backoff),
+// where backoff doubles from BaseDelay up to MaxDelay.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy keeps the worst case well inside the chat timeout.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    8 * time.Second,
+}
+
+// sleep waits for d or until ctx is done. Tests override it.
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// do runs fn until it succeeds, fails with a non-retryable error, or the
+// attempts run out. Only ErrRateLimited and ErrTransient are retried; a
+// Retry-After hint longer than MaxDelay is treated as final rather than
+// stalling the user.
+func (r RetryPolicy) do(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || !retryable(err) || attempt+1 >= r.MaxAttempts {
+			return err
+		}
+
+		wait := r.backoff(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+			if httpErr.RetryAfter > r.MaxDelay {
+				return err
+			}
+			wait = httpErr.RetryAfter
+		}
+		if sleepErr := sleep(ctx, wait); sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := r.BaseDelay << attempt
+	if ceiling <= 0 || ceiling > r.MaxDelay {
+		ceiling = r.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+func retryable(err error) bool {
+	return errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTransient)
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Retry delays are asserted explicitly below; everywhere else they would
+	// only slow the suite down.
+	sleep = func(context.Context, time.Duration) error { return nil }
+	os.Exit(m.Run())
+}
+
+func recordSleeps(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	orig := sleep
+	sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() { sleep = orig })
+	return &waits
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	transient := &HTTPError{Op: "test", StatusCode: 503, Kind: ErrTransient}
+	authErr := &HTTPError{Op: "test", StatusCode: 401, Kind: ErrAuth}
+	boom := errors.New("boom")
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{name: "success first try", errs: []error{nil}, wantCalls: 1},
+		{name: "transient then success", errs: []error{transient, nil}, wantCalls: 2},
+		{name: "gives up after max attempts", errs: []error{transient, transient, transient, nil}, wantCalls: 3, wantErr: ErrTransient},
+		{name: "auth not retried", errs: []error{authErr, nil}, wantCalls: 1, wantErr: ErrAuth},
+		{name: "plain error not retried", errs: []error{boom, nil}, wantCalls: 1, wantErr: boom},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := recordSleeps(t)
+			calls := 0
+			err := policy.do(context.Background(), func() error {
+				e := tt.errs[calls]
+				calls++
+				return e
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if len(*waits) != calls-1 {
+				t.Errorf("sleeps = %d, want %d", len(*waits), calls-1)
+			}
+			for _, w := range *waits {
+				if w < 0 || w > policy.MaxDelay {
+					t.Errorf("wait %v outside [0, %v]", w, policy.MaxDelay)
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyHonorsRetryAfter(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Second}
+
+	t.Run("within max delay", func(t *testing.T) {
+		waits := recordSleeps(t)
+		calls := 0
+		_ = policy.do(context.Background(), func() error {
+			calls++
+			if calls == 1 {
+				return &HTTPError{StatusCode: 429, RetryAfter: 2 * time.Second, Kind: ErrRateLimited}
+			}
+			return nil
+		})
+		if len(*waits) != 1 || (*waits)[0] != 2*time.Second {
+			t.Errorf("waits = %v, want [2s]", *waits)
+		}
+	})
+
+	t.Run("beyond max delay gives up", func(t *testing.T) {
+		waits := recordSleeps(t)
+		calls := 0
+		err := policy.do(context.Background(), func() error {
+			calls++
+			return &HTTPError{StatusCode: 429, RetryAfter: time.Minute, Kind: ErrRateLimited}
+		})
+		if calls != 1 || len(*waits) != 0 {
+			t.Errorf("calls = %d, waits = %v, want 1 call and no wait", calls, *waits)
+		}
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("err = %v, want ErrRateLimited", err)
+		}
+	})
+}
+
+func TestRetryPolicyStopsOnCanceledContext(t *testing.T) {
+	orig := sleep
+	sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	t.Cleanup(func() { sleep = orig })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	err := DefaultRetryPolicy.do(ctx, func() error {
+		calls++
+		return &HTTPError{StatusCode: 503, Kind: ErrTransient}
+	})
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	if !errors.Is(err, ErrTransient) {
+		t.Errorf("err = %v, want the last backend error", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: 400 * time.Millisecond}
+	for attempt := 0; attempt < 8; attempt++ {
+		ceiling := min(policy.BaseDelay<<attempt, policy.MaxDelay)
+		for i := 0; i < 20; i++ {
+			if d := policy.backoff(attempt); d < 0 || d >= ceiling {
+				t.Fatalf("backoff(%d) = %v, want in [0, %v)", attempt, d, ceiling)
+			}
+		}
+	}
+	if d := (RetryPolicy{}).backoff(3); d != 0 {
+		t.Errorf("zero policy backoff = %v, want 0", d)
+	}
+}
@@ -0,0 +1,214 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/hpkotak/shellbud/internal/conversation"
+	"github.com/hpkotak/shellbud/internal/prompt"
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+// slashCommand is a chat command handled locally instead of being sent to the
+// model. run reports whether it changed the conversation, so the session is
+// saved only when needed.
+type slashCommand struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *session, args string, out io.Writer) bool
+}
+
+// slashCommands is the dispatch table, in /help order. It is filled in by
+// init because /help refers back to it.
+var slashCommands []slashCommand
+
+func init() {
+	slashCommands = []slashCommand{
+		{name: "model", usage: "/model [name]", help: "show or switch the model for the rest of the session", run: cmdModel},
+		{name: "clear", usage: "/clear", help: "forget the conversation so far", run: cmdClear},
+		{name: "context", usage: "/context", help: "show the environment context sent to the model", run: cmdContext},
+		{name: "history", usage: "/history", help: "show the conversation so far", run: cmdHistory},
+		{name: "retry", usage: "/retry", help: "regenerate the answer to your last message", run: cmdRetry},
+		{name: "undo", usage: "/undo", help: "remove your last message and everything after it", run: cmdUndo},
+		{name: "reset", usage: "/reset", help: "start commands afresh in sb's directory and environment", run: cmdReset},
+		{name: "tokens", usage: "/tokens", help: "show token usage for this session", run: cmdTokens},
+		{name: "help", usage: "/help", help: "list chat commands", run: cmdHelp},
+	}
+}
+
+// findSlashCommand returns the slash command input invokes and its
+// arguments. ok is false for anything else, such as a question that starts
+// with a path like /usr/local/bin, which goes to the model.
+func findSlashCommand(input string) (c slashCommand, args string, ok bool) {
+	if !strings.HasPrefix(input, "/") || strings.Contains(input, "\n") {
+		return slashCommand{}, "", false
+	}
+	name, args, _ := strings.Cut(strings.TrimPrefix(input, "/"), " ")
+	for _, c := range slashCommands {
+		if c.name == name {
+			return c, strings.TrimSpace(args), true
+		}
+	}
+	return slashCommand{}, "", false
+}
+
+func cmdModel(s *session, args string, out io.Writer) bool {
+	if args == "" {
+		model := s.model
+		if model == "" {
+			model = "provider default"
+		}
+		_, _ = fmt.Fprintf(out, "Model: %s\n\n", model)
+		return false
+	}
+	if strings.ContainsAny(args, " \t") {
+		_, _ = fmt.Fprintln(out, "Usage: /model <name>")
+		_, _ = fmt.Fprintln(out)
+		return false
+	}
+
+	s.model = args
+	if s.windowFor != nil {
+		// A new model has its own tokenizer and window; start calibration over.
+		s.budget = conversation.NewBudget(s.windowFor(args))
+	}
+	if s.rec != nil {
+		s.rec.Session.Model = args
+	}
+	_, _ = fmt.Fprintf(out, "Switched to model %s.\n\n", args)
+	return true
+}
+
+func cmdClear(s *session, _ string, out io.Writer) bool {
+	s.history = nil
+	s.summary = conversation.Summary{}
+	if s.rec != nil {
+		// Saved summaries describe the forgotten messages.
+		s.rec.Session.Summaries = nil
+	}
+	_, _ = fmt.Fprintln(out, "Conversation cleared.")
+	_, _ = fmt.Fprintln(out)
+	return true
+}
+
+func cmdContext(s *session, _ string, out io.Writer) bool {
+	_, _ = fmt.Fprintln(out, gatherEnv(s.shell.WorkDir()).Format())
+	return false
+}
+
+func cmdHistory(s *session, _ string, out io.Writer) bool {
+	if len(s.history) == 0 {
+		_, _ = fmt.Fprintln(out, "No conversation yet.")
+		_, _ = fmt.Fprintln(out)
+		return false
+	}
+	if s.summary.Covers > 0 {
+		_, _ = fmt.Fprintf(out, "  (%d earlier messages summarized)\n", s.summary.Covers)
+	}
+	for _, m := range s.history[s.summary.Covers:] {
+		switch {
+		case m.Role == "assistant":
+			// Display only: history is never re-run.
+			_, _ = fmt.Fprintf(out, "  sb:  %s\n", prompt.ParseChatResponse(m.Content).Text)
+		case conversation.IsFollowUp(m):
+			first, _, _ := strings.Cut(m.Content, "\n")
+			_, _ = fmt.Fprintf(out, "       %s\n", first)
+		default:
+			_, _ = fmt.Fprintf(out, "  you: %s\n", m.Content)
+		}
+	}
+	_, _ = fmt.Fprintln(out)
+	return false
+}
+
+func cmdRetry(s *session, _ string, out io.Writer) bool {
+	start, ok := s.lastTurn(out, "retry")
+	if !ok {
+		return false
+	}
+	s.history = s.history[:start+1]
+	s.respond(out)
+	return true
+}
+
+func cmdUndo(s *session, _ string, out io.Writer) bool {
+	start, ok := s.lastTurn(out, "undo")
+	if !ok {
+		return false
+	}
+	removed := len(s.history) - start
+	s.history = s.history[:start]
+	_, _ = fmt.Fprintf(out, "Removed the last turn (%d messages).\n\n", removed)
+	return true
+}
+
+func cmdReset(s *session, _ string, out io.Writer) bool {
+	if err := s.shell.Reset(); err != nil {
+		_, _ = fmt.Fprintf(out, "Could not reset the shell: %v\n\n", err)
+		return false
+	}
+	_, _ = fmt.Fprintln(out, "Shell reset. Commands run in sb's own directory and environment again.")
+	_, _ = fmt.Fprintln(out)
+	return false
+}
+
+func cmdTokens(s *session, _ string, out io.Writer) bool {
+	if s.usage == (provider.Usage{}) && !s.p.Capabilities().Usage {
+		_, _ = fmt.Fprintf(out, "Tokens: %s does not report token usage.\n", s.p.Name())
+	} else {
+		_, _ = fmt.Fprintf(out, "Tokens: %d in, %d out, %d total\n", s.usage.InputTokens, s.usage.OutputTokens, s.usage.TotalTokens)
+	}
+
+	var summaries provider.Usage
+	for _, sum := range s.summaries {
+		summaries = addUsage(summaries, sum.Usage)
+	}
+	if len(s.summaries) > 0 {
+		_, _ = fmt.Fprintf(out, "Summaries: %d generated, %d in, %d out\n", len(s.summaries), summaries.InputTokens, summaries.OutputTokens)
+	}
+
+	// Estimate what the next request would carry, without the system prompt.
+	conv := s.history[s.summary.Covers:]
+	if s.summary.Text != "" {
+		conv = append([]provider.Message{s.summary.Message()}, conv...)
+	}
+	estimate := s.budget.Estimate(conv...)
+	_, _ = fmt.Fprintf(out, "Context: ~%d of %d tokens used by the conversation\n\n", estimate, s.budget.Window())
+	return false
+}
+
+func cmdHelp(_ *session, _ string, out io.Writer) bool {
+	_, _ = fmt.Fprintln(out, "Chat commands:")
+	for _, c := range slashCommands {
+		_, _ = fmt.Fprintf(out, "  %-15s %s\n", c.usage, c.help)
+	}
+	_, _ = fmt.Fprintf(out, "  %-15s %s\n\n", "exit, quit", "end the session")
+	return false
+}
+
+// lastTurn finds where the user's last typed message starts. It reports
+// false, after telling the user why, when there is nothing to act on.
+func (s *session) lastTurn(out io.Writer, action string) (int, bool) {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		m := s.history[i]
+		if m.Role != "user" || conversation.IsFollowUp(m) {
+			continue
+		}
+		if i < s.summary.Covers {
+			break
+		}
+		return i, true
+	}
+	_, _ = fmt.Fprintf(out, "Nothing to %s.\n\n", action)
+	return 0, false
+}
+
+func addUsage(a, b provider.Usage) provider.Usage {
+	return provider.Usage{
+		InputTokens:  a.InputTokens + b.InputTokens,
+		OutputTokens: a.OutputTokens + b.OutputTokens,
+		TotalTokens:  a.TotalTokens + b.TotalTokens,
+	}
+}
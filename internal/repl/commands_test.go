@@ -0,0 +1,392 @@
+package repl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/conversation"
+	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/provider"
+	"github.com/hpkotak/shellbud/internal/sessions"
+	"github.com/hpkotak/shellbud/internal/shellenv"
+)
+
+// runChat runs a chat over input and returns its output.
+func runChat(t *testing.T, p provider.Provider, input string, opts Options) string {
+	t.Helper()
+	out := &bytes.Buffer{}
+	if err := Run(p, strings.NewReader(input), out, opts); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	return out.String()
+}
+
+func TestSlashHelp(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	mock := &mockProvider{}
+	output := runChat(t, mock, "/help\nexit\n", Options{})
+
+	for _, c := range slashCommands {
+		if !strings.Contains(output, c.usage) {
+			t.Errorf("/help missing %q:\n%s", c.usage, output)
+		}
+	}
+	if mock.callCount != 0 {
+		t.Errorf("slash commands should not reach the model, got %d calls", mock.callCount)
+	}
+}
+
+func TestSlashUnknownGoesToModel(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	mock := &mockProvider{responses: []string{`{"text":"It is not executable.","commands":[]}`}}
+	runChat(t, mock, "/usr/local/bin/foo fails, why?\nexit\n", Options{})
+	if mock.callCount != 1 {
+		t.Fatalf("question starting with a path made %d model calls, want 1", mock.callCount)
+	}
+	last := mock.messages[0][len(mock.messages[0])-1]
+	if last.Content != "/usr/local/bin/foo fails, why?" {
+		t.Errorf("sent %q, want the question unchanged", last.Content)
+	}
+}
+
+func TestSlashModel(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	mock := &mockProvider{responses: []string{
+		`{"text":"one","commands":[]}`,
+		`{"text":"two","commands":[]}`,
+	}}
+	var windowFor string
+	opts := Options{
+		Model: "llama3.2:latest",
+		ContextWindowFor: func(model string) int {
+			windowFor = model
+			return 2048
+		},
+	}
+	output := runChat(t, mock, "first\n/model\n/model too many args\n/model gpt-4o\nsecond\nexit\n", opts)
+
+	for _, want := range []string{"Model: llama3.2:latest", "Usage: /model <name>", "Switched to model gpt-4o."} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+	if len(mock.models) != 2 || mock.models[0] != "llama3.2:latest" || mock.models[1] != "gpt-4o" {
+		t.Errorf("request models = %v, want [llama3.2:latest gpt-4o]", mock.models)
+	}
+	if windowFor != "gpt-4o" {
+		t.Errorf("context window looked up for %q, want gpt-4o", windowFor)
+	}
+	if len(mock.messages[1]) != 4 {
+		t.Errorf("history should carry across a model switch, got %d messages", len(mock.messages[1]))
+	}
+}
+
+func TestSlashModelProviderDefault(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	output := runChat(t, &mockProvider{}, "/model\nexit\n", Options{})
+	if !strings.Contains(output, "Model: provider default") {
+		t.Errorf("expected provider default model, got:\n%s", output)
+	}
+}
+
+func TestSlashClear(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	mock := &mockProvider{responses: []string{
+		`{"text":"one","commands":[]}`,
+		`{"text":"two","commands":[]}`,
+	}}
+	output := runChat(t, mock, "first\n/clear\nsecond\nexit\n", Options{})
+
+	if !strings.Contains(output, "Conversation cleared.") {
+		t.Errorf("expected clear confirmation, got:\n%s", output)
+	}
+	if got := mock.messages[1]; len(got) != 2 || got[1].Content != "second" {
+		t.Errorf("request after /clear = %+v, want system + new question only", got)
+	}
+}
+
+func TestSlashContext(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	output := runChat(t, &mockProvider{}, "/context\nexit\n", Options{})
+	for _, want := range []string{"OS: darwin (arm64)", "Shell: /bin/zsh", "Working directory: /tmp/test"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("/context missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestSlashHistory(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+	runCapture = func(_ executor.Runner, _ string) (string, int, error) {
+		return "a.txt\nb.txt", 0, nil
+	}
+
+	mock := &mockProvider{responses: []string{`{"text":"Listing.","commands":["ls"]}`}}
+	output := runChat(t, mock, "/history\nlist files\nr\n/history\nexit\n", Options{})
+
+	if !strings.Contains(output, "No conversation yet.") {
+		t.Errorf("expected empty history message, got:\n%s", output)
+	}
+	for _, want := range []string{"  you: list files", "  sb:  Listing.", "       I ran `ls` — exit code 0."} {
+		if !strings.Contains(output, want) {
+			t.Errorf("/history missing %q:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "b.txt\n  ") {
+		t.Errorf("/history should show only the first line of command results:\n%s", output)
+	}
+}
+
+func TestSlashRetry(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+	runCapture = func(_ executor.Runner, _ string) (string, int, error) {
+		return "", 0, nil
+	}
+
+	mock := &mockProvider{responses: []string{
+		`{"text":"First try.","commands":["ls"]}`,
+		`{"text":"Second try.","commands":[]}`,
+		`{"text":"Next.","commands":[]}`,
+	}}
+	output := runChat(t, mock, "/retry\nlist files\nr\n/retry\nthen?\nexit\n", Options{})
+
+	if !strings.Contains(output, "Nothing to retry.") {
+		t.Errorf("expected nothing to retry before the first turn, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Second try.") {
+		t.Errorf("retry should show the regenerated answer, got:\n%s", output)
+	}
+	retried := mock.messages[1]
+	if len(retried) != 2 || retried[1].Content != "list files" {
+		t.Errorf("retry request = %+v, want the last question without the old answer", retried)
+	}
+	last := mock.messages[2]
+	if len(last) != 4 || !strings.Contains(last[2].Content, "Second try.") {
+		t.Errorf("history after retry = %+v, want only the regenerated answer", last)
+	}
+}
+
+func TestSlashUndo(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	mock := &mockProvider{responses: []string{
+		`{"text":"one","commands":[]}`,
+		`{"text":"two","commands":[]}`,
+		`{"text":"three","commands":[]}`,
+	}}
+	output := runChat(t, mock, "/undo\nfirst\nsecond\n/undo\nthird\nexit\n", Options{})
+
+	if !strings.Contains(output, "Nothing to undo.") {
+		t.Errorf("expected nothing to undo before the first turn, got:\n%s", output)
+	}
+	if !strings.Contains(output, "Removed the last turn (2 messages).") {
+		t.Errorf("expected undo confirmation, got:\n%s", output)
+	}
+	last := mock.messages[2]
+	if len(last) != 4 || last[1].Content != "first" || last[3].Content != "third" {
+		t.Errorf("request after /undo = %+v, want first turn then third question", last)
+	}
+}
+
+func TestLastTurnTypedLikeFollowUp(t *testing.T) {
+	s := &session{
+		history: []provider.Message{
+			{Role: "user", Content: "list files"},
+			{Role: "assistant", Content: "ls"},
+			conversation.CommandResult("ls", 0, ""),
+			{Role: "user", Content: "I ran `make` and it failed"},
+		},
+	}
+	if start, ok := s.lastTurn(&bytes.Buffer{}, "undo"); !ok || start != 3 {
+		t.Errorf("lastTurn() = %d, %v, want the typed message at 3", start, ok)
+	}
+}
+
+func TestLastTurnStopsAtSummary(t *testing.T) {
+	s := &session{
+		history: []provider.Message{
+			{Role: "user", Content: "old question"},
+			{Role: "assistant", Content: "old answer"},
+			conversation.CommandResult("ls", 0, ""),
+		},
+		summary: conversation.Summary{Text: "earlier", Covers: 2},
+	}
+	out := &bytes.Buffer{}
+	if _, ok := s.lastTurn(out, "undo"); ok {
+		t.Error("lastTurn() should not reach into summarized messages")
+	}
+	if !strings.Contains(out.String(), "Nothing to undo.") {
+		t.Errorf("output = %q, want nothing to undo", out.String())
+	}
+}
+
+// usageProvider reports fixed token usage per reply.
+type usageProvider struct {
+	mockProvider
+}
+
+func (u *usageProvider) Chat(ctx context.Context, req provider.ChatRequest) (provider.ChatResponse, error) {
+	resp, err := u.mockProvider.Chat(ctx, req)
+	resp.Usage = provider.Usage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}
+	return resp, err
+}
+
+func (u *usageProvider) Capabilities() provider.Capabilities {
+	return provider.Capabilities{JSONMode: true, Usage: true}
+}
+
+// fakeRunner is a Runner that records what happens to it.
+type fakeRunner struct {
+	dir    string
+	ran    []string
+	resets int
+	err    error
+}
+
+func (f *fakeRunner) RunCapture(command string) (string, int, error) {
+	f.ran = append(f.ran, command)
+	f.dir = "/tmp/test/sub"
+	return "", 0, nil
+}
+
+func (f *fakeRunner) WorkDir() string { return f.dir }
+
+func (f *fakeRunner) Reset() error {
+	f.resets++
+	f.dir = ""
+	return f.err
+}
+
+func (f *fakeRunner) Close() error { return nil }
+
+func TestSlashReset(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	var gathered []string
+	gatherEnv = func(dir string) shellenv.Snapshot {
+		gathered = append(gathered, dir)
+		return shellenv.Snapshot{OS: "linux", Shell: "/bin/sh", CWD: "/tmp/test"}
+	}
+
+	runner := &fakeRunner{}
+	mock := &mockProvider{responses: []string{
+		`{"text":"Going.","commands":["cd sub"]}`,
+		`{"text":"Here.","commands":[]}`,
+	}}
+	output := runChat(t, mock, "go to sub\nr\n/reset\nwhere am I?\nexit\n", Options{Runner: runner})
+
+	if len(runner.ran) != 1 || runner.ran[0] != "cd sub" {
+		t.Errorf("runner ran %q, want the approved command", runner.ran)
+	}
+	if runner.resets != 1 || !strings.Contains(output, "Shell reset.") {
+		t.Errorf("/reset called Reset() %d times, output:\n%s", runner.resets, output)
+	}
+	if len(gathered) != 2 || gathered[1] != "" {
+		t.Errorf("snapshots gathered in %q, want the second in sb's own directory", gathered)
+	}
+
+	runner = &fakeRunner{err: errors.New("boom")}
+	output = runChat(t, &mockProvider{}, "/reset\nexit\n", Options{Runner: runner})
+	if !strings.Contains(output, "Could not reset the shell: boom") {
+		t.Errorf("expected reset error, got:\n%s", output)
+	}
+}
+
+func TestSlashTokens(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	output := runChat(t, &mockProvider{}, "/tokens\nexit\n", Options{})
+	if !strings.Contains(output, "Tokens: mock does not report token usage.") {
+		t.Errorf("expected no-usage message, got:\n%s", output)
+	}
+
+	p := &usageProvider{mockProvider{responses: []string{
+		`{"text":"one","commands":[]}`,
+		`{"text":"two","commands":[]}`,
+	}}}
+	output = runChat(t, p, "first\nsecond\n/tokens\nexit\n", Options{ContextWindow: 4096})
+	for _, want := range []string{"Tokens: 20 in, 10 out, 30 total", "of 4096 tokens used by the conversation"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("/tokens missing %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestSlashTokensCountsSummaries(t *testing.T) {
+	s := &session{
+		p:       &mockProvider{},
+		budget:  conversation.NewBudget(4096),
+		history: []provider.Message{{Role: "user", Content: "old"}, {Role: "user", Content: "new"}},
+		summary: conversation.Summary{Text: "earlier", Covers: 1},
+		summaries: []conversation.Summary{
+			{Text: "a", Covers: 1, Usage: provider.Usage{InputTokens: 100, OutputTokens: 20}},
+			{Text: "earlier", Covers: 1, Usage: provider.Usage{InputTokens: 50, OutputTokens: 10}},
+		},
+	}
+	out := &bytes.Buffer{}
+	cmdTokens(s, "", out)
+	if !strings.Contains(out.String(), "Summaries: 2 generated, 150 in, 30 out") {
+		t.Errorf("/tokens should report summary usage, got:\n%s", out.String())
+	}
+	if want := s.budget.Estimate(s.summary.Message(), s.history[1]); !strings.Contains(out.String(), fmt.Sprintf("Context: ~%d ", want)) {
+		t.Errorf("/tokens context estimate should count the summary and unsummarized history, got:\n%s", out.String())
+	}
+}
+
+func TestSlashCommandsSaveSession(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	st := sessions.NewStore(t.TempDir())
+	h, err := st.Create("mock", "llama3.2:latest", "/tmp/test")
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	h.Session.Summaries = []sessions.Summary{{Text: "stale", Covers: 0}}
+
+	mock := &mockProvider{responses: []string{`{"text":"one","commands":[]}`}}
+	runChat(t, mock, "first\n/model gpt-4o\n/clear\nexit\n", Options{Session: h})
+
+	saved, err := st.Load(h.Session.ID)
+	if err != nil {
+		t.Fatalf("Load(): %v", err)
+	}
+	if saved.Model != "gpt-4o" {
+		t.Errorf("saved model = %q, want gpt-4o", saved.Model)
+	}
+	if len(saved.Messages) != 0 || len(saved.Summaries) != 0 {
+		t.Errorf("saved session after /clear has %d messages and %d summaries, want none", len(saved.Messages), len(saved.Summaries))
+	}
+}
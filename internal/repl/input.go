@@ -0,0 +1,232 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/lineedit"
+)
+
+// maxInputHistory is how many prompt lines are kept for recall.
+const maxInputHistory = 1000
+
+const (
+	// pasteStart and pasteEnd are the bracketed paste markers a terminal
+	// wraps pasted text in.
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+	// fence opens and closes a multi-line block.
+	fence = `"""`
+	// continuationPrompt is shown for each further line of a message.
+	continuationPrompt = "... "
+)
+
+// Terminal hooks, overridden in tests.
+var (
+	isTerminal    = lineedit.IsTerminal
+	newTermEditor = lineedit.NewTerminal
+)
+
+// lineReader reads one line of user input after showing prompt.
+type lineReader = executor.LineReader
+
+// scannerReader reads plain lines, for input that is not a terminal (pipes,
+// tests).
+type scannerReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *scannerReader) ReadLine(prompt string) (string, error) {
+	_, _ = fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// readMessage reads one chat message at the main prompt. Several lines form
+// one message when they are pasted (bracketed paste), when a line ends with
+// a backslash, or when they are wrapped in a """ fence. End of input inside
+// a message submits what was read so far.
+func (s *session) readMessage() (string, error) {
+	line, err := s.in.ReadLine("sb> ")
+	if err != nil {
+		return "", err
+	}
+
+	if before, pasted, ok := strings.Cut(line, pasteStart); ok {
+		return s.readPaste(before + pasted)
+	}
+	if rest, ok := strings.CutPrefix(strings.TrimSpace(line), fence); ok {
+		return s.readFenced(rest)
+	}
+
+	var lines []string
+	for {
+		body, more := strings.CutSuffix(line, `\`)
+		lines = append(lines, body)
+		if !more {
+			break
+		}
+		if line, err = s.in.ReadLine(continuationPrompt); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// readPaste reads the rest of a bracketed paste that started in text. Pasted
+// text is taken literally: backslashes and fences in it do not continue the
+// message.
+func (s *session) readPaste(text string) (string, error) {
+	for !strings.Contains(text, pasteEnd) {
+		line, err := s.in.ReadLine("")
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+		text += "\n" + line
+	}
+	return strings.Replace(text, pasteEnd, "", 1), nil
+}
+
+// readFenced reads a """ block whose opening line continued with rest, up to
+// the line that ends with the closing """.
+func (s *session) readFenced(rest string) (string, error) {
+	if body, closed := strings.CutSuffix(rest, fence); closed {
+		return body, nil
+	}
+	var lines []string
+	if rest != "" {
+		lines = append(lines, rest)
+	}
+	for {
+		line, err := s.in.ReadLine(continuationPrompt)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return "", err
+		}
+		if body, closed := strings.CutSuffix(strings.TrimRight(line, " \t"), fence); closed {
+			if body != "" {
+				lines = append(lines, body)
+			}
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// newLineReader returns a line editor with persistent history when in is a
+// terminal, and a plain scanner otherwise. The history is nil for the
+// scanner. A history file that cannot be read is reported and replaced by an
+// in-memory history. Paths complete relative to workDir, which reports the
+// directory commands run in ("" for the process's own).
+func newLineReader(in io.Reader, out io.Writer, historyPath string, workDir func() string) (lineReader, *lineedit.History) {
+	f, ok := in.(*os.File)
+	if !ok || !isTerminal(f) {
+		return &scannerReader{scanner: bufio.NewScanner(in), out: out}, nil
+	}
+
+	hist, err := lineedit.LoadHistory(historyPath, maxInputHistory)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "Note: input history unavailable: %v\n\n", err)
+		hist, _ = lineedit.LoadHistory("", maxInputHistory)
+	}
+	editor := newTermEditor(f, out)
+	editor.History = hist
+	editor.Complete = func(line string) (int, []string) {
+		return completeInput(workDir(), line)
+	}
+	return editor, hist
+}
+
+// completeInput completes slash command names at the start of the line and
+// file paths, relative to workDir, everywhere else. An empty workDir means
+// the process's working directory.
+func completeInput(workDir, line string) (int, []string) {
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+
+	if start == 0 && strings.HasPrefix(word, "/") && !strings.Contains(word[1:], "/") {
+		var names []string
+		for _, c := range slashCommands {
+			if name := "/" + c.name; strings.HasPrefix(name, word) {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			return start, names
+		}
+	}
+	return start, completePath(workDir, word)
+}
+
+// completePath lists the entries matching word, a partial path relative to
+// workDir. Directories end in "/"; hidden entries are offered only once word
+// asks for them.
+func completePath(workDir, word string) []string {
+	dir, prefix := filepath.Split(word)
+	readDir := dir
+	if rest, ok := strings.CutPrefix(readDir, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			readDir = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(readDir) {
+		readDir = filepath.Join(workDir, readDir)
+	}
+	if readDir == "" {
+		readDir = "."
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		candidate := dir + name
+		if info, err := os.Stat(filepath.Join(readDir, name)); err == nil && info.IsDir() {
+			candidate += "/"
+		}
+		matches = append(matches, candidate)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// edit lets the user change command with executor.EditCommand. ok is false
+// when input ended.
+func (s *session) edit(command string, out io.Writer) (edited string, ok bool) {
+	edited, err := executor.EditCommand(command, s.in, out)
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			_, _ = fmt.Fprintf(out, "  Input error: %v\n", err)
+		}
+		return "", false
+	}
+	return edited, true
+}
@@ -0,0 +1,478 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/lineedit"
+)
+
+// fakeTerminal makes Run treat a file of scripted keys as a terminal, using
+// the line editor without raw mode.
+func fakeTerminal(t *testing.T, keys string) *os.File {
+	t.Helper()
+	origIsTerminal, origNewEditor := isTerminal, newTermEditor
+	t.Cleanup(func() { isTerminal, newTermEditor = origIsTerminal, origNewEditor })
+	isTerminal = func(*os.File) bool { return true }
+	newTermEditor = func(f *os.File, out io.Writer) *lineedit.Editor { return lineedit.New(f, out) }
+
+	path := filepath.Join(t.TempDir(), "keys")
+	if err := os.WriteFile(path, []byte(keys), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+func TestTerminalInputUsesEditorAndHistory(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	ran := false
+	runCapture = func(_ executor.Runner, _ string) (string, int, error) {
+		ran = true
+		return "", 0, nil
+	}
+	historyPath := filepath.Join(t.TempDir(), "chat_history")
+	if err := os.WriteFile(historyPath, []byte("list files\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Recall the saved line with Up, answer "s", interrupt a line, then quit.
+	in := fakeTerminal(t, "\x1b[A\rs\rhalf typed\x03exit\r")
+	mock := &mockProvider{responses: []string{`{"text":"Try this.","commands":["ls -la"]}`}}
+	out := &bytes.Buffer{}
+	if err := Run(mock, in, out, Options{HistoryPath: historyPath}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if len(mock.messages) != 1 {
+		t.Fatalf("provider calls = %d, want 1", len(mock.messages))
+	}
+	msgs := mock.messages[0]
+	if got := msgs[len(msgs)-1].Content; got != "list files" {
+		t.Errorf("sent %q, want the recalled line", got)
+	}
+	if ran {
+		t.Error("skipped command should not run")
+	}
+
+	h, err := lineedit.LoadHistory(historyPath, maxInputHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Answers to command prompts and interrupted lines are not history.
+	if got, want := h.Entries(), []string{"list files", "exit"}; !slices.Equal(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
+
+func TestTerminalInterruptAtConfirmationSkips(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	ran := false
+	runCapture = func(_ executor.Runner, _ string) (string, int, error) {
+		ran = true
+		return "", 0, nil
+	}
+
+	in := fakeTerminal(t, "clean up\rr\r\x03exit\r")
+	mock := &mockProvider{responses: []string{`{"text":"This deletes.","commands":["rm -rf build"]}`}}
+	out := &bytes.Buffer{}
+	if err := Run(mock, in, out, Options{}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if ran {
+		t.Error("Ctrl-C at the confirmation should not run the command")
+	}
+	if !strings.Contains(out.String(), "Skipped") {
+		t.Errorf("output should contain 'Skipped', got:\n%s", out.String())
+	}
+}
+
+func TestTerminalHistoryUnavailable(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	in := fakeTerminal(t, "exit\r")
+	out := &bytes.Buffer{}
+	// A directory cannot be read as a history file.
+	if err := Run(&mockProvider{}, in, out, Options{HistoryPath: t.TempDir()}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if !strings.Contains(out.String(), "input history unavailable") {
+		t.Errorf("output should note the history problem, got:\n%s", out.String())
+	}
+}
+
+func TestCompleteInput(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "Makefile", ".env", "docs/design.md", "docs/notes.txt"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	tests := []struct {
+		name      string
+		line      string
+		wantStart int
+		want      []string
+	}{
+		{"slash command", "/mo", 0, []string{"/model"}},
+		{"several slash commands, in /help order", "/h", 0, []string{"/history", "/help"}},
+		{"all slash commands", "/", 0, []string{"/model", "/clear", "/context", "/history", "/retry", "/undo", "/reset", "/tokens", "/help"}},
+		{"unknown command falls back to paths", "/zz", 0, nil},
+		{"file in cwd", "explain ma", 8, []string{"main.go"}},
+		{"directory gets slash", "cat do", 4, []string{"docs/"}},
+		{"inside directory", "cat docs/n", 4, []string{"docs/notes.txt"}},
+		{"slash command word later is a path", "/model /nonexistent/", 7, nil},
+		{"hidden only when asked", "cat .e", 4, []string{".env"}},
+		{"hidden skipped", "cat ", 4, []string{"Makefile", "docs/", "main.go"}},
+		{"missing dir", "cat nope/x", 4, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, got := completeInput("", tt.line)
+			if start != tt.wantStart {
+				t.Errorf("start = %d, want %d", start, tt.wantStart)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("candidates = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompleteInputWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "build", "out"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+
+	if _, got := completeInput(dir, "cd bu"); !slices.Equal(got, []string{"build/"}) {
+		t.Errorf("candidates = %q, want [build/] from the work dir", got)
+	}
+	if _, got := completeInput(dir, "ls build/o"); !slices.Equal(got, []string{"build/out/"}) {
+		t.Errorf("candidates = %q, want [build/out/]", got)
+	}
+	if _, got := completeInput("/nonexistent", "ls "+dir+"/bu"); !slices.Equal(got, []string{dir + "/build/"}) {
+		t.Errorf("absolute path candidates = %q, want [%s/build/]", got, dir)
+	}
+}
+
+func TestTerminalCompletionFollowsShellDir(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "build"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+
+	in := fakeTerminal(t, "explain bu\t\r\x04")
+	mock := &mockProvider{responses: []string{`{"text":"ok","commands":[]}`}}
+	if err := Run(mock, in, &bytes.Buffer{}, Options{Runner: &executor.Shell{Dir: dir}}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if len(mock.messages) != 1 {
+		t.Fatalf("sent %d requests, want 1", len(mock.messages))
+	}
+	if got := mock.messages[0][len(mock.messages[0])-1].Content; got != "explain build/" {
+		t.Errorf("sent %q, want the path completed in the shell's directory", got)
+	}
+}
+
+func TestCompletePathHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	if err := os.Mkdir(filepath.Join(home, "projects"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got := completePath("", "~/pro"); !slices.Equal(got, []string{"~/projects/"}) {
+		t.Errorf("completePath(~/pro) = %q, want [~/projects/]", got)
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string // user messages sent, in order
+	}{
+		{
+			name:  "bracketed paste",
+			input: "\x1b[200~panic: boom\n\ngoroutine 1 [running]:\nmain.main()\x1b[201~\nexit\n",
+			want:  []string{"panic: boom\n\ngoroutine 1 [running]:\nmain.main()"},
+		},
+		{
+			name:  "text before paste",
+			input: "why does this fail? \x1b[200~line 1\nline 2\x1b[201~\nexit\n",
+			want:  []string{"why does this fail? line 1\nline 2"},
+		},
+		{
+			name:  "paste is literal",
+			input: "\x1b[200~echo a \\\n\"\"\"\x1b[201~\nexit\n",
+			want:  []string{"echo a \\\n\"\"\""},
+		},
+		{
+			name:  "trailing backslash",
+			input: "find files \\\nlarger than 1GB \\\nin /var\nexit\n",
+			want:  []string{"find files \nlarger than 1GB \nin /var"},
+		},
+		{
+			name:  "fenced block",
+			input: "\"\"\"\nfor f in *.log; do\n  gzip \"$f\"\ndone\n\"\"\"\nexit\n",
+			want:  []string{"for f in *.log; do\n  gzip \"$f\"\ndone"},
+		},
+		{
+			name:  "fence with text on its lines",
+			input: "\"\"\"explain this\nset -euo pipefail\"\"\"\nexit\n",
+			want:  []string{"explain this\nset -euo pipefail"},
+		},
+		{
+			name:  "one-line fence",
+			input: "\"\"\"hello\"\"\"\nexit\n",
+			want:  []string{"hello"},
+		},
+		{
+			name:  "fence keeps backslashes",
+			input: "\"\"\"\nls \\\n\"\"\"\nexit\n",
+			want:  []string{"ls \\"},
+		},
+		{
+			name:  "multi-line slash text is a message",
+			input: "\"\"\"\n/usr/lib/libfoo.so: not found\nwhy?\n\"\"\"\nexit\n",
+			want:  []string{"/usr/lib/libfoo.so: not found\nwhy?"},
+		},
+		{
+			name:  "end of input in fence submits",
+			input: "\"\"\"\nunfinished\n",
+			want:  []string{"unfinished"},
+		},
+		{
+			name:  "end of input after backslash submits",
+			input: "unfinished \\\n",
+			want:  []string{"unfinished"},
+		},
+		{
+			name:  "end of input in paste submits",
+			input: "\x1b[200~a\nb\n",
+			want:  []string{"a\nb"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveVars(t)
+			defer restore()
+			stubEnv()
+
+			responses := make([]string, len(tt.want))
+			for i := range responses {
+				responses[i] = `{"text":"ok","commands":[]}`
+			}
+			mock := &mockProvider{responses: responses}
+			out := &bytes.Buffer{}
+			if err := Run(mock, strings.NewReader(tt.input), out, Options{}); err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+
+			var got []string
+			for _, msgs := range mock.messages {
+				got = append(got, msgs[len(msgs)-1].Content)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContinuationPrompt(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	mock := &mockProvider{responses: []string{`{"text":"ok","commands":[]}`}}
+	out := &bytes.Buffer{}
+	if err := Run(mock, strings.NewReader("a \\\nb\nexit\n"), out, Options{}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if !strings.Contains(out.String(), "sb> ... ") {
+		t.Errorf("output should show the continuation prompt, got:\n%s", out.String())
+	}
+}
+
+func TestInterruptInContinuationDiscardsMessage(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	in := fakeTerminal(t, "\"\"\"\rdraft\r\x03exit\r")
+	mock := &mockProvider{}
+	out := &bytes.Buffer{}
+	if err := Run(mock, in, out, Options{}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if len(mock.messages) != 0 {
+		t.Errorf("interrupted block was sent: %v", mock.messages)
+	}
+}
+
+func TestMultiLineInputRead(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+
+	for _, input := range []string{"a \\\n", "\"\"\"\n", "\x1b[200~a\n"} {
+		mock := &mockProvider{}
+		err := Run(mock, io.MultiReader(strings.NewReader(input), failingReader{err: io.ErrUnexpectedEOF}), &bytes.Buffer{}, Options{})
+		if err == nil {
+			t.Errorf("Run(%q) with failing input: want error", input)
+		}
+		if len(mock.messages) != 0 {
+			t.Errorf("Run(%q) sent a partial message", input)
+		}
+	}
+}
+
+func TestModifyCommand(t *testing.T) {
+	tests := []struct {
+		name      string
+		visual    string
+		input     string
+		wantRan   string
+		wantEdit  bool
+		wantInOut []string
+	}{
+		{
+			name:      "inline edit runs the edited command",
+			input:     "list files\nm\nls -la\nr\nthanks\nexit\n",
+			wantRan:   "ls -la",
+			wantEdit:  true,
+			wantInOut: []string{"[m]odify", "New command (Enter keeps it):", "> ls -la"},
+		},
+		{
+			name:      "edit to a destructive command is confirmed again",
+			input:     "list files\nm\nrm -rf build\nr\nn\nthanks\nexit\n",
+			wantInOut: []string{"> rm -rf build\n  Warning: this is a destructive command", "Skipped."},
+		},
+		{
+			name:     "empty edit keeps the suggestion",
+			input:    "list files\nm\n\nr\nthanks\nexit\n",
+			wantRan:  "ls",
+			wantEdit: false,
+		},
+		{
+			name:      "editor from VISUAL",
+			visual:    `printf 'ls -la /tmp\n' >`,
+			input:     "list files\nm\nr\nthanks\nexit\n",
+			wantRan:   "ls -la /tmp",
+			wantEdit:  true,
+			wantInOut: []string{"> ls -la /tmp"},
+		},
+		{
+			name:      "editor failure keeps the suggestion",
+			visual:    "false",
+			input:     "list files\nm\nr\nthanks\nexit\n",
+			wantRan:   "ls",
+			wantInOut: []string{"Edit error:"},
+		},
+		{
+			name:  "input ends while editing",
+			input: "list files\nm\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := saveVars(t)
+			defer restore()
+			stubEnv()
+			t.Setenv("VISUAL", tt.visual)
+			t.Setenv("EDITOR", "")
+
+			ran := ""
+			runCapture = func(_ executor.Runner, command string) (string, int, error) {
+				ran = command
+				return "", 0, nil
+			}
+			mock := &mockProvider{responses: []string{
+				`{"text":"Try this.","commands":["ls"]}`,
+				`{"text":"You're welcome.","commands":[]}`,
+			}}
+			out := &bytes.Buffer{}
+			if err := Run(mock, strings.NewReader(tt.input), out, Options{}); err != nil {
+				t.Fatalf("Run() error: %v", err)
+			}
+
+			if ran != tt.wantRan {
+				t.Errorf("ran %q, want %q", ran, tt.wantRan)
+			}
+			for _, want := range tt.wantInOut {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			edited := false
+			if len(mock.messages) == 2 {
+				for _, m := range mock.messages[1] {
+					if strings.HasPrefix(m.Content, "I changed your suggested command `ls` to `"+tt.wantRan+"`") {
+						edited = true
+					}
+				}
+			}
+			if edited != tt.wantEdit {
+				t.Errorf("edit recorded = %v, want %v", edited, tt.wantEdit)
+			}
+		})
+	}
+}
+
+func TestTerminalModifyEditsInPlace(t *testing.T) {
+	restore := saveVars(t)
+	defer restore()
+	stubEnv()
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+
+	ran := ""
+	runCapture = func(_ executor.Runner, command string) (string, int, error) {
+		ran = command
+		return "", 0, nil
+	}
+
+	in := fakeTerminal(t, "list files\rm\r -la\rr\rexit\r")
+	mock := &mockProvider{responses: []string{`{"text":"Try this.","commands":["ls"]}`}}
+	out := &bytes.Buffer{}
+	if err := Run(mock, in, out, Options{HistoryPath: filepath.Join(t.TempDir(), "history")}); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+	if ran != "ls -la" {
+		t.Errorf("ran %q, want the suggestion edited in place", ran)
+	}
+	if !strings.Contains(out.String(), "\r  edit> ls") {
+		t.Errorf("output should show the suggestion ready to edit:\n%s", out.String())
+	}
+}
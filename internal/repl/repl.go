@@ -4,205 +4,615 @@
 //
 // Environment context is refreshed every turn (not cached) because the user's
 // shell state changes between prompts (cd, git operations, file creation).
-// History is capped rather than summarized — token limits are the LLM's problem
-// via context window, not ours.
+// History is kept in full; each request is fitted to the model's context
+// window by a conversation.Budget, which shrinks old command output before
+// dropping old turns. With Options.Summarize, dropped turns are first folded
+// into a rolling summary pinned after the system prompt. With Options.Session,
+// the chat is resumed from and saved to disk after every turn. Input starting
+// with "/" is a local slash command (see commands.go), never sent to the model.
 package repl
 
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strings"
 	"time"
 
+	"github.com/hpkotak/shellbud/internal/approval"
+	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/conversation"
 	"github.com/hpkotak/shellbud/internal/executor"
+	"github.com/hpkotak/shellbud/internal/lineedit"
+	"github.com/hpkotak/shellbud/internal/output"
 	"github.com/hpkotak/shellbud/internal/prompt"
 	"github.com/hpkotak/shellbud/internal/provider"
 	"github.com/hpkotak/shellbud/internal/safety"
+	"github.com/hpkotak/shellbud/internal/sessions"
 	"github.com/hpkotak/shellbud/internal/shellenv"
 )
 
-const (
-	chatTimeout    = 120 * time.Second
-	maxHistoryMsgs = 50
-)
+const chatTimeout = 120 * time.Second
 
 // Package-level function variables for testability.
 var (
-	runCapture = executor.RunCapture
-	gatherEnv  = shellenv.Gather
+	runCapture = executor.Runner.RunCapture
+	gatherEnv  = shellenv.GatherIn
 )
 
+// Options configures a chat session.
+type Options struct {
+	// ContextWindow is the model's context window in tokens. Zero means
+	// config.DefaultContextWindow.
+	ContextWindow int
+	// Summarize folds turns that no longer fit into a rolling summary
+	// instead of dropping them outright.
+	Summarize bool
+	// Session, when set, is resumed from and saved after every turn.
+	Session *sessions.Handle
+	// Model is the model in use, shown by /model. Empty means the
+	// provider's configured model.
+	Model string
+	// ContextWindowFor returns the context window of a model switched to
+	// with /model. When nil, the window stays at ContextWindow.
+	ContextWindowFor func(model string) int
+	// HistoryPath is where prompt input is recalled from and saved to when
+	// reading from a terminal. Empty keeps history in memory.
+	HistoryPath string
+	// NDJSON writes each response as one output.Result line instead of
+	// human text. Input is read line by line without prompts, slash commands
+	// are unavailable, and commands are never run.
+	NDJSON bool
+	// Policy decides whether suggested commands are run without asking.
+	// The zero value, approval.Ask, offers each one.
+	Policy approval.Policy
+	// AllowForbidden offers forbidden commands for confirmation instead of
+	// refusing them. No policy runs them without asking.
+	AllowForbidden bool
+	// Runner runs the commands the user approves. Nil means an
+	// executor.Shell, which spawns a shell per command.
+	Runner executor.Runner
+}
+
+// session is the state of one chat: the full history and what is needed to
+// fit it into the model's context window.
+type session struct {
+	p provider.Provider
+	// model overrides the provider's model when set (see /model).
+	model     string
+	windowFor func(model string) int
+	budget    *conversation.Budget
+	summarize bool
+	history   []provider.Message
+	// summary stands in for the oldest history messages it covers.
+	summary conversation.Summary
+	// summaries records each summary generation, apart from the turns.
+	summaries []conversation.Summary
+	// cwd is the working directory from the latest environment snapshot.
+	cwd string
+	// shell runs approved commands, carrying state such as the working
+	// directory from one to the next (see /reset).
+	shell executor.Runner
+	// in reads the user's input.
+	in lineReader
+	// rec persists the session; nil when the chat is not saved.
+	rec *sessions.Handle
+	// usage accumulates the token usage of chat replies (see /tokens).
+	usage provider.Usage
+	// emit writes machine-readable results; nil for human output.
+	emit *output.Encoder
+	// policy is the approval policy for suggested commands.
+	policy approval.Policy
+	// allowForbidden offers forbidden commands instead of refusing them.
+	allowForbidden bool
+}
+
 // Run starts the interactive REPL loop.
-func Run(p provider.Provider, in io.Reader, out io.Writer) error {
-	_, _ = fmt.Fprintln(out, "ShellBud Chat (type 'exit' to quit)")
+func Run(p provider.Provider, in io.Reader, out io.Writer, opts Options) error {
+	var emit *output.Encoder
+	if opts.NDJSON {
+		// Only result lines reach out; everything meant for a person is
+		// dropped.
+		emit = output.NewEncoder(out, output.NDJSON)
+		out = io.Discard
+	}
+
+	_, _ = fmt.Fprintln(out, "ShellBud Chat (type /help for commands, 'exit' to quit)")
+	if opts.Policy != approval.Ask {
+		_, _ = fmt.Fprintf(out, "Approval policy: %s\n", opts.Policy.Describe())
+	}
+	if _, ok := opts.Runner.(*executor.ShellSession); ok {
+		_, _ = fmt.Fprintln(out, "Commands run in one shell session (/reset starts a new one).")
+	}
 	_, _ = fmt.Fprintln(out)
 
-	scanner := bufio.NewScanner(in)
-	var history []provider.Message
+	window := opts.ContextWindow
+	if window <= 0 {
+		window = config.DefaultContextWindow
+	}
+	s := &session{
+		p:              p,
+		model:          opts.Model,
+		windowFor:      opts.ContextWindowFor,
+		budget:         conversation.NewBudget(window),
+		summarize:      opts.Summarize,
+		rec:            opts.Session,
+		emit:           emit,
+		policy:         opts.Policy,
+		allowForbidden: opts.AllowForbidden,
+		shell:          opts.Runner,
+	}
+	if s.shell == nil {
+		s.shell = &executor.Shell{}
+	}
+	if s.rec != nil && len(s.rec.Session.Messages) > 0 {
+		s.history = s.rec.Session.History()
+		if sum := s.rec.Session.Summary(); sum.Covers <= len(s.history) {
+			s.summary = sum
+		}
+		_, _ = fmt.Fprintf(out, "Resumed session %s (%d messages).\n\n", s.rec.Session.ID, len(s.history))
+	}
 
-	for {
-		_, _ = fmt.Fprint(out, "sb> ")
+	var inputHistory *lineedit.History
+	if emit != nil {
+		s.in = &scannerReader{scanner: bufio.NewScanner(in), out: out}
+	} else {
+		s.in, inputHistory = newLineReader(in, out, opts.HistoryPath, func() string { return s.shell.WorkDir() })
+	}
 
-		if !scanner.Scan() {
-			if err := scanner.Err(); err != nil {
-				_, _ = fmt.Fprintf(out, "\nInput error: %v\n", err)
-				return err
+	for {
+		line, err := s.readMessage()
+		if err != nil {
+			if errors.Is(err, lineedit.ErrInterrupted) {
+				continue
 			}
-			_, _ = fmt.Fprintln(out)
-			break // EOF (Ctrl+D)
+			if errors.Is(err, io.EOF) {
+				_, _ = fmt.Fprintln(out)
+				break // EOF (Ctrl+D)
+			}
+			_, _ = fmt.Fprintf(out, "\nInput error: %v\n", err)
+			return err
 		}
 
-		input := strings.TrimSpace(scanner.Text())
+		input := strings.TrimSpace(line)
 		if input == "" {
 			continue
 		}
+		if inputHistory != nil {
+			if err := inputHistory.Add(input); err != nil {
+				_, _ = fmt.Fprintf(out, "  Note: could not save input history: %v\n", err)
+			}
+		}
 		if input == "exit" || input == "quit" {
 			_, _ = fmt.Fprintln(out, "Bye!")
 			return nil
 		}
 
-		// Refresh environment context each turn.
-		envSnap := gatherEnv()
-		sysMsg := provider.Message{
-			Role:    "system",
-			Content: prompt.ChatSystemPrompt(envSnap.Format()),
+		if c, args, ok := findSlashCommand(input); ok {
+			if emit != nil {
+				_ = emit.Error(errors.New("slash commands are not available with --output ndjson"))
+				continue
+			}
+			if c.run(s, args, out) {
+				s.save(out)
+			}
+			continue
 		}
 
 		// Add user message to history.
-		history = append(history, provider.Message{Role: "user", Content: input})
+		s.history = append(s.history, provider.Message{Role: "user", Content: input})
+		s.respond(out)
+		s.save(out)
+	}
 
-		// Trim history if too long (keep most recent messages).
-		if len(history) > maxHistoryMsgs {
-			history = history[len(history)-maxHistoryMsgs:]
-		}
+	return nil
+}
 
-		// Build full message list: system + history.
-		messages := make([]provider.Message, 0, 1+len(history))
-		messages = append(messages, sysMsg)
-		messages = append(messages, history...)
+// respond asks the model to answer the conversation so far, displays the
+// reply, and offers any commands it suggests.
+func (s *session) respond(out io.Writer) {
+	// Refresh environment context each turn.
+	envSnap := gatherEnv(s.shell.WorkDir())
+	s.cwd = envSnap.CWD
+	sysMsg := provider.Message{
+		Role:    "system",
+		Content: prompt.ChatSystemPrompt(envSnap.Format()),
+	}
 
-		result, err := sendMessage(p, messages)
-		if err != nil {
-			_, _ = fmt.Fprintf(out, "Error: %v\n\n", err)
-			continue
-		}
+	result, streamed, err := s.ask(sysMsg, out)
+	if s.emit != nil {
+		s.respondDocument(result, err)
+		return
+	}
+	if err != nil {
+		printError(out, "Error", err)
+		_, _ = fmt.Fprintln(out)
+		return
+	}
 
-		if result.Warning != "" {
-			_, _ = fmt.Fprintf(out, "\n  Note: %s\n", result.Warning)
-		}
+	if result.Warning != "" {
+		_, _ = fmt.Fprintf(out, "\n  Note: %s\n", result.Warning)
+	}
 
-		// Add assistant response to history.
-		history = append(history, provider.Message{Role: "assistant", Content: result.Text})
+	// Add assistant response to history.
+	s.history = append(s.history, provider.Message{Role: "assistant", Content: result.Text})
 
-		parsed := prompt.ParseChatResponse(result.Text)
+	parsed := prompt.ParseChatResponse(result.Text)
 
-		// Display the full response.
+	// Display the full response unless it was already streamed.
+	if !streamed {
 		_, _ = fmt.Fprintf(out, "\n%s\n", parsed.Text)
-		if !parsed.Structured {
-			_, _ = fmt.Fprintln(out, "  Note: model response was not valid structured output; no commands were run.")
-		}
+	}
+	if !parsed.Structured {
+		_, _ = fmt.Fprintln(out, "  Note: model response was not valid structured output; no commands were run.")
+	}
 
-		// Handle any extracted commands.
-		for _, command := range parsed.Commands {
-			handleCommand(command, &history, sysMsg, p, scanner, out)
+	// Handle any extracted commands, one at a time unless the user runs
+	// the rest as a batch.
+	for commands := parsed.Commands; len(commands) > 0; {
+		commands = commands[handleCommand(commands, s, sysMsg, out):]
+	}
+
+	_, _ = fmt.Fprintln(out)
+}
+
+// ask fits the conversation into the context budget, sends it, and calibrates
+// the budget from the usage the provider reports. When summarizing, turns
+// that would be dropped are folded into the summary first; if that fails
+// they are dropped as usual.
+func (s *session) ask(sysMsg provider.Message, out io.Writer) (provider.ChatResponse, bool, error) {
+	messages, dropped := s.budget.FitPinned(s.summary.Pinned(sysMsg), s.history[s.summary.Covers:])
+	if dropped > 0 && s.summarize {
+		if err := s.compact(dropped); err != nil {
+			_, _ = fmt.Fprintf(out, "\n  Note: could not summarize earlier messages (%v); they were dropped.\n", err)
+		} else {
+			_, _ = fmt.Fprintf(out, "\n  Note: summarized %d earlier messages to fit the context window.\n", dropped)
+			messages, _ = s.budget.FitPinned(s.summary.Pinned(sysMsg), s.history[s.summary.Covers:])
 		}
+	}
 
-		_, _ = fmt.Fprintln(out)
+	resp, streamed, err := sendMessage(s.p, s.model, messages, out)
+	if err == nil {
+		s.budget.Observe(messages, resp.Usage)
+		s.usage = addUsage(s.usage, resp.Usage)
+	}
+	return resp, streamed, err
+}
+
+// compact folds the n oldest unsummarized messages into the rolling summary.
+// The request asks for prose, not the JSON contract, and the reply is kept as
+// context only: it is never displayed as an answer or parsed for commands.
+func (s *session) compact(n int) error {
+	start := s.summary.Covers
+	messages := s.budget.SummaryRequest(prompt.SummarySystemPrompt(), s.summary.Text, s.history[start:start+n])
+
+	ctx, cancel := context.WithTimeout(context.Background(), chatTimeout)
+	defer cancel()
+	resp, err := s.p.Chat(ctx, provider.ChatRequest{Messages: messages, Model: s.model})
+	if err != nil {
+		return err
+	}
+	text := s.budget.ClampSummary(resp.Text)
+	if text == "" {
+		return errors.New("model returned an empty summary")
 	}
 
+	s.budget.Observe(messages, resp.Usage)
+	s.summary = conversation.Summary{Text: text, Covers: start + n, Usage: resp.Usage}
+	s.summaries = append(s.summaries, s.summary)
+	if s.rec != nil {
+		s.rec.Session.AddSummary(s.summary)
+	}
 	return nil
 }
 
-func sendMessage(p provider.Provider, messages []provider.Message) (provider.ChatResponse, error) {
+// save writes the session to disk when it is persisted. A failed save is
+// reported but does not end the chat.
+func (s *session) save(out io.Writer) {
+	if s.rec == nil {
+		return
+	}
+	s.rec.Session.SetHistory(s.history)
+	if err := s.rec.Save(); err != nil {
+		_, _ = fmt.Fprintf(out, "  Note: could not save session: %v\n", err)
+	}
+}
+
+// recordCommand notes a command executed in dir for the saved session.
+func (s *session) recordCommand(command, dir string, exitCode int) {
+	if s.rec == nil {
+		return
+	}
+	s.rec.Session.Commands = append(s.rec.Session.Commands, sessions.Command{
+		Command:  command,
+		ExitCode: exitCode,
+		CWD:      dir,
+		RanAt:    time.Now(),
+	})
+}
+
+// sendMessage calls the provider, rendering the response's text field to out
+// as it streams in when the provider supports it. streamed reports whether any
+// text was rendered, in which case callers should not print it again.
+func sendMessage(p provider.Provider, model string, messages []provider.Message, out io.Writer) (resp provider.ChatResponse, streamed bool, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), chatTimeout)
 	defer cancel()
 
-	return p.Chat(ctx, provider.ChatRequest{
+	var textStream prompt.TextStream
+	resp, err = provider.StreamChat(ctx, p, provider.ChatRequest{
 		Messages:   messages,
+		Model:      model,
 		ExpectJSON: true,
+	}, func(delta string) {
+		text := textStream.Feed(delta)
+		if text == "" {
+			return
+		}
+		if !streamed {
+			_, _ = fmt.Fprintln(out)
+			streamed = true
+		}
+		_, _ = fmt.Fprint(out, text)
 	})
+	if streamed {
+		_, _ = fmt.Fprintln(out)
+	}
+	return resp, streamed, err
 }
 
-func handleCommand(command string, history *[]provider.Message, sysMsg provider.Message, p provider.Provider, scanner *bufio.Scanner, out io.Writer) {
-	level := safety.Classify(command)
-
-	_, _ = fmt.Fprintf(out, "\n  > %s\n", command)
+// respondDocument records a reply and writes it as a result line. Its
+// commands are listed but never run.
+func (s *session) respondDocument(result provider.ChatResponse, err error) {
+	if err != nil {
+		_ = s.emit.Error(err)
+		return
+	}
+	s.history = append(s.history, provider.Message{Role: "assistant", Content: result.Text})
+	_ = s.emit.Result(output.NewResult(s.p.Name(), s.model, result, prompt.ParseChatResponse(result.Text)))
+}
 
-	if level == safety.Destructive {
-		_, _ = fmt.Fprintln(out, "  Warning: destructive command")
+// handleCommand offers the first of commands, the suggested commands not yet
+// handled, and returns how many it handled. With [m]odify the user edits the
+// command and is offered the edited command, classified afresh, until it is
+// run, explained or skipped. With [a]ll, offered when more commands follow,
+// the rest run as one batch.
+func handleCommand(commands []string, s *session, sysMsg provider.Message, out io.Writer) int {
+	command := commands[0]
+	suggested := command
+	choices := "  [r]un / [e]xplain / [m]odify / [s]kip: "
+	if len(commands) > 1 {
+		choices = "  [r]un / [e]xplain / [m]odify / [a]ll / [s]kip: "
 	}
+	for {
+		v := safety.Evaluate(command)
+		level := v.Level
 
-	_, _ = fmt.Fprint(out, "  [r]un / [e]xplain / [s]kip: ")
+		_, _ = fmt.Fprintf(out, "\n  > %s\n", command)
 
-	if !scanner.Scan() {
-		if err := scanner.Err(); err != nil {
-			_, _ = fmt.Fprintf(out, "  Input error: %v\n", err)
+		if warning := v.Warning(); warning != "" {
+			_, _ = fmt.Fprintf(out, "  %s\n", warning)
+		}
+		for _, seg := range v.Flagged() {
+			_, _ = fmt.Fprintf(out, "    %s\n", seg)
 		}
-		return
-	}
-	choice := strings.TrimSpace(strings.ToLower(scanner.Text()))
 
-	switch choice {
-	case "r", "run":
-		if level == safety.Destructive {
-			_, _ = fmt.Fprint(out, "  Are you sure? [y/N]: ")
-			if !scanner.Scan() {
-				if err := scanner.Err(); err != nil {
-					_, _ = fmt.Fprintf(out, "  Input error: %v\n", err)
+		switch action, reason := s.policy.Decide(command); action {
+		case approval.Show:
+			_, _ = fmt.Fprintf(out, "  Not run (%s).\n", reason)
+			return 1
+		case approval.Refuse:
+			_, _ = fmt.Fprintf(out, "  Refused: %s.\n", reason)
+			return 1
+		case approval.Run:
+			_, _ = fmt.Fprintf(out, "  Running (%s).\n", reason)
+			s.run(command, out)
+			return 1
+		}
+		if level == safety.Forbidden && !s.allowForbidden {
+			_, _ = fmt.Fprintln(out, "  Not offered: forbidden commands need sb chat --allow-forbidden.")
+			return 1
+		}
+
+		choice, ok := s.readChoice(choices, out)
+		if !ok {
+			return 1
+		}
+
+		switch choice {
+		case "r", "run":
+			if level >= safety.Destructive {
+				confirm, ok := s.readChoice("  Are you sure? [y/N]: ", out)
+				if !ok {
+					return 1
+				}
+				if confirm != "y" && confirm != "yes" {
+					_, _ = fmt.Fprintln(out, "  Skipped.")
+					return 1
 				}
-				return
 			}
-			confirm := strings.TrimSpace(strings.ToLower(scanner.Text()))
-			if confirm != "y" && confirm != "yes" {
+
+			if command != suggested {
+				// Tell the model about the correction so it can learn from it.
+				s.history = append(s.history, conversation.EditedCommand(suggested, command))
+			}
+			s.run(command, out)
+
+		case "a", "all":
+			if len(commands) == 1 {
 				_, _ = fmt.Fprintln(out, "  Skipped.")
-				return
+				break
 			}
-		}
+			batch := append([]string{command}, commands[1:]...)
+			if !s.confirmPlan(batch, out) {
+				_, _ = fmt.Fprintln(out, "  Skipped all.")
+				return len(commands)
+			}
+			if command != suggested {
+				s.history = append(s.history, conversation.EditedCommand(suggested, command))
+			}
+			s.runAll(batch, out)
+			return len(commands)
 
-		_, _ = fmt.Fprintln(out)
-		output, exitCode, err := runCapture(command)
-		if err != nil {
-			_, _ = fmt.Fprintf(out, "  Execution error: %v\n", err)
-			return
+		case "m", "modify":
+			edited, ok := s.edit(command, out)
+			if !ok {
+				return 1
+			}
+			command = edited
+			continue
+
+		case "e", "explain":
+			s.history = append(s.history, conversation.ExplainRequest(command))
+
+			result, streamed, err := s.ask(sysMsg, out)
+			if err != nil {
+				printError(out, "  Explain error", err)
+				return 1
+			}
+
+			s.history = append(s.history, provider.Message{Role: "assistant", Content: result.Text})
+			if !streamed {
+				explanation := prompt.ParseChatResponse(result.Text).Text
+				_, _ = fmt.Fprintf(out, "\n%s\n", explanation)
+			}
+
+		case "s", "skip", "":
+			_, _ = fmt.Fprintln(out, "  Skipped.")
+		default:
+			_, _ = fmt.Fprintln(out, "  Skipped.")
 		}
+		return 1
+	}
+}
 
-		// Add command output to conversation context.
-		contextMsg := fmt.Sprintf("I ran `%s` — exit code %d.", command, exitCode)
-		if output != "" {
-			contextMsg += fmt.Sprintf("\nOutput:\n```\n%s\n```", output)
+// confirmPlan shows every command in a batch with its classification and
+// reports whether to run them. One confirmation covers all the destructive
+// ones; a batch of safe commands needs none beyond choosing [a]ll. A batch
+// with a forbidden command is refused unless forbidden commands are allowed.
+func (s *session) confirmPlan(commands []string, out io.Writer) bool {
+	_, _ = fmt.Fprintln(out, "\n  Plan (runs in order, stops at the first failure):")
+	destructive, forbidden := 0, 0
+	for i, command := range commands {
+		note := ""
+		if level := safety.Classify(command); level != safety.Safe {
+			note = "  (" + level.String() + ")"
+			if level >= safety.Destructive {
+				destructive++
+			}
+			if level == safety.Forbidden {
+				forbidden++
+			}
 		}
-		*history = append(*history, provider.Message{
-			Role:    "user",
-			Content: contextMsg,
-		})
+		_, _ = fmt.Fprintf(out, "    %d. %s%s\n", i+1, command, note)
+	}
+	if forbidden > 0 && !s.allowForbidden {
+		_, _ = fmt.Fprintln(out, "  The plan has forbidden commands, which need sb chat --allow-forbidden.")
+		return false
+	}
+	if destructive == 0 {
+		return true
+	}
+	confirm, ok := s.readChoice(fmt.Sprintf("  %d of these are destructive. Run all %d? [y/N]: ", destructive, len(commands)), out)
+	return ok && (confirm == "y" || confirm == "yes")
+}
 
-	case "e", "explain":
-		explainMsg := provider.Message{
-			Role:    "user",
-			Content: fmt.Sprintf("Explain what this command does step by step: `%s`", command),
+// runAll runs commands in order and stops at the first that fails. The
+// outcome goes into the conversation as a single batch result.
+func (s *session) runAll(commands []string, out io.Writer) {
+	var steps []conversation.BatchStep
+	for _, command := range commands {
+		_, _ = fmt.Fprintf(out, "\n  > %s\n\n", command)
+		output, exitCode, err := s.execute(command, out)
+		if err != nil {
+			break
 		}
-		*history = append(*history, explainMsg)
+		steps = append(steps, conversation.BatchStep{Command: command, ExitCode: exitCode, Output: output})
+		if exitCode != 0 {
+			_, _ = fmt.Fprintf(out, "\n  Stopped: exit code %d.\n", exitCode)
+			break
+		}
+	}
+	_, _ = fmt.Fprintf(out, "  Ran %d of %d commands.\n", len(steps), len(commands))
+	if len(steps) > 0 {
+		s.history = append(s.history, conversation.BatchResult(steps, len(commands)))
+	}
+}
+
+// run runs command and adds its output to the conversation context.
+func (s *session) run(command string, out io.Writer) {
+	_, _ = fmt.Fprintln(out)
+	output, exitCode, err := s.execute(command, out)
+	if err != nil {
+		return
+	}
 
-		// Build messages for immediate LLM call.
-		messages := make([]provider.Message, 0, 1+len(*history))
-		messages = append(messages, sysMsg)
-		messages = append(messages, *history...)
+	s.history = append(s.history, conversation.CommandResult(command, exitCode, output))
+}
 
-		result, err := sendMessage(p, messages)
-		if err != nil {
-			_, _ = fmt.Fprintf(out, "  Explain error: %v\n", err)
-			return
+// execute runs command in the session's shell and records it, reporting an
+// execution error or a change of working directory to the user.
+func (s *session) execute(command string, out io.Writer) (output string, exitCode int, err error) {
+	dir := s.shell.WorkDir()
+	output, exitCode, err = runCapture(s.shell, command)
+	if err != nil {
+		_, _ = fmt.Fprintf(out, "  Execution error: %v\n", err)
+		return "", 0, err
+	}
+	if dir == "" {
+		dir = s.cwd
+	}
+	s.recordCommand(command, dir, exitCode)
+	if now := s.shell.WorkDir(); now != "" && now != dir {
+		_, _ = fmt.Fprintf(out, "  Working directory is now %s\n", now)
+	}
+	return output, exitCode, nil
+}
+
+// readChoice reads a lowercased answer to a prompt. ok is false when input
+// ended or failed; Ctrl-C reads as an empty answer, which declines.
+func (s *session) readChoice(prompt string, out io.Writer) (choice string, ok bool) {
+	line, err := s.in.ReadLine(prompt)
+	if err != nil {
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			return "", true
+		}
+		if !errors.Is(err, io.EOF) {
+			_, _ = fmt.Fprintf(out, "  Input error: %v\n", err)
 		}
+		return "", false
+	}
+	return strings.TrimSpace(strings.ToLower(line)), true
+}
 
-		*history = append(*history, provider.Message{Role: "assistant", Content: result.Text})
-		explanation := prompt.ParseChatResponse(result.Text).Text
-		_, _ = fmt.Fprintf(out, "\n%s\n", explanation)
+// printError reports a provider failure with a next step suited to its class.
+func printError(out io.Writer, label string, err error) {
+	_, _ = fmt.Fprintf(out, "%s: %v\n", label, err)
+	if hint := errorHint(err); hint != "" {
+		_, _ = fmt.Fprintf(out, "  Hint: %s\n", hint)
+	}
+}
 
-	case "s", "skip", "":
-		_, _ = fmt.Fprintln(out, "  Skipped.")
+// errorHint returns an actionable suggestion for classified provider errors,
+// or "" when there is nothing more useful to say than the error itself.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, provider.ErrRateLimited):
+		var httpErr *provider.HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+			return fmt.Sprintf("the provider is rate limiting requests; wait %s and try again.", httpErr.RetryAfter.Round(time.Second))
+		}
+		return "the provider is rate limiting requests; wait a moment and try again."
+	case errors.Is(err, provider.ErrAuth):
+		return "check the provider API key (e.g. OPENAI_API_KEY) or run 'sb setup'."
+	case errors.Is(err, provider.ErrModelNotFound):
+		return "the model is not available; pull it (ollama pull <model>) or pick another with 'sb config set model'."
+	case errors.Is(err, provider.ErrContextLength):
+		return "the conversation is too long for this model; start a new 'sb chat' session."
+	case errors.Is(err, provider.ErrTransient):
+		return "the provider had a temporary failure and retries did not help; try again shortly."
 	default:
-		_, _ = fmt.Fprintln(out, "  Skipped.")
+		return ""
 	}
 }
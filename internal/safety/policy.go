@@ -0,0 +1,266 @@
+package safety
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of a safety file: ~/.shellbud/safety.yaml for the
+// user's, .shellbud/safety.yaml for a repository's.
+const FileName = "safety.yaml"
+
+// Policy is an ordered set of compiled rules.
+type Policy struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	pattern *regexp.Regexp
+	exclude *regexp.Regexp // nil means no exclusion
+}
+
+// NewPolicy compiles rules into a Policy.
+func NewPolicy(rules []Rule) (*Policy, error) {
+	p := &Policy{rules: make([]compiledRule, len(rules))}
+	for i, r := range rules {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: rule %q: invalid pattern: %w", r.Source, r.Name, err)
+		}
+		p.rules[i] = compiledRule{Rule: r, pattern: pattern}
+		if r.Exclude != "" {
+			if p.rules[i].exclude, err = regexp.Compile(r.Exclude); err != nil {
+				return nil, fmt.Errorf("%s: rule %q: invalid exclude: %w", r.Source, r.Name, err)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Evaluate returns the highest level among the rules command matches, or
+// Safe when it matches none, with the matching rules. The rules are matched
+// against the whole command, which keeps bash -c and eval arguments in view,
+// and against each of its segments, so anchored rules apply to every command
+// of a pipeline or list.
+func (p *Policy) Evaluate(command string) Verdict {
+	all := make([]bool, len(p.rules))
+	p.mark(command, all)
+
+	var segments []Segment
+	for _, text := range Split(command) {
+		hit := make([]bool, len(p.rules))
+		p.mark(text, hit)
+		for i := range hit {
+			all[i] = all[i] || hit[i]
+		}
+		segments = append(segments, Segment{Text: text, Verdict: p.verdict(hit)})
+	}
+
+	v := p.verdict(all)
+	if len(segments) > 1 {
+		v.Segments = segments
+	}
+	return v
+}
+
+// mark sets hit[i] for each rule i that command matches.
+func (p *Policy) mark(command string, hit []bool) {
+	for i, r := range p.rules {
+		if r.pattern.MatchString(command) && (r.exclude == nil || !r.exclude.MatchString(command)) {
+			hit[i] = true
+		}
+	}
+}
+
+// verdict collects the rules set in hit.
+func (p *Policy) verdict(hit []bool) Verdict {
+	var v Verdict
+	for i, r := range p.rules {
+		if hit[i] {
+			v.Matched = append(v.Matched, r.Rule)
+			v.Level = max(v.Level, r.Level)
+		}
+	}
+	return v
+}
+
+// Classify returns the level Evaluate would.
+func (p *Policy) Classify(command string) Level {
+	return p.Evaluate(command).Level
+}
+
+var (
+	builtinPolicy *Policy
+	builtinOnce   sync.Once
+	active        atomic.Pointer[Policy]
+)
+
+// Current returns the policy Classify uses: the one passed to Use, or the
+// built-in rules.
+func Current() *Policy {
+	if p := active.Load(); p != nil {
+		return p
+	}
+	builtinOnce.Do(func() {
+		p, err := NewPolicy(Builtin())
+		if err != nil {
+			panic(err) // the built-in patterns are constants
+		}
+		builtinPolicy = p
+	})
+	return builtinPolicy
+}
+
+// Use makes p the policy Classify uses. nil restores the built-in rules.
+func Use(p *Policy) {
+	active.Store(p)
+}
+
+// fileRule is a rule as written in a safety file.
+type fileRule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	Exclude string `yaml:"exclude"`
+	Level   string `yaml:"level"`
+	Reason  string `yaml:"reason"`
+}
+
+type safetyFile struct {
+	Rules []fileRule `yaml:"rules"`
+}
+
+// LoadPolicy merges the built-in rules with the user's safety file at
+// userPath and then a repository's at repoPath, and leaves out the built-in
+// rules of the families in disabled (see Families). Either path may be empty
+// or name a missing file.
+//
+// A rule with a new name is added; a pattern and a reason are required, and
+// the level defaults to destructive. In the user's file, a rule named after
+// an earlier one overrides the fields it sets, so `level: safe` un-flags a
+// built-in rule. A repository's file can only add rules, so a cloned
+// repository cannot make ShellBud less careful.
+func LoadPolicy(userPath, repoPath string, disabled ...string) (*Policy, error) {
+	families := Families()
+	for _, f := range disabled {
+		if !slices.Contains(families, f) {
+			return nil, fmt.Errorf("unknown rule family %q (families: %s)", f, strings.Join(families, ", "))
+		}
+	}
+
+	rules := Builtin()
+	var err error
+	if rules, err = mergeFile(rules, userPath, true); err != nil {
+		return nil, err
+	}
+	if rules, err = mergeFile(rules, repoPath, false); err != nil {
+		return nil, err
+	}
+	rules = slices.DeleteFunc(rules, func(r Rule) bool {
+		return r.Family != "" && slices.Contains(disabled, r.Family)
+	})
+	return NewPolicy(rules)
+}
+
+// mergeFile merges the rules in the safety file at path into rules.
+func mergeFile(rules []Rule, path string, overrides bool) ([]Rule, error) {
+	if path == "" {
+		return rules, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return rules, nil
+		}
+		return nil, fmt.Errorf("reading safety file: %w", err)
+	}
+
+	var f safetyFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	index := make(map[string]int, len(rules))
+	for i, r := range rules {
+		index[r.Name] = i
+	}
+	seen := make(map[string]bool, len(f.Rules))
+	for n, fr := range f.Rules {
+		if fr.Name == "" {
+			return nil, fmt.Errorf("%s: rule %d: name is required", path, n+1)
+		}
+		if seen[fr.Name] {
+			return nil, fmt.Errorf("%s: rule %q is defined twice", path, fr.Name)
+		}
+		seen[fr.Name] = true
+
+		r := Rule{Name: fr.Name, Level: Destructive}
+		i, exists := index[fr.Name]
+		if exists {
+			if !overrides {
+				return nil, fmt.Errorf("%s: rule %q is already defined in %s; a repository's safety file can only add rules", path, fr.Name, rules[i].Source)
+			}
+			r = rules[i]
+		}
+		if fr.Pattern != "" {
+			r.Pattern = fr.Pattern
+		}
+		if fr.Exclude != "" {
+			r.Exclude = fr.Exclude
+		}
+		if fr.Reason != "" {
+			r.Reason = fr.Reason
+		}
+		if fr.Level != "" {
+			if r.Level, err = ParseLevel(fr.Level); err != nil {
+				return nil, fmt.Errorf("%s: rule %q: %w", path, fr.Name, err)
+			}
+		}
+		r.Source = path
+
+		if r.Pattern == "" {
+			return nil, fmt.Errorf("%s: rule %q: pattern is required", path, fr.Name)
+		}
+		if r.Reason == "" {
+			return nil, fmt.Errorf("%s: rule %q: reason is required", path, fr.Name)
+		}
+		if exists {
+			rules[i] = r
+		} else {
+			index[r.Name] = len(rules)
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+// RepoFile returns the nearest .shellbud/safety.yaml in dir or a parent,
+// skipping the user's own file at userPath, or "" when there is none.
+func RepoFile(dir, userPath string) string {
+	for {
+		path := filepath.Join(dir, ".shellbud", FileName)
+		if path != userPath {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,329 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuiltinRules(t *testing.T) {
+	seen := map[string]bool{}
+	for _, r := range Builtin() {
+		if r.Name == "" || r.Reason == "" || r.Source != BuiltinSource || r.Level == Safe {
+			t.Errorf("built-in rule %+v should have a name, a reason, the built-in source and a level above safe", r)
+		}
+		if seen[r.Name] {
+			t.Errorf("built-in rule %q is defined twice", r.Name)
+		}
+		seen[r.Name] = true
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		repo    string
+		command string
+		want    Level
+		wantErr string
+	}{
+		{
+			name:    "no files",
+			command: "rm file.txt",
+			want:    Destructive,
+		},
+		{
+			name: "user rule flags a command",
+			user: `rules:
+  - name: pulumi-destroy
+    pattern: '\bpulumi\s+destroy\b'
+    reason: destroys managed infrastructure
+`,
+			command: "pulumi destroy --yes",
+			want:    Destructive,
+		},
+		{
+			name: "user rule exclusion",
+			user: `rules:
+  - name: redis-flush
+    pattern: '\bredis-cli\b.*\bflushall\b'
+    exclude: '-h localhost'
+    reason: deletes every key
+`,
+			command: "redis-cli -h localhost flushall",
+			want:    Safe,
+		},
+		{
+			name: "user override un-flags a built-in",
+			user: `rules:
+  - name: killall
+    level: safe
+    reason: we restart dev servers this way
+`,
+			command: "killall node",
+			want:    Safe,
+		},
+		{
+			name: "un-flagged built-in leaves other rules in force",
+			user: `rules:
+  - name: killall
+    level: safe
+`,
+			command: "sudo killall node",
+			want:    Destructive,
+		},
+		{
+			name: "override narrows a built-in with an exclusion",
+			user: `rules:
+  - name: rm
+    exclude: '^rm -f \./build\.tar$'
+`,
+			command: "rm -f ./build.tar",
+			want:    Safe,
+		},
+		{
+			name: "repo rule flags a command",
+			repo: `rules:
+  - name: deploy-prod
+    pattern: '\bmake\s+deploy-prod\b'
+    reason: deploys to production
+`,
+			command: "make deploy-prod",
+			want:    Destructive,
+		},
+		{
+			name:    "empty file",
+			user:    "",
+			repo:    "# nothing yet\n",
+			command: "ls",
+			want:    Safe,
+		},
+		{
+			name: "repo cannot override a built-in",
+			repo: `rules:
+  - name: rm
+    level: safe
+`,
+			wantErr: `rule "rm" is already defined in built-in; a repository's safety file can only add rules`,
+		},
+		{
+			name: "repo cannot override a user rule",
+			user: `rules:
+  - name: tf
+    pattern: terraform
+    reason: infrastructure
+`,
+			repo: `rules:
+  - name: tf
+    level: safe
+`,
+			wantErr: "can only add rules",
+		},
+		{
+			name:    "missing name",
+			user:    "rules:\n  - pattern: x\n    reason: y\n",
+			wantErr: "rule 1: name is required",
+		},
+		{
+			name:    "missing pattern",
+			user:    "rules:\n  - name: x\n    reason: y\n",
+			wantErr: `rule "x": pattern is required`,
+		},
+		{
+			name:    "missing reason",
+			user:    "rules:\n  - name: x\n    pattern: y\n",
+			wantErr: `rule "x": reason is required`,
+		},
+		{
+			name:    "unknown level",
+			user:    "rules:\n  - name: x\n    pattern: y\n    reason: z\n    level: scary\n",
+			wantErr: `rule "x": level must be safe, caution, destructive, network or forbidden, got "scary"`,
+		},
+		{
+			name:    "duplicate name",
+			user:    "rules:\n  - name: x\n    pattern: y\n    reason: z\n  - name: x\n    pattern: y\n    reason: z\n",
+			wantErr: `rule "x" is defined twice`,
+		},
+		{
+			name:    "invalid pattern",
+			user:    "rules:\n  - name: x\n    pattern: '('\n    reason: z\n",
+			wantErr: `rule "x": invalid pattern`,
+		},
+		{
+			name:    "invalid exclude",
+			user:    "rules:\n  - name: x\n    pattern: y\n    exclude: '['\n    reason: z\n",
+			wantErr: `rule "x": invalid exclude`,
+		},
+		{
+			name:    "unknown field",
+			user:    "rules:\n  - name: x\n    patern: y\n",
+			wantErr: "field patern not found",
+		},
+		{
+			name:    "malformed yaml",
+			user:    "rules: [",
+			wantErr: "parsing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var userPath, repoPath string
+			if tt.user != "" || tt.name == "empty file" {
+				userPath = writeFile(t, filepath.Join(dir, "home", FileName), tt.user)
+			}
+			if tt.repo != "" {
+				repoPath = writeFile(t, filepath.Join(dir, "repo", FileName), tt.repo)
+			}
+
+			p, err := LoadPolicy(userPath, repoPath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadPolicy() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadPolicy() error: %v", err)
+			}
+			if got := p.Classify(tt.command); got != tt.want {
+				t.Errorf("Classify(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPolicyDisabledFamilies(t *testing.T) {
+	user := writeFile(t, filepath.Join(t.TempDir(), FileName), `rules:
+  - name: git-reset-hard
+    level: caution
+`)
+	p, err := LoadPolicy(user, "", "git", "cloud")
+	if err != nil {
+		t.Fatalf("LoadPolicy() error: %v", err)
+	}
+	for command, want := range map[string]Level{
+		"git reset --hard":       Safe, // an override does not bring a disabled family back
+		"git push -f":            Safe,
+		"terraform destroy":      Safe,
+		"kubectl delete pod web": Destructive,
+		"rm -rf build":           Destructive,
+	} {
+		if got := p.Classify(command); got != want {
+			t.Errorf("Classify(%q) = %v, want %v", command, got, want)
+		}
+	}
+
+	if _, err := LoadPolicy("", "", "gti"); err == nil || !strings.Contains(err.Error(), `unknown rule family "gti" (families: git, container,`) {
+		t.Errorf("LoadPolicy(gti) error = %v, want unknown family", err)
+	}
+}
+
+func TestLoadPolicyMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	p, err := LoadPolicy(filepath.Join(dir, "none.yaml"), filepath.Join(dir, "also-none.yaml"))
+	if err != nil {
+		t.Fatalf("LoadPolicy() error: %v", err)
+	}
+	if len(p.rules) != len(Builtin()) {
+		t.Errorf("policy has %d rules, want only the %d built-ins", len(p.rules), len(Builtin()))
+	}
+}
+
+func TestLoadPolicyUnreadable(t *testing.T) {
+	if _, err := LoadPolicy(t.TempDir(), ""); err == nil || !strings.Contains(err.Error(), "reading safety file") {
+		t.Errorf("LoadPolicy(directory) error = %v, want read error", err)
+	}
+}
+
+func TestPolicyMatched(t *testing.T) {
+	user := writeFile(t, filepath.Join(t.TempDir(), FileName), `rules:
+  - name: kill-9
+    reason: overridden reason
+  - name: xkill
+    pattern: '\bxkill\b'
+    reason: kills a window's client
+`)
+	p, err := LoadPolicy(user, "")
+	if err != nil {
+		t.Fatalf("LoadPolicy() error: %v", err)
+	}
+
+	matched := p.Evaluate("sudo xkill; kill -9 1").Matched
+	var names []string
+	for _, r := range matched {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, ","); got != "kill,sudo,kill-9,xkill" {
+		t.Fatalf("Matched = %s, want kill,sudo,kill-9,xkill in rule order", got)
+	}
+	if matched[2].Reason != "overridden reason" || matched[2].Source != user || matched[2].Pattern == "" {
+		t.Errorf("overridden rule = %+v, want the new reason and source with the built-in pattern", matched[2])
+	}
+	if matched[1].Source != BuiltinSource {
+		t.Errorf("sudo rule source = %q, want %q", matched[1].Source, BuiltinSource)
+	}
+	if p.Evaluate("ls").Matched != nil {
+		t.Error("ls should match nothing")
+	}
+}
+
+func TestUse(t *testing.T) {
+	defer Use(nil)
+	p, err := NewPolicy([]Rule{{Name: "ls", Pattern: `^ls\b`, Level: Destructive, Reason: "test"}})
+	if err != nil {
+		t.Fatalf("NewPolicy() error: %v", err)
+	}
+
+	Use(p)
+	if Classify("ls -la") != Destructive || Classify("rm x") != Safe {
+		t.Error("Classify() should use the policy passed to Use")
+	}
+	Use(nil)
+	if Classify("ls -la") != Safe || Classify("rm x") != Destructive {
+		t.Error("Use(nil) should restore the built-in rules")
+	}
+}
+
+func TestRepoFile(t *testing.T) {
+	root := t.TempDir()
+	home := filepath.Join(root, "home")
+	userPath := writeFile(t, filepath.Join(home, ".shellbud", FileName), "")
+	repoPath := writeFile(t, filepath.Join(home, "repo", ".shellbud", FileName), "")
+	deep := filepath.Join(home, "repo", "src", "pkg")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := RepoFile(deep, userPath); got != repoPath {
+		t.Errorf("RepoFile(deep) = %q, want %q", got, repoPath)
+	}
+	// The user's own file is not a repository's.
+	if got := RepoFile(home, userPath); got != "" {
+		t.Errorf("RepoFile(home) = %q, want none", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{Safe, Caution, Destructive, Network, Forbidden} {
+		if got, err := ParseLevel(l.String()); err != nil || got != l {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", l.String(), got, err, l)
+		}
+	}
+	if _, err := ParseLevel("unsafe"); err == nil {
+		t.Error("ParseLevel(unsafe) should fail")
+	}
+}
@@ -1,92 +1,241 @@
-// Package safety classifies shell commands as safe or destructive using regex
-// pattern matching. This is intentionally not LLM-based — safety checks must
-// be deterministic, fast, and independent of the model that generated the command.
+// Package safety classifies shell commands as safe, caution, destructive,
+// network or forbidden using regex pattern matching. This is intentionally not LLM-based
+// — safety checks must be deterministic, fast, and independent of the model
+// that generated the command.
+//
+// The built-in rules can be extended and overridden by the user's safety
+// file, and extended by a repository's (see LoadPolicy).
 package safety
 
 import (
-	"regexp"
-	"sync"
+	"fmt"
+	"slices"
+	"strings"
 )
 
-// Level represents the safety classification of a command.
+// Level represents the safety classification of a command. Levels are
+// ordered: a higher level needs more care.
 type Level int
 
 const (
+	// Safe commands run after the usual confirmation.
 	Safe Level = iota
+	// Caution commands change state in ways that are easy to undo or
+	// limited in scope. They are shown with their reasons.
+	Caution
+	// Destructive commands need an explicit confirmation.
 	Destructive
+	// Network commands run code fetched from elsewhere or send local data
+	// to another machine. They need an explicit confirmation, and are never
+	// run automatically: a poisoned page or commit message can talk the
+	// model into suggesting one.
+	Network
+	// Forbidden commands can wreck a machine beyond repair. They are never
+	// run unless the user passes --allow-forbidden, and never automatically.
+	Forbidden
 )
 
-// rule pairs a destructive pattern with an optional exclusion pattern.
-// A command that matches both pattern and exclude is not flagged by this rule.
-type rule struct {
-	pattern *regexp.Regexp
-	exclude *regexp.Regexp // nil means no exclusion
+// Rule is a named classification rule. A command matching Pattern, and not
+// Exclude, is at least Level.
+type Rule struct {
+	Name    string
+	Pattern string
+	// Exclude exempts commands that match it; empty means no exclusion.
+	Exclude string
+	Level   Level
+	// Reason tells the user why the rule exists.
+	Reason string
+	// Family groups built-in rules for one tool, such as git, so they can
+	// be turned off together. Empty for rules that are always on.
+	Family string
+	// Source is where the rule was defined: "built-in" or a file path.
+	Source string
 }
 
-var (
-	rules     []rule
-	rulesOnce sync.Once
-)
+// BuiltinSource is the Source of the rules ShellBud ships with.
+const BuiltinSource = "built-in"
 
-// rawRule holds the uncompiled pattern/exclusion strings.
-type rawRule struct {
-	pattern string
-	exclude string // empty means no exclusion
-}
+// builtinRules defines the rules ShellBud ships with.
+var builtinRules = []Rule{
+	{Name: "kill", Pattern: `\bkill\s`, Level: Caution, Reason: "sends a signal to a process"},
+	{Name: "pkill", Pattern: `\bpkill\s`, Level: Caution, Reason: "signals processes by name"},
+	{Name: "chmod-recursive", Pattern: `\bchmod\s+(-\S+\s+)*-\S*R`, Level: Caution, Reason: "changes permissions recursively"},
+	{Name: "package-remove", Pattern: `\b(apt|apt-get|yum|dnf|brew|pip3?|npm|gem)\s+(remove|uninstall|purge|autoremove)\b`, Level: Caution, Reason: "uninstalls packages"},
 
-// destructiveRules defines patterns for destructive commands.
-// exclude is checked after pattern matches — if exclude also matches, this rule is skipped.
-var destructiveRules = []rawRule{
-	{`\brm\s`, ""},
-	{`\brm$`, ""},
-	{`\bsudo\s`, ""},
-	{`\bdd\s+if=`, ""},
-	{`\bmkfs\b`, ""},
-	{`\bfdisk\b`, ""},
+	// "aws s3 rm" and "docker volume rm" are left to their families' rules.
+	{Name: "rm", Pattern: `\brm(\s|$)`, Exclude: toolRm, Level: Destructive, Reason: "deletes files"},
+	{Name: "rm-recursive", Pattern: `\brm\s+(-\S+\s+)*-\S*[rR]`, Exclude: toolRm, Level: Destructive, Reason: "recursive delete"},
+	{Name: "sudo", Pattern: `\bsudo\s`, Level: Destructive, Reason: "runs with root privileges"},
+	{Name: "dd", Pattern: `\bdd\s+if=`, Level: Destructive, Reason: "copies raw data and can overwrite disks"},
+	{Name: "fdisk", Pattern: `\bfdisk\b`, Level: Destructive, Reason: "edits disk partitions"},
 	// Redirections to /dev/ are destructive, but /dev/null, /dev/stdout, /dev/stderr are safe.
-	{`>+\s*/dev/`, `>+\s*/dev/(null|stdout|stderr)(\s|;|&|$)`},
-	{`\bchmod\s+000\b`, ""},
-	{`\bkill\s+-9\b`, ""},
-	{`\bkillall\s`, ""},
-	{`\bshutdown\b`, ""},
-	{`\breboot\b`, ""},
-	{`\bsystemctl\s+(stop|disable|mask)\b`, ""},
-	{`\bmv\s+/`, ""},
-	{`\bchown\s+-R\b`, ""},
-	{`:\s*>\s*\S`, ""}, // truncate via : > file
-	{`\btruncate\b`, ""},
-	{`\bshred\b`, ""},
+	{Name: "device-write", Pattern: `>+\s*/dev/`, Exclude: `>+\s*/dev/(null|stdout|stderr)(\s|;|&|$)`, Level: Destructive, Reason: "writes to a device"},
+	{Name: "chmod-000", Pattern: `\bchmod\s+000\b`, Level: Destructive, Reason: "removes all permissions"},
+	{Name: "kill-9", Pattern: `\bkill\s+-9\b`, Level: Destructive, Reason: "force-kills a process"},
+	{Name: "killall", Pattern: `\bkillall\s`, Level: Destructive, Reason: "kills processes by name"},
+	{Name: "systemctl-stop", Pattern: `\bsystemctl\s+(stop|disable|mask)\b`, Level: Destructive, Reason: "stops or disables a service"},
+	{Name: "mv-absolute", Pattern: `\bmv\s+/`, Level: Destructive, Reason: "moves files by absolute path"},
+	{Name: "chown-recursive", Pattern: `\bchown\s+-R\b`, Level: Destructive, Reason: "changes ownership recursively"},
+	{Name: "colon-truncate", Pattern: `:\s*>\s*\S`, Level: Destructive, Reason: "empties a file (: > file)"},
+	{Name: "truncate", Pattern: `\btruncate\b`, Level: Destructive, Reason: "truncates a file"},
+	{Name: "shred", Pattern: `\bshred\b`, Level: Destructive, Reason: "overwrites files beyond recovery"},
+
+	// Tool families. Exclusions skip the tools' own dry-run flags.
+	{Name: "git-reset-hard", Pattern: gitCommand + `reset\b[^|;&]*\s--hard\b`, Level: Destructive, Reason: "discards uncommitted changes", Family: "git"},
+	{Name: "git-push-force", Pattern: gitCommand + `push\b[^|;&]*\s(--force(\s|=|$)|-[a-zA-Z]*f[a-zA-Z]*(\s|$)|\+\S)`, Exclude: `\s(--dry-run|-n)(\s|$)`, Level: Destructive, Reason: "overwrites the remote branch", Family: "git"},
+	{Name: "git-push-force-with-lease", Pattern: gitCommand + `push\b[^|;&]*\s--force-with-lease\b`, Exclude: `\s(--dry-run|-n)(\s|$)`, Level: Caution, Reason: "overwrites the remote branch if nobody else pushed", Family: "git"},
+	{Name: "git-clean", Pattern: gitCommand + `clean\b[^|;&]*\s(-[a-zA-Z]*f|--force\b)`, Exclude: `\s(--dry-run|-[a-zA-Z]*n[a-zA-Z]*)(\s|$)`, Level: Destructive, Reason: "deletes untracked files", Family: "git"},
+	{Name: "git-branch-delete", Pattern: gitCommand + `branch\b[^|;&]*\s(-[a-zA-Z]*D\b|(-d|--delete)\s+(-f|--force)\b|(-f|--force)\s+(-d|--delete)\b)`, Level: Destructive, Reason: "deletes a branch even if it is not merged", Family: "git"},
+	{Name: "docker-prune", Pattern: `\b(docker|podman)\s+((system|volume)\s+prune\b|\S+\s+prune\b[^|;&]*\s(-a|--all)\b)`, Level: Destructive, Reason: "deletes unused containers, images or volumes", Family: "container"},
+	{Name: "docker-volume-rm", Pattern: `\b(docker|podman)\s+volume\s+(rm|remove)\b`, Level: Destructive, Reason: "deletes a volume and its data", Family: "container"},
+	{Name: "compose-down-volumes", Pattern: `\b(docker[\s-]compose|podman-compose)\b[^|;&]*\sdown\b[^|;&]*\s(-v|--volumes)\b`, Level: Destructive, Reason: "deletes the project's volumes", Family: "container"},
+	{Name: "kubectl-delete", Pattern: `\bkubectl\b[^|;&]*\sdelete\b`, Exclude: `\s--dry-run(=client|=server)?(\s|$)`, Level: Destructive, Reason: "deletes cluster resources", Family: "kubernetes"},
+	{Name: "helm-uninstall", Pattern: `\bhelm\b[^|;&]*\s(uninstall|delete|del|un)\b`, Exclude: `\s--dry-run\b`, Level: Destructive, Reason: "removes a release from the cluster", Family: "kubernetes"},
+	{Name: "terraform-destroy", Pattern: `\b(terraform|tofu)\b[^|;&]*\s(destroy\b|apply\b[^|;&]*\s-destroy\b)`, Level: Destructive, Reason: "destroys managed infrastructure", Family: "cloud"},
+	{Name: "aws-s3-delete", Pattern: `\baws\b[^|;&]*\ss3\s+((rm|rb)\b|sync\b[^|;&]*\s--delete\b)`, Exclude: `\s--dryrun\b`, Level: Destructive, Reason: "deletes objects or buckets in S3", Family: "cloud"},
+	{Name: "aws-delete", Pattern: `\baws\b[^|;&]*\s(delete-\S+|terminate-instances)\b`, Exclude: `\s--dry-run\b`, Level: Destructive, Reason: "deletes cloud resources", Family: "cloud"},
+	{Name: "gcloud-az-delete", Pattern: `\b(gcloud|az)\b[^|;&]*\sdelete\b`, Level: Destructive, Reason: "deletes cloud resources", Family: "cloud"},
+	{Name: "sql-drop", Pattern: `(?i)\b(drop\s+(table|database|schema|view|index|user|role)|truncate\s+table)\b`, Level: Destructive, Reason: "drops database objects", Family: "database"},
+	{Name: "sql-delete-all", Pattern: `(?i)\bdelete\s+from\s+[\w."]+\s*(;|"|'|$)`, Level: Destructive, Reason: "deletes every row of a table", Family: "database"},
+	{Name: "dropdb", Pattern: `\b(dropdb|dropuser)\b`, Level: Destructive, Reason: "drops a database or role", Family: "database"},
+
+	// Remote code execution and exfiltration.
+	{Name: "remote-script", Pattern: `\b(curl|wget|fetch)\b[^|]*\|([^|]+\|)*\s*` + interpreter, Level: Network, Reason: "runs a script downloaded from the network"},
+	{Name: "remote-script-substitution", Pattern: `(\b(` + interpreterNames + `|eval|source)|(^|[\s;&|(])\.)\s+(-\S+\s+)*["']?(\$\(|<\(|` + "`" + `)\s*(curl|wget|fetch)\b`, Level: Network, Reason: "runs a script downloaded from the network"},
+	{Name: "decoded-script", Pattern: `\b(base64|openssl\s+(base64|enc))\b[^|]*\s(-d|-D|--decode)\b[^|]*\|\s*` + interpreter, Level: Network, Reason: "runs an encoded script"},
+	{Name: "netcat-exec", Pattern: `\b(nc|ncat|netcat)(\s[^|;&]*)?\s-[a-zA-Z]*[ec](\s|$)`, Level: Network, Reason: "serves a shell over the network"},
+	{Name: "dev-tcp", Pattern: `/dev/(tcp|udp)/`, Level: Network, Reason: "opens a raw network connection"},
+	{Name: "upload-file", Pattern: `\bcurl\s[^|;&]*(-T|--upload-file)(\s|=)`, Level: Network, Reason: "uploads a local file"},
+	{Name: "post-file", Pattern: `\bcurl\s[^|;&]*(-d|--data|--data-binary|--data-urlencode|-F|--form)(\s+|=)?["']?([^\s"'=@]*=)?@`, Level: Network, Reason: "sends a local file"},
+	{Name: "wget-post-file", Pattern: `\bwget\s[^|;&]*--(post|body)-file\b`, Level: Network, Reason: "sends a local file"},
+	{Name: "copy-secrets", Pattern: `\b(scp|rsync|sftp)\s[^|;&]*(\.ssh|\.aws|\.gnupg|\.kube|\.netrc|\.docker/config)\b`, Level: Network, Reason: "copies credentials to another machine"},
+
+	// Commands that can wreck a machine beyond repair: wiping the root or
+	// home directory, formatting or overwriting a disk, fork bombs, and
+	// powering the machine off.
+	{Name: "rm-root", Pattern: `\brm\s+(-\S+\s+)*(/|/\*|~/?|\$HOME/?)(\s|;|&|$)`, Level: Forbidden, Reason: "deletes the root or home directory"},
+	{Name: "mkfs", Pattern: `\bmkfs(\.\w+)?\b`, Level: Forbidden, Reason: "formats a filesystem"},
+	{Name: "dd-device", Pattern: `\bdd\s+.*\bof=/dev/`, Exclude: `\bof=/dev/null\b`, Level: Forbidden, Reason: "writes raw data to a device"},
+	{Name: "block-device-write", Pattern: `>+\s*/dev/(sd|hd|vd|xvd|nvme|disk|mmcblk)`, Level: Forbidden, Reason: "writes to a block device"},
+	{Name: "fork-bomb", Pattern: `:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`, Level: Forbidden, Reason: "fork bomb"},
+	{Name: "shutdown", Pattern: `\b(shutdown|halt|poweroff)\b`, Level: Forbidden, Reason: "shuts the machine down"},
+	{Name: "reboot", Pattern: `\breboot\b`, Level: Forbidden, Reason: "reboots the machine"},
+	{Name: "chmod-root", Pattern: `\bchmod\s+(-\S+\s+)*[0-7]+\s+/(\s|;|&|$)`, Level: Forbidden, Reason: "changes the permissions of /"},
+	{Name: "chown-root", Pattern: `\bchown\s+(-\S+\s+)*\S+\s+/(\s|;|&|$)`, Level: Forbidden, Reason: "changes the owner of /"},
 }
 
-func compileRules() {
-	rulesOnce.Do(func() {
-		rules = make([]rule, len(destructiveRules))
-		for i, r := range destructiveRules {
-			rules[i].pattern = regexp.MustCompile(r.pattern)
-			if r.exclude != "" {
-				rules[i].exclude = regexp.MustCompile(r.exclude)
-			}
+// interpreterNames are the shells and languages that can run a script read
+// from standard input or a file. interpreter matches one reading standard
+// input, possibly by path or under sudo or env. A language counts only
+// without arguments other than -, so `| python3 -m json.tool` is not flagged.
+const (
+	interpreterNames = `(ba|z|k|da|fi|c|tc)?sh|python[0-9.]*|perl|ruby|node|php`
+	interpreter      = `(sudo\s+(-\S+\s+)*)?((\S*/)?env\s+)?(\S*/)?((ba|z|k|da|fi|c|tc)?sh\b|(python[0-9.]*|perl|ruby|node|php)(\s+-)?\s*($|[;&|)]))`
+)
+
+// toolRm matches the rm subcommands of tools covered by a rule family.
+const toolRm = `\b(s3|volume)\s+rm\b`
+
+// gitCommand matches git with any global options, up to its subcommand.
+const gitCommand = `\bgit\s+((-C|-c|--git-dir|--work-tree)\s+\S+\s+|-\S+\s+)*`
+
+// Builtin returns the built-in rules.
+func Builtin() []Rule {
+	rules := make([]Rule, len(builtinRules))
+	for i, r := range builtinRules {
+		r.Source = BuiltinSource
+		rules[i] = r
+	}
+	return rules
+}
+
+// Families returns the names of the built-in rule families, in rule order.
+func Families() []string {
+	var families []string
+	for _, r := range builtinRules {
+		if r.Family != "" && !slices.Contains(families, r.Family) {
+			families = append(families, r.Family)
 		}
-	})
+	}
+	return families
 }
 
-// Classify examines a shell command and returns its safety level.
-func Classify(command string) Level {
-	compileRules()
-	for _, r := range rules {
-		if r.pattern.MatchString(command) {
-			if r.exclude != nil && r.exclude.MatchString(command) {
-				continue
+// Verdict is a command's classification with the rules behind it.
+type Verdict struct {
+	Level Level
+	// Matched lists every rule the command or one of its segments matches,
+	// in policy order.
+	Matched []Rule
+	// Segments classifies each simple command of a command line (see Split).
+	// It is nil when the command has only one.
+	Segments []Segment
+}
+
+// Reasons returns the reasons of the matched rules that flag the command,
+// strictest first, without repeats.
+func (v Verdict) Reasons() []string {
+	var reasons []string
+	seen := make(map[string]bool)
+	for level := v.Level; level > Safe; level-- {
+		for _, r := range v.Matched {
+			if r.Level == level && !seen[r.Reason] {
+				seen[r.Reason] = true
+				reasons = append(reasons, r.Reason)
 			}
-			return Destructive
 		}
 	}
-	return Safe
+	return reasons
+}
+
+// Warning is the line shown above a flagged command: its level and the
+// reasons for it. It returns "" for a safe command.
+func (v Verdict) Warning() string {
+	reasons := strings.Join(v.Reasons(), "; ")
+	switch v.Level {
+	case Caution:
+		return "Caution: " + reasons + "."
+	case Destructive:
+		return "Warning: this is a destructive command (" + reasons + ")."
+	case Network:
+		return "Warning: this command can run remote code or send data away (" + reasons + ")."
+	case Forbidden:
+		return "Forbidden: " + reasons + "."
+	}
+	return ""
+}
+
+// Classify examines a shell command and returns its safety level under the
+// policy in use (see Use).
+func Classify(command string) Level {
+	return Current().Classify(command)
+}
+
+// Evaluate classifies command under the policy in use and returns the rules
+// it matches.
+func Evaluate(command string) Verdict {
+	return Current().Evaluate(command)
 }
 
 func (l Level) String() string {
-	if l == Destructive {
+	switch l {
+	case Caution:
+		return "caution"
+	case Destructive:
 		return "destructive"
+	case Network:
+		return "network"
+	case Forbidden:
+		return "forbidden"
 	}
 	return "safe"
 }
+
+// ParseLevel parses a level name as written in a safety file.
+func ParseLevel(s string) (Level, error) {
+	for l := Safe; l <= Forbidden; l++ {
+		if s == l.String() {
+			return l, nil
+		}
+	}
+	return Safe, fmt.Errorf("level must be safe, caution, destructive, network or forbidden, got %q", s)
+}
@@ -0,0 +1,264 @@
+package safety
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Segment is one simple command within a command line, such as a pipeline
+// stage, a command after && or ;, or the body of a command substitution,
+// with its own classification.
+type Segment struct {
+	Text string
+	Verdict
+}
+
+func (s Segment) String() string {
+	if reasons := s.Reasons(); len(reasons) > 0 {
+		return fmt.Sprintf("%s: %s (%s)", s.Level, s.Text, strings.Join(reasons, "; "))
+	}
+	return fmt.Sprintf("%s: %s", s.Level, s.Text)
+}
+
+// Flagged returns the segments of the command that are not safe on their
+// own. It is empty for a command with a single segment.
+func (v Verdict) Flagged() []Segment {
+	var flagged []Segment
+	for _, s := range v.Segments {
+		if s.Level > Safe {
+			flagged = append(flagged, s)
+		}
+	}
+	return flagged
+}
+
+// Split breaks a command line into the simple commands it runs. It splits
+// on ;, &, &&, ||, |, |&, newlines and subshell parentheses outside quotes,
+// and adds the contents of $(...), `...`, <(...) and >(...) as segments of
+// their own, after the segment that contains them. Here-document bodies are
+// data for their command and are left out.
+//
+// Split is not a shell parser: it is a best-effort view for showing which
+// part of a command line is risky. Classification still matches the rules
+// against the whole line, so anything Split gets wrong fails closed.
+func Split(command string) []string {
+	var (
+		segments []string
+		nested   []string // segments of substitutions in the current segment
+		cur      strings.Builder
+		heredocs []heredoc // here-documents whose bodies follow the next newline
+		depth    int       // subshell parentheses
+	)
+	sub := func(text string) {
+		nested = append(nested, Split(text)...)
+	}
+	flush := func() {
+		if text := strings.TrimSpace(cur.String()); text != "" {
+			segments = append(segments, text)
+		}
+		segments = append(segments, nested...)
+		cur.Reset()
+		nested = nil
+	}
+
+	s := command
+	for i := 0; i < len(s); {
+		c := s[i]
+		rest := s[i:]
+		switch {
+		case strings.HasPrefix(rest, "\\\n"):
+			i += 2 // line continuation
+		case c == '\\':
+			end := min(i+2, len(s))
+			cur.WriteString(s[i:end])
+			i = end
+		case c == '\'' || c == '"' || c == '`' || strings.HasPrefix(rest, "$(") ||
+			strings.HasPrefix(rest, "<(") || strings.HasPrefix(rest, ">("):
+			end := skipQuoted(s, i, sub)
+			cur.WriteString(s[i:end])
+			i = end
+		case c == '#' && atWordStart(&cur):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			cur.WriteString(rest[:end])
+			i += end
+		case strings.HasPrefix(rest, "<<") && !strings.HasPrefix(rest, "<<<"):
+			h, end := readHeredoc(s, i+2)
+			if h.delim != "" {
+				heredocs = append(heredocs, h)
+			}
+			cur.WriteString(s[i:end])
+			i = end
+		case c == '\n':
+			i++
+			for _, h := range heredocs {
+				i = h.skipBody(s, i)
+			}
+			heredocs = nil
+			flush()
+		case strings.HasPrefix(rest, "&&") || strings.HasPrefix(rest, "||") || strings.HasPrefix(rest, "|&"):
+			flush()
+			i += 2
+		case c == ';' || c == '|':
+			flush()
+			i++
+		case c == '&' && !isRedirect(&cur, s, i):
+			flush()
+			i++
+		case c == '(' && atWordStart(&cur):
+			depth++
+			flush()
+			i++
+		case c == ')' && depth > 0:
+			depth--
+			flush()
+			i++
+		default:
+			cur.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return segments
+}
+
+// skipQuoted returns the index just past the quoted string, backquote or
+// $(...), $((...)), <(...) or >(...) starting at s[i], passing the text of
+// each command substitution it finds to sub. sub may be nil. Unterminated
+// constructs run to the end of s.
+func skipQuoted(s string, i int, sub func(string)) int {
+	switch s[i] {
+	case '\'':
+		if end := strings.IndexByte(s[i+1:], '\''); end >= 0 {
+			return i + 1 + end + 1
+		}
+		return len(s)
+	case '"':
+		for j := i + 1; j < len(s); {
+			switch {
+			case s[j] == '\\':
+				j += 2
+			case s[j] == '"':
+				return j + 1
+			case s[j] == '`' || strings.HasPrefix(s[j:], "$("):
+				j = skipQuoted(s, j, sub)
+			default:
+				j++
+			}
+		}
+		return len(s)
+	case '`':
+		for j := i + 1; j < len(s); j++ {
+			switch s[j] {
+			case '\\':
+				j++
+			case '`':
+				if sub != nil {
+					sub(s[i+1 : j])
+				}
+				return j + 1
+			}
+		}
+		if sub != nil {
+			sub(s[i+1:])
+		}
+		return len(s)
+	}
+
+	// $(...), <(...) or >(...); $((...)) is arithmetic, not a command.
+	arithmetic := strings.HasPrefix(s[i:], "$((")
+	start := i + 2
+	depth := 1
+	for j := start; j < len(s); {
+		switch s[j] {
+		case '\\':
+			j += 2
+		case '\'', '"', '`':
+			// Substitutions nested in this one are found when its text is split.
+			j = skipQuoted(s, j, nil)
+		case '(':
+			depth++
+			j++
+		case ')':
+			depth--
+			if depth == 0 {
+				if sub != nil && !arithmetic {
+					sub(s[start:j])
+				}
+				return j + 1
+			}
+			j++
+		default:
+			j++
+		}
+	}
+	if sub != nil && !arithmetic {
+		sub(s[start:])
+	}
+	return len(s)
+}
+
+// heredoc is a pending here-document.
+type heredoc struct {
+	delim string
+	// tabs reports <<-, which strips leading tabs from the body's lines.
+	tabs bool
+}
+
+// readHeredoc reads the delimiter word after << at s[i:], returning the
+// here-document and the index just past the word.
+func readHeredoc(s string, i int) (heredoc, int) {
+	var h heredoc
+	if i < len(s) && s[i] == '-' {
+		h.tabs = true
+		i++
+	}
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+		i++
+	}
+	start := i
+	for i < len(s) && !strings.ContainsRune(" \t\n;&|<>()", rune(s[i])) {
+		if s[i] == '\'' || s[i] == '"' {
+			i = skipQuoted(s, i, nil)
+			continue
+		}
+		i++
+	}
+	// Quoting the delimiter only changes how the body is expanded.
+	h.delim = strings.NewReplacer(`'`, "", `"`, "", `\`, "").Replace(s[start:i])
+	return h, i
+}
+
+// skipBody returns the index just past the body of h starting at s[i], which
+// ends with a line holding only the delimiter.
+func (h heredoc) skipBody(s string, i int) int {
+	for i < len(s) {
+		line, _, _ := strings.Cut(s[i:], "\n")
+		i += len(line) + 1
+		if h.tabs {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == h.delim {
+			break
+		}
+	}
+	return min(i, len(s))
+}
+
+// atWordStart reports whether the next byte starts a new word.
+func atWordStart(cur *strings.Builder) bool {
+	text := cur.String()
+	return text == "" || strings.ContainsRune(" \t", rune(text[len(text)-1]))
+}
+
+// isRedirect reports whether the & at s[i] is part of a redirection such as
+// 2>&1, >&2 or &>file rather than a separator.
+func isRedirect(cur *strings.Builder, s string, i int) bool {
+	text := cur.String()
+	if text != "" && (text[len(text)-1] == '>' || text[len(text)-1] == '<') {
+		return true
+	}
+	return i+1 < len(s) && s[i+1] == '>'
+}
@@ -0,0 +1,130 @@
+package safety
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+	}{
+		{"simple", "ls -la", []string{"ls -la"}},
+		{"empty", "  ", nil},
+		{"list", "make && make test || echo failed; ls", []string{"make", "make test", "echo failed", "ls"}},
+		{"pipeline", "du -sh * | sort -rh |& head", []string{"du -sh *", "sort -rh", "head"}},
+		{"newlines", "cd build\nrm -rf out\n", []string{"cd build", "rm -rf out"}},
+		{"line continuation", "rm -rf \\\n  build", []string{"rm -rf   build"}},
+		{"background", "sleep 5 & rm x", []string{"sleep 5", "rm x"}},
+		{"redirections are not separators", "make 2>&1 >&2 &>log", []string{"make 2>&1 >&2 &>log"}},
+		{"single quotes", "echo 'a; b | c'", []string{"echo 'a; b | c'"}},
+		{"double quotes", `echo "a && b"; ls`, []string{`echo "a && b"`, "ls"}},
+		{"escaped separator", `echo a \; b`, []string{`echo a \; b`}},
+		{"command substitution", "echo $(rm -rf x; ls) done", []string{"echo $(rm -rf x; ls) done", "rm -rf x", "ls"}},
+		{"nested substitution", "a $(b $(c))", []string{"a $(b $(c))", "b $(c)", "c"}},
+		{"substitution in double quotes", `echo "now $(date | tr a b)"`, []string{`echo "now $(date | tr a b)"`, "date", "tr a b"}},
+		{"quoted parenthesis in substitution", `echo $(printf ')'; rm x)`, []string{`echo $(printf ')'; rm x)`, `printf ')'`, "rm x"}},
+		{"backquotes", "echo `whoami`; ls", []string{"echo `whoami`", "whoami", "ls"}},
+		{"arithmetic is not a command", "echo $((1 + (2 * 3)))", []string{"echo $((1 + (2 * 3)))"}},
+		{"process substitution", "diff <(ls a) >(cat)", []string{"diff <(ls a) >(cat)", "ls a", "cat"}},
+		{"subshell", "(cd build && rm -rf out); ls", []string{"cd build", "rm -rf out", "ls"}},
+		{"parenthesis inside a word", "arr=(a b)", []string{"arr=(a b)"}},
+		{"comment", "ls # rm -rf / ; reboot\npwd", []string{"ls # rm -rf / ; reboot", "pwd"}},
+		{"here-document", "cat <<EOF > notes\nrm -rf /; x | y\nEOF\nls", []string{"cat <<EOF > notes", "ls"}},
+		{"quoted here-document delimiter", "cat <<'END'\n$(rm x)\nEND\npwd", []string{"cat <<'END'", "pwd"}},
+		{"tab-stripped here-document", "cat <<-EOF\n\tbody\n\tEOF\npwd", []string{"cat <<-EOF", "pwd"}},
+		{"here-string", "grep x <<< 'a; b'", []string{"grep x <<< 'a; b'"}},
+		{"unterminated quote", `echo "a; b`, []string{`echo "a; b`}},
+		{"unterminated substitution", "echo $(rm x; ls", []string{"echo $(rm x; ls", "rm x", "ls"}},
+		{"unterminated backquote", "echo `rm x", []string{"echo `rm x", "rm x"}},
+		{"unterminated here-document", "cat <<EOF\nbody", []string{"cat <<EOF"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Split(tt.command); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateSegments(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		wantLevel   Level
+		wantFlagged []string
+	}{
+		{
+			name:        "flags the risky stage",
+			command:     "cd build && rm -rf out | tee log; ls",
+			wantLevel:   Destructive,
+			wantFlagged: []string{"destructive: rm -rf out (deletes files; recursive delete)"},
+		},
+		{
+			name:        "flags inside a substitution",
+			command:     "echo $(rm -rf /)",
+			wantLevel:   Forbidden,
+			wantFlagged: []string{"destructive: echo $(rm -rf /) (deletes files; recursive delete)", "forbidden: rm -rf / (deletes the root or home directory; deletes files; recursive delete)"},
+		},
+		{
+			name:      "whole-string pass still applies",
+			command:   ":(){ :|:& };:",
+			wantLevel: Forbidden,
+		},
+		{
+			name:      "safe list",
+			command:   "git status && git log",
+			wantLevel: Safe,
+		},
+		{
+			name:      "here-document body is still classified",
+			command:   "bash <<EOF\nshred secrets\nEOF",
+			wantLevel: Destructive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := Evaluate(tt.command)
+			if v.Level != tt.wantLevel {
+				t.Errorf("Evaluate(%q).Level = %v, want %v", tt.command, v.Level, tt.wantLevel)
+			}
+			var flagged []string
+			for _, s := range v.Flagged() {
+				flagged = append(flagged, s.String())
+			}
+			if !reflect.DeepEqual(flagged, tt.wantFlagged) {
+				t.Errorf("Flagged() = %q, want %q", flagged, tt.wantFlagged)
+			}
+		})
+	}
+}
+
+func TestEvaluateSegmentRules(t *testing.T) {
+	// An anchored rule matches a later command of a list only through its
+	// segment.
+	p, err := NewPolicy([]Rule{{Name: "push", Pattern: `^git push\b`, Level: Caution, Reason: "publishes commits"}})
+	if err != nil {
+		t.Fatalf("NewPolicy() error: %v", err)
+	}
+
+	v := p.Evaluate("git commit -am wip && git push")
+	if v.Level != Caution || len(v.Matched) != 1 || v.Matched[0].Name != "push" {
+		t.Errorf("Evaluate() = %+v, want caution from the push rule", v)
+	}
+	if len(v.Segments) != 2 || v.Segments[1].Level != Caution || v.Segments[0].Level != Safe {
+		t.Errorf("Segments = %+v, want the second one flagged", v.Segments)
+	}
+	if got := v.Segments[0].String(); got != "safe: git commit -am wip" {
+		t.Errorf("Segment.String() = %q", got)
+	}
+	if v := p.Evaluate("git push"); v.Segments != nil || v.Level != Caution {
+		t.Errorf("single command Evaluate() = %+v, want no segments", v)
+	}
+	if !strings.Contains(Evaluate("ls | rm x").Flagged()[0].Text, "rm x") {
+		t.Error("Flagged() should return the rm segment")
+	}
+}
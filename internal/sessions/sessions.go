@@ -0,0 +1,387 @@
+// Package sessions persists chat sessions under ~/.shellbud/sessions so a
+// conversation survives exit and can be resumed with `sb chat --resume`.
+//
+// Each session is one JSON file, rewritten atomically (temp file + rename)
+// after every turn, so readers such as `sb sessions list` never see a partial
+// file and need no lock. Writers take an exclusive flock on a sibling .lock
+// file: a second terminal resuming the same session fails fast instead of
+// interleaving writes.
+package sessions
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hpkotak/shellbud/internal/config"
+	"github.com/hpkotak/shellbud/internal/conversation"
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+var (
+	// ErrNotFound means no session matches the given ID.
+	ErrNotFound = errors.New("session not found")
+	// ErrLocked means another sb process has the session open.
+	ErrLocked = errors.New("session is in use by another sb process")
+)
+
+// now is the clock used for timestamps. Tests override it.
+var now = time.Now
+
+// validID keeps IDs to a single path element.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Session is the persisted state of one chat.
+type Session struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// CWD is the working directory the session was started in.
+	CWD       string    `json:"cwd"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Messages  []Message `json:"messages"`
+	Commands  []Command `json:"commands,omitempty"`
+	// Summaries records each rolling summary generation, oldest first.
+	Summaries []Summary `json:"summaries,omitempty"`
+}
+
+// Message is one conversation turn.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// FollowUp marks a message ShellBud sent on the user's behalf.
+	FollowUp bool `json:"follow_up,omitempty"`
+}
+
+// Command is a command the user ran from the chat.
+type Command struct {
+	Command  string    `json:"command"`
+	ExitCode int       `json:"exit_code"`
+	CWD      string    `json:"cwd"`
+	RanAt    time.Time `json:"ran_at"`
+}
+
+// Summary is a rolling summary of the oldest messages.
+type Summary struct {
+	Text string `json:"text"`
+	// Covers is how many leading messages the summary replaces.
+	Covers       int `json:"covers"`
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+}
+
+// History returns the messages as provider messages.
+func (s *Session) History() []provider.Message {
+	msgs := make([]provider.Message, len(s.Messages))
+	for i, m := range s.Messages {
+		msgs[i] = provider.Message{Role: m.Role, Content: m.Content, FollowUp: m.FollowUp}
+	}
+	return msgs
+}
+
+// SetHistory replaces the messages.
+func (s *Session) SetHistory(msgs []provider.Message) {
+	s.Messages = make([]Message, len(msgs))
+	for i, m := range msgs {
+		s.Messages[i] = Message{Role: m.Role, Content: m.Content, FollowUp: m.FollowUp}
+	}
+}
+
+// AddSummary records a summary generation.
+func (s *Session) AddSummary(sum conversation.Summary) {
+	s.Summaries = append(s.Summaries, Summary{
+		Text:         sum.Text,
+		Covers:       sum.Covers,
+		InputTokens:  sum.Usage.InputTokens,
+		OutputTokens: sum.Usage.OutputTokens,
+	})
+}
+
+// Summary returns the latest summary, or the zero Summary when there is none.
+func (s *Session) Summary() conversation.Summary {
+	if len(s.Summaries) == 0 {
+		return conversation.Summary{}
+	}
+	last := s.Summaries[len(s.Summaries)-1]
+	return conversation.Summary{
+		Text:   last.Text,
+		Covers: last.Covers,
+		Usage:  provider.Usage{InputTokens: last.InputTokens, OutputTokens: last.OutputTokens},
+	}
+}
+
+// Dir returns the sessions directory path (~/.shellbud/sessions).
+func Dir() string {
+	return filepath.Join(config.Dir(), "sessions")
+}
+
+// Store reads and writes sessions in a directory.
+type Store struct {
+	dir string
+}
+
+// NewStore returns a Store for dir, usually Dir().
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Handle is a session opened for writing. It holds the session's lock until
+// Close.
+type Handle struct {
+	Session *Session
+	path    string
+	lock    *os.File
+	saved   bool
+}
+
+// Create starts a new session and locks it. Nothing is written until the
+// first Save, so a chat that is quit straight away leaves no session behind.
+func (st *Store) Create(providerName, model, cwd string) (*Handle, error) {
+	if err := os.MkdirAll(st.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating sessions dir: %w", err)
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := st.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	t := now()
+	return &Handle{
+		Session: &Session{
+			ID:        id,
+			Provider:  providerName,
+			Model:     model,
+			CWD:       cwd,
+			CreatedAt: t,
+			UpdatedAt: t,
+		},
+		path: st.path(id),
+		lock: lock,
+	}, nil
+}
+
+// Open locks and loads an existing session for writing. id may be a unique
+// prefix of a session ID.
+func (st *Store) Open(id string) (*Handle, error) {
+	id, err := st.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := st.lock(id)
+	if err != nil {
+		return nil, err
+	}
+	// Load after locking: the session may have been removed meanwhile.
+	s, err := st.load(id)
+	if err != nil {
+		_ = lock.Close()
+		return nil, err
+	}
+	return &Handle{Session: s, path: st.path(id), lock: lock, saved: true}, nil
+}
+
+// Load reads a session without locking it. id may be a unique prefix.
+func (st *Store) Load(id string) (*Session, error) {
+	id, err := st.resolve(id)
+	if err != nil {
+		return nil, err
+	}
+	return st.load(id)
+}
+
+// List returns all sessions, most recently updated first. Unreadable files
+// are skipped so one corrupt session cannot hide the rest.
+func (st *Store) List() ([]*Session, error) {
+	ids, err := st.ids()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]*Session, 0, len(ids))
+	for _, id := range ids {
+		s, err := st.load(id)
+		if err != nil {
+			continue
+		}
+		list = append(list, s)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].UpdatedAt.After(list[j].UpdatedAt)
+	})
+	return list, nil
+}
+
+// Latest returns the ID of the most recently updated session.
+func (st *Store) Latest() (string, error) {
+	list, err := st.List()
+	if err != nil {
+		return "", err
+	}
+	if len(list) == 0 {
+		return "", ErrNotFound
+	}
+	return list[0].ID, nil
+}
+
+// Remove deletes a session. It fails with ErrLocked while the session is open
+// in another process. id may be a unique prefix. It returns the full ID.
+func (st *Store) Remove(id string) (string, error) {
+	id, err := st.resolve(id)
+	if err != nil {
+		return "", err
+	}
+	lock, err := st.lock(id)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = lock.Close() }()
+
+	if err := os.Remove(st.path(id)); err != nil {
+		return "", fmt.Errorf("removing session: %w", err)
+	}
+	_ = os.Remove(st.lockPath(id))
+	return id, nil
+}
+
+// Save writes the session to disk atomically and updates UpdatedAt.
+func (h *Handle) Save() error {
+	h.Session.UpdatedAt = now()
+	data, err := json.MarshalIndent(h.Session, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding session: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(h.path), ".session-*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing session: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), h.path); err != nil {
+		return fmt.Errorf("writing session: %w", err)
+	}
+	h.saved = true
+	return nil
+}
+
+// Close releases the session lock. A session that was never saved leaves no
+// files behind.
+func (h *Handle) Close() error {
+	if !h.saved {
+		_ = os.Remove(h.lock.Name())
+	}
+	return h.lock.Close()
+}
+
+// lock takes the exclusive, non-blocking lock for id. The lock is released
+// when the returned file is closed, including when the process dies.
+func (st *Store) lock(id string) (*os.File, error) {
+	f, err := os.OpenFile(st.lockPath(id), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("locking session: %w", err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("%s: %w", id, ErrLocked)
+		}
+		return nil, fmt.Errorf("locking session: %w", err)
+	}
+	return f, nil
+}
+
+func (st *Store) load(id string) (*Session, error) {
+	data, err := os.ReadFile(st.path(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%s: %w", id, ErrNotFound)
+		}
+		return nil, fmt.Errorf("reading session: %w", err)
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// resolve maps id, or a unique prefix of one, to a stored session ID.
+func (st *Store) resolve(id string) (string, error) {
+	if !validID.MatchString(id) {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	if _, err := os.Stat(st.path(id)); err == nil {
+		return id, nil
+	}
+	ids, err := st.ids()
+	if err != nil {
+		return "", err
+	}
+	var matches []string
+	for _, candidate := range ids {
+		if strings.HasPrefix(candidate, id) {
+			matches = append(matches, candidate)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%s: %w", id, ErrNotFound)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("session ID %q is ambiguous (matches %s)", id, strings.Join(matches, ", "))
+	}
+}
+
+func (st *Store) ids() ([]string, error) {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading sessions dir: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && !e.IsDir() && validID.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (st *Store) path(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+func (st *Store) lockPath(id string) string {
+	return filepath.Join(st.dir, id+".lock")
+}
+
+// newID returns a sortable, collision-resistant ID such as
+// 20260102-150405-a1b2.
+func newID() (string, error) {
+	var suffix [2]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return "", fmt.Errorf("generating session ID: %w", err)
+	}
+	return now().Format("20060102-150405") + "-" + hex.EncodeToString(suffix[:]), nil
+}
@@ -0,0 +1,267 @@
+package sessions
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hpkotak/shellbud/internal/conversation"
+	"github.com/hpkotak/shellbud/internal/provider"
+)
+
+// fakeClock makes timestamps (and so IDs and list order) deterministic.
+func fakeClock(t *testing.T) {
+	t.Helper()
+	orig := now
+	current := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	now = func() time.Time {
+		current = current.Add(time.Second)
+		return current
+	}
+	t.Cleanup(func() { now = orig })
+}
+
+func createSaved(t *testing.T, st *Store, question string) *Session {
+	t.Helper()
+	h, err := st.Create("ollama", "llama3.2:latest", "/tmp/project")
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	defer func() { _ = h.Close() }()
+	h.Session.SetHistory([]provider.Message{{Role: "user", Content: question}})
+	if err := h.Save(); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	return h.Session
+}
+
+func TestDir(t *testing.T) {
+	t.Setenv("HOME", "/home/test")
+	if got, want := Dir(), filepath.Join("/home/test", ".shellbud", "sessions"); got != want {
+		t.Errorf("Dir() = %q, want %q", got, want)
+	}
+}
+
+func TestCreateSaveOpenRoundTrip(t *testing.T) {
+	fakeClock(t)
+	st := NewStore(filepath.Join(t.TempDir(), "sessions"))
+
+	h, err := st.Create("openai", "gpt-4o-mini", "/tmp/project")
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	if !validID.MatchString(h.Session.ID) || !strings.HasPrefix(h.Session.ID, "20260102-") {
+		t.Errorf("ID = %q, want timestamp-based ID", h.Session.ID)
+	}
+
+	history := []provider.Message{
+		{Role: "user", Content: "list files"},
+		{Role: "assistant", Content: `{"text":"ok","commands":["ls"]}`},
+		{Role: "user", Content: "I ran `ls` — exit code 2.", FollowUp: true},
+	}
+	h.Session.SetHistory(history)
+	h.Session.Commands = append(h.Session.Commands, Command{Command: "ls", ExitCode: 2, CWD: "/tmp/project"})
+	h.Session.AddSummary(conversation.Summary{Text: "earlier", Covers: 2, Usage: provider.Usage{InputTokens: 10, OutputTokens: 3}})
+	if err := h.Save(); err != nil {
+		t.Fatalf("Save(): %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close(): %v", err)
+	}
+
+	opened, err := st.Open(h.Session.ID)
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+	defer func() { _ = opened.Close() }()
+
+	got := opened.Session
+	if got.Provider != "openai" || got.Model != "gpt-4o-mini" || got.CWD != "/tmp/project" {
+		t.Errorf("metadata = %s/%s in %s, want openai/gpt-4o-mini in /tmp/project", got.Provider, got.Model, got.CWD)
+	}
+	if !got.UpdatedAt.After(got.CreatedAt) {
+		t.Errorf("UpdatedAt %v should be after CreatedAt %v", got.UpdatedAt, got.CreatedAt)
+	}
+	gotHistory := got.History()
+	if !reflect.DeepEqual(gotHistory, history) {
+		t.Errorf("History() = %+v, want %+v", gotHistory, history)
+	}
+	if len(got.Commands) != 1 || got.Commands[0].ExitCode != 2 {
+		t.Errorf("Commands = %+v, want ls with exit code 2", got.Commands)
+	}
+	want := conversation.Summary{Text: "earlier", Covers: 2, Usage: provider.Usage{InputTokens: 10, OutputTokens: 3}}
+	if sum := got.Summary(); sum != want {
+		t.Errorf("Summary() = %+v, want %+v", sum, want)
+	}
+}
+
+func TestSummaryEmpty(t *testing.T) {
+	if got := (&Session{}).Summary(); got != (conversation.Summary{}) {
+		t.Errorf("Summary() = %+v, want zero", got)
+	}
+}
+
+func TestUnsavedSessionLeavesNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sessions")
+	st := NewStore(dir)
+
+	h, err := st.Create("ollama", "llama3.2:latest", "/tmp")
+	if err != nil {
+		t.Fatalf("Create(): %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close(): %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir(): %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("sessions dir has %d entries after an unsaved session, want 0", len(entries))
+	}
+}
+
+func TestOpenLocked(t *testing.T) {
+	st := NewStore(t.TempDir())
+	s := createSaved(t, st, "hello")
+
+	first, err := st.Open(s.ID)
+	if err != nil {
+		t.Fatalf("Open(): %v", err)
+	}
+
+	if _, err := st.Open(s.ID); !errors.Is(err, ErrLocked) {
+		t.Errorf("second Open() error = %v, want ErrLocked", err)
+	}
+	if _, err := st.Remove(s.ID); !errors.Is(err, ErrLocked) {
+		t.Errorf("Remove() of open session error = %v, want ErrLocked", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close(): %v", err)
+	}
+	second, err := st.Open(s.ID)
+	if err != nil {
+		t.Fatalf("Open() after Close(): %v", err)
+	}
+	_ = second.Close()
+}
+
+func TestListAndLatest(t *testing.T) {
+	fakeClock(t)
+	st := NewStore(t.TempDir())
+
+	if _, err := st.Latest(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Latest() on empty store error = %v, want ErrNotFound", err)
+	}
+	if list, err := st.List(); err != nil || len(list) != 0 {
+		t.Errorf("List() on missing dir = %v, %v, want empty", list, err)
+	}
+
+	older := createSaved(t, st, "first")
+	newer := createSaved(t, st, "second")
+	// A corrupt file is skipped, not fatal.
+	if err := os.WriteFile(filepath.Join(st.dir, "broken.json"), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := st.List()
+	if err != nil {
+		t.Fatalf("List(): %v", err)
+	}
+	if len(list) != 2 || list[0].ID != newer.ID || list[1].ID != older.ID {
+		t.Errorf("List() = %v, want newest first", list)
+	}
+	if latest, err := st.Latest(); err != nil || latest != newer.ID {
+		t.Errorf("Latest() = %q, %v, want %q", latest, err, newer.ID)
+	}
+}
+
+func TestLoadResolvesIDs(t *testing.T) {
+	st := NewStore(t.TempDir())
+	for _, id := range []string{"20260102-150405-aaaa", "20260102-150405-aabb", "20260103-090000-cccc"} {
+		h := &Handle{Session: &Session{ID: id}, path: st.path(id)}
+		if err := os.MkdirAll(st.dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Save(); err != nil {
+			t.Fatalf("Save(): %v", err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		id      string
+		want    string
+		wantErr string
+	}{
+		{"exact", "20260102-150405-aaaa", "20260102-150405-aaaa", ""},
+		{"unique prefix", "20260103", "20260103-090000-cccc", ""},
+		{"ambiguous prefix", "20260102-150405-aa", "", "ambiguous"},
+		{"no match", "2025", "", "session not found"},
+		{"path traversal", "../config", "", "invalid session ID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := st.Load(tt.id)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load(%q) error = %v, want %q", tt.id, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load(%q): %v", tt.id, err)
+			}
+			if got.ID != tt.want {
+				t.Errorf("Load(%q).ID = %q, want %q", tt.id, got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCorrupt(t *testing.T) {
+	st := NewStore(t.TempDir())
+	if err := os.WriteFile(st.path("bad"), []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Load("bad"); err == nil || !strings.Contains(err.Error(), "parsing session bad") {
+		t.Errorf("Load() error = %v, want parse error", err)
+	}
+	if _, err := st.Open("bad"); err == nil {
+		t.Error("Open() of corrupt session should fail")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	st := NewStore(t.TempDir())
+	s := createSaved(t, st, "hello")
+
+	removed, err := st.Remove(s.ID[:10])
+	if err != nil {
+		t.Fatalf("Remove(): %v", err)
+	}
+	if removed != s.ID {
+		t.Errorf("Remove() = %q, want full ID %q", removed, s.ID)
+	}
+	for _, path := range []string{st.path(s.ID), st.lockPath(s.ID)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after Remove()", filepath.Base(path))
+		}
+	}
+	if _, err := st.Remove(s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Remove() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSaveFailsWithoutDir(t *testing.T) {
+	h := &Handle{Session: &Session{ID: "x"}, path: filepath.Join(t.TempDir(), "missing", "x.json")}
+	if err := h.Save(); err == nil || !strings.Contains(err.Error(), "writing session") {
+		t.Errorf("Save() error = %v, want write error", err)
+	}
+}
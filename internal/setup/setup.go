@@ -87,6 +87,9 @@ func Run(in io.Reader, out io.Writer) error {
 		Ollama:   config.Ollama{Host: host},
 		OpenAI:   config.OpenAI{Host: config.DefaultOpenAIHost},
 		AFM:      config.AFM{Command: config.DefaultAFMCommand},
+
+		Anthropic: config.Anthropic{Host: config.DefaultAnthropicHost},
+		Gemini:    config.Gemini{Host: config.DefaultGeminiHost},
 	}
 	if err := saveConfig(cfg); err != nil {
 		return fmt.Errorf("saving config: %w", err)
@@ -164,6 +167,9 @@ func setupAFM(bridgePath string, out io.Writer) error {
 		Ollama:   config.Ollama{Host: config.DefaultOllamaHost},
 		OpenAI:   config.OpenAI{Host: config.DefaultOpenAIHost},
 		AFM:      config.AFM{Command: bridgePath},
+
+		Anthropic: config.Anthropic{Host: config.DefaultAnthropicHost},
+		Gemini:    config.Gemini{Host: config.DefaultGeminiHost},
 	}
 	if err := saveConfig(cfg); err != nil {
 		return fmt.Errorf("saving config: %w", err)
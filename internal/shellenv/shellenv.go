@@ -33,11 +33,14 @@ type Snapshot struct {
 	Env       map[string]string // filtered env vars
 }
 
-// execCommandFn is injectable for testing. Default calls exec.Command().Output().
+// execCommandFn is injectable for testing. Default calls exec.Command().Output()
+// in dir, or in the current directory when dir is empty.
 var execCommandFn = defaultExecCommand
 
-func defaultExecCommand(ctx context.Context, name string, args ...string) (string, error) {
-	out, err := exec.CommandContext(ctx, name, args...).Output()
+func defaultExecCommand(ctx context.Context, dir, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
 	return string(out), err
 }
 
@@ -47,6 +50,12 @@ var envVarAllowlist = []string{"EDITOR", "VISUAL", "LANG", "TERM", "HOME", "USER
 // Gather collects the current environment snapshot.
 // Individual failures are swallowed — the snapshot is best-effort.
 func Gather() Snapshot {
+	return GatherIn("")
+}
+
+// GatherIn is Gather for the working directory dir, such as the one a chat's
+// commands have moved to. An empty dir means the current directory.
+func GatherIn(dir string) Snapshot {
 	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
 	defer cancel()
 
@@ -57,11 +66,14 @@ func Gather() Snapshot {
 		Env:   gatherEnv(),
 	}
 
-	s.CWD, _ = os.Getwd()
-	s.DirList = gatherDirList(ctx)
-	s.GitBranch = gatherGitBranch(ctx)
-	s.GitDirty = gatherGitDirty(ctx)
-	s.GitRecent = gatherGitRecent(ctx)
+	s.CWD = dir
+	if dir == "" {
+		s.CWD, _ = os.Getwd()
+	}
+	s.DirList = gatherDirList(ctx, dir)
+	s.GitBranch = gatherGitBranch(ctx, dir)
+	s.GitDirty = gatherGitDirty(ctx, dir)
+	s.GitRecent = gatherGitRecent(ctx, dir)
 
 	return s
 }
@@ -115,32 +127,32 @@ func gatherEnv() map[string]string {
 	return env
 }
 
-func gatherDirList(ctx context.Context) string {
-	out, err := execCommandFn(ctx, "ls", "-la")
+func gatherDirList(ctx context.Context, dir string) string {
+	out, err := execCommandFn(ctx, dir, "ls", "-la")
 	if err != nil {
 		return ""
 	}
 	return truncateLines(out, maxDirLines)
 }
 
-func gatherGitBranch(ctx context.Context) string {
-	out, err := execCommandFn(ctx, "git", "rev-parse", "--abbrev-ref", "HEAD")
+func gatherGitBranch(ctx context.Context, dir string) string {
+	out, err := execCommandFn(ctx, dir, "git", "rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
 		return ""
 	}
 	return strings.TrimSpace(out)
 }
 
-func gatherGitDirty(ctx context.Context) bool {
-	out, err := execCommandFn(ctx, "git", "status", "--porcelain")
+func gatherGitDirty(ctx context.Context, dir string) bool {
+	out, err := execCommandFn(ctx, dir, "git", "status", "--porcelain")
 	if err != nil {
 		return false
 	}
 	return strings.TrimSpace(out) != ""
 }
 
-func gatherGitRecent(ctx context.Context) string {
-	out, err := execCommandFn(ctx, "git", "log", "--oneline", fmt.Sprintf("-%d", maxGitLogLines))
+func gatherGitRecent(ctx context.Context, dir string) string {
+	out, err := execCommandFn(ctx, dir, "git", "log", "--oneline", fmt.Sprintf("-%d", maxGitLogLines))
 	if err != nil {
 		return ""
 	}
@@ -3,14 +3,15 @@ package shellenv
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
 // mockExec returns a function that maps command names to canned outputs.
 // Commands not in the map return an error.
-func mockExec(responses map[string]string) func(context.Context, string, ...string) (string, error) {
-	return func(_ context.Context, name string, args ...string) (string, error) {
+func mockExec(responses map[string]string) func(context.Context, string, string, ...string) (string, error) {
+	return func(_ context.Context, _, name string, args ...string) (string, error) {
 		// Use "name args[0]" as key for specificity (e.g., "git rev-parse")
 		key := name
 		if len(args) > 0 {
@@ -59,6 +60,43 @@ func TestGather(t *testing.T) {
 	}
 }
 
+func TestGatherIn(t *testing.T) {
+	origExec := execCommandFn
+	defer func() { execCommandFn = origExec }()
+
+	var dirs []string
+	execCommandFn = func(_ context.Context, dir, _ string, _ ...string) (string, error) {
+		dirs = append(dirs, dir)
+		return "", nil
+	}
+
+	snap := GatherIn("/srv/app/build")
+	if snap.CWD != "/srv/app/build" {
+		t.Errorf("CWD = %q, want the given dir", snap.CWD)
+	}
+	if len(dirs) != 4 {
+		t.Fatalf("ran %d commands, want 4", len(dirs))
+	}
+	for _, dir := range dirs {
+		if dir != "/srv/app/build" {
+			t.Errorf("command ran in %q, want the given dir", dir)
+		}
+	}
+}
+
+func TestDefaultExecCommandDir(t *testing.T) {
+	dir := t.TempDir()
+	out, err := defaultExecCommand(context.Background(), dir, "pwd")
+	if err != nil {
+		t.Fatalf("defaultExecCommand() error: %v", err)
+	}
+	got, _ := filepath.EvalSymlinks(strings.TrimSpace(out))
+	want, _ := filepath.EvalSymlinks(dir)
+	if got != want {
+		t.Errorf("pwd = %q, want %q", got, want)
+	}
+}
+
 func TestGatherNoGit(t *testing.T) {
 	origExec := execCommandFn
 	defer func() { execCommandFn = origExec }()
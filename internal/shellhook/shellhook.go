@@ -0,0 +1,182 @@
+// Package shellhook holds the shell snippets users add to their rc file.
+//
+// The hook records the last command line and its exit status in shell
+// variables before each prompt. They are not exported, since a command line
+// can hold tokens or passwords; an sb wrapper function passes them to sb
+// when an argument is "fix", and sb drops them unless it is running
+// `sb fix`, which reads them from its environment. The widget binds Ctrl-G
+// to send the current command line to `sb --print-command` and replace it
+// with the command the user picks, so the command is edited and run by the
+// shell itself and lands in its history.
+package shellhook
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables the hook passes to `sb fix`.
+const (
+	EnvLastCommand = "SB_LAST_COMMAND"
+	EnvLastStatus  = "SB_LAST_STATUS"
+)
+
+// Shells lists the supported shells, in help order.
+var Shells = []string{"bash", "zsh", "fish"}
+
+const bashHook = `# ShellBud: record the last command for 'sb fix'.
+__sb_record_last() {
+  local s=$?
+  __sb_last_status=$s
+  __sb_last_command="$(HISTTIMEFORMAT= builtin history 1 | sed 's/^ *[0-9]*[* ] *//')"
+  return $s
+}
+case ";${PROMPT_COMMAND:-};" in
+  *";__sb_record_last;"*) ;;
+  *) PROMPT_COMMAND="__sb_record_last${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+esac
+sb() {
+  local arg
+  for arg in "$@"; do
+    if [[ $arg == fix ]]; then
+      SB_LAST_COMMAND=$__sb_last_command SB_LAST_STATUS=$__sb_last_status command sb "$@"
+      return
+    fi
+  done
+  command sb "$@"
+}
+`
+
+const zshHook = `# ShellBud: record the last command for 'sb fix'.
+__sb_record_last() {
+  local s=$?
+  __sb_last_status=$s
+  __sb_last_command="$(fc -ln -1)"
+  return $s
+}
+(( ${precmd_functions[(Ie)__sb_record_last]} )) || precmd_functions=(__sb_record_last $precmd_functions)
+sb() {
+  local arg
+  for arg in "$@"; do
+    if [[ $arg == fix ]]; then
+      SB_LAST_COMMAND=$__sb_last_command SB_LAST_STATUS=$__sb_last_status command sb "$@"
+      return
+    fi
+  done
+  command sb "$@"
+}
+`
+
+const fishHook = `# ShellBud: record the last command for 'sb fix'.
+function __sb_record_last --on-event fish_postexec
+    set -g __sb_last_status $status
+    set -g __sb_last_command $argv[1]
+end
+function sb --wraps sb
+    if contains -- fix $argv
+        env "SB_LAST_COMMAND=$__sb_last_command" "SB_LAST_STATUS=$__sb_last_status" sb $argv
+    else
+        command sb $argv
+    end
+end
+`
+
+const bashWidget = `# ShellBud: Ctrl-G replaces the command line with a command suggested by sb.
+__sb_widget() {
+  [[ -z $READLINE_LINE ]] && return
+  local cmd
+  cmd=$(command sb --print-command -- "$READLINE_LINE" </dev/tty)
+  if [[ -n $cmd ]]; then
+    READLINE_LINE=$cmd
+    READLINE_POINT=${#cmd}
+  fi
+}
+bind -x '"\C-g": __sb_widget'
+`
+
+const zshWidget = `# ShellBud: Ctrl-G replaces the command line with a command suggested by sb.
+__sb_widget() {
+  [[ -z $BUFFER ]] && return
+  local cmd
+  zle -I
+  cmd=$(command sb --print-command -- "$BUFFER" </dev/tty)
+  if [[ -n $cmd ]]; then
+    BUFFER=$cmd
+    CURSOR=${#BUFFER}
+  fi
+  zle reset-prompt
+}
+zle -N __sb_widget
+bindkey '^G' __sb_widget
+`
+
+const fishWidget = `# ShellBud: Ctrl-G replaces the command line with a command suggested by sb.
+function __sb_widget
+    set -l query (commandline)
+    test -z "$query"; and return
+    set -l cmd (command sb --print-command -- "$query" </dev/tty | string collect)
+    if test -n "$cmd"
+        commandline -r -- $cmd
+    end
+    commandline -f repaint
+end
+bind \cg __sb_widget
+`
+
+// Init returns the full shell integration for shell: the hook followed by
+// the Ctrl-G widget.
+func Init(shell string) (string, error) {
+	hook, err := Script(shell)
+	if err != nil {
+		return "", err
+	}
+	widgets := map[string]string{"bash": bashWidget, "zsh": zshWidget, "fish": fishWidget}
+	return hook + "\n" + widgets[shell], nil
+}
+
+// Script returns the hook for shell.
+func Script(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashHook, nil
+	case "zsh":
+		return zshHook, nil
+	case "fish":
+		return fishHook, nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (supported: %s)", shell, strings.Join(Shells, ", "))
+}
+
+// LastCommand is the last command the hook recorded.
+type LastCommand struct {
+	Command string
+	// ExitCode is -1 when the hook did not record a status.
+	ExitCode int
+}
+
+// Forget removes the hook's variables from the environment, so commands sb
+// runs do not inherit them.
+func Forget() {
+	_ = os.Unsetenv(EnvLastCommand)
+	_ = os.Unsetenv(EnvLastStatus)
+}
+
+// Last returns the command recorded by the hook. ok is false when the hook
+// is not installed or has recorded nothing yet. It removes the variables
+// from the environment, so commands sb runs do not inherit them.
+func Last() (last LastCommand, ok bool) {
+	command := strings.TrimSpace(os.Getenv(EnvLastCommand))
+	status := os.Getenv(EnvLastStatus)
+	_ = os.Unsetenv(EnvLastCommand)
+	_ = os.Unsetenv(EnvLastStatus)
+	if command == "" {
+		return LastCommand{}, false
+	}
+	last = LastCommand{Command: command, ExitCode: -1}
+	if code, err := strconv.Atoi(strings.TrimSpace(status)); err == nil {
+		last.ExitCode = code
+	}
+	return last, true
+}
@@ -0,0 +1,122 @@
+package shellhook
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	for _, shell := range Shells {
+		t.Run(shell, func(t *testing.T) {
+			got, err := Script(shell)
+			if err != nil {
+				t.Fatalf("Script(%q): %v", shell, err)
+			}
+			for _, want := range []string{EnvLastCommand, EnvLastStatus, "__sb_record_last", "command sb"} {
+				if !strings.Contains(got, want) {
+					t.Errorf("Script(%q) missing %q", shell, want)
+				}
+			}
+			for _, leak := range []string{"export ", "-gx", "-x "} {
+				if strings.Contains(got, leak) {
+					t.Errorf("Script(%q) exports the last command (%q)", shell, leak)
+				}
+			}
+		})
+	}
+
+	if _, err := Script("tcsh"); err == nil || !strings.Contains(err.Error(), "bash, zsh, fish") {
+		t.Errorf("Script(tcsh) error = %v, want unsupported shell", err)
+	}
+}
+
+func TestLast(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		status  string
+		want    LastCommand
+		wantOK  bool
+	}{
+		{"not recorded", "", "", LastCommand{}, false},
+		{"blank command", "  ", "1", LastCommand{}, false},
+		{"command and status", " git pus \n", "1", LastCommand{Command: "git pus", ExitCode: 1}, true},
+		{"missing status", "make", "", LastCommand{Command: "make", ExitCode: -1}, true},
+		{"bad status", "make", "x", LastCommand{Command: "make", ExitCode: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(EnvLastCommand, tt.command)
+			t.Setenv(EnvLastStatus, tt.status)
+			got, ok := Last()
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Last() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if _, set := os.LookupEnv(EnvLastCommand); set {
+				t.Errorf("Last() left %s in the environment", EnvLastCommand)
+			}
+			if _, set := os.LookupEnv(EnvLastStatus); set {
+				t.Errorf("Last() left %s in the environment", EnvLastStatus)
+			}
+		})
+	}
+}
+
+func TestBashHookKeepsCommandLocal(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	// A fake sb that prints what it was given.
+	bin := t.TempDir()
+	fake := "#!/bin/sh\necho \"sb $*: ${SB_LAST_COMMAND-unset} ${SB_LAST_STATUS-unset}\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "sb"), []byte(fake), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	script := bashHook + `
+__sb_last_command='curl -H "Authorization: secret"'
+__sb_last_status=7
+sh -c 'echo "child: ${SB_LAST_COMMAND-unset}"'
+sb version
+sb fix
+sb --yes fix
+`
+	cmd := exec.Command(bash, "--norc", "-c", script)
+	cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("bash: %v\n%s", err, out)
+	}
+	want := "child: unset\nsb version: unset unset\nsb fix: curl -H \"Authorization: secret\" 7\n" +
+		"sb --yes fix: curl -H \"Authorization: secret\" 7\n"
+	if string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestInit(t *testing.T) {
+	for _, shell := range Shells {
+		t.Run(shell, func(t *testing.T) {
+			got, err := Init(shell)
+			if err != nil {
+				t.Fatalf("Init(%q): %v", shell, err)
+			}
+			hook, _ := Script(shell)
+			if !strings.HasPrefix(got, hook) {
+				t.Errorf("Init(%q) should start with the hook", shell)
+			}
+			for _, want := range []string{"__sb_widget", "sb --print-command --", "</dev/tty"} {
+				if !strings.Contains(got, want) {
+					t.Errorf("Init(%q) missing %q", shell, want)
+				}
+			}
+		})
+	}
+
+	if _, err := Init("tcsh"); err == nil {
+		t.Error("Init(tcsh) should fail")
+	}
+}
@@ -43,6 +43,7 @@ packages=(
 	"./internal/provider"
 	"./internal/safety"
 	"./internal/prompt"
+	"./internal/conversation"
 	"./internal/setup"
 )
 
@@ -52,6 +53,7 @@ thresholds=(
 	"$critical_min"
 	"$critical_min"
 	"$critical_min"
+	"$critical_min"
 	"$setup_min"
 )
 